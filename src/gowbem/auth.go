@@ -0,0 +1,288 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// Challenges of the CIMRoleAuthenticate header.
+	RoleCredentialRequired    = "credentialrequired"
+	RoleCredentialOptional    = "credentialoptional"
+	RoleCredentialNotRequired = "credentialnotrequired"
+)
+
+// A challenge of a WWW-Authenticate header.
+type authChallenge struct {
+	scheme string
+	params map[string]string
+}
+
+// Authentication state of a connection. The first request goes without credentials, unless
+// WithPreemptiveBasic is set, so that the password is not sent in the clear to a server that
+// would ask for Digest; the request is sent again with the scheme the 401 response asks for.
+// The scheme is cached, and so is the Digest challenge, so that the following requests
+// authenticate without another round trip until the server marks the nonce as stale.
+type authState struct {
+	mu        sync.Mutex
+	basic     bool
+	digest    *authChallenge
+	nc        uint32
+	roleAuth  string
+	roleUser  string
+	rolePass  string
+	roleIsSet bool
+}
+
+// WithRoleCredentials sets the credentials sent in the CIMRoleAuthorization header when the WBEM
+// server asks for role credentials with the CIMRoleAuthenticate header.
+func WithRoleCredentials(username, password string) Option {
+	return func(conn *WBEMConnection) error {
+		conn.SetRoleCredentials(username, password)
+		return nil
+	}
+}
+
+// WithPreemptiveBasic sends the credentials with Basic from the first request on, saving the round
+// trip of the 401 challenge with WBEM servers known to use Basic, preferably over https.
+func WithPreemptiveBasic() Option {
+	return func(conn *WBEMConnection) error {
+		conn.auth.mu.Lock()
+		defer conn.auth.mu.Unlock()
+		conn.auth.basic = true
+		return nil
+	}
+}
+
+// SetRoleCredentials sets the credentials sent in the CIMRoleAuthorization header.
+func (conn *WBEMConnection) SetRoleCredentials(username, password string) {
+	conn.auth.mu.Lock()
+	defer conn.auth.mu.Unlock()
+	conn.auth.roleUser = username
+	conn.auth.rolePass = password
+	conn.auth.roleIsSet = true
+}
+
+// Parses the challenges of WWW-Authenticate header values, for example:
+//      Digest realm="cimom", qop="auth", nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", algorithm=SHA-256
+func parseChallenges(values []string) []authChallenge {
+	var challenges []authChallenge
+	for _, value := range values {
+		var cur *authChallenge
+		rest := strings.TrimSpace(value)
+		for "" != rest {
+			// scheme token or auth-param name
+			i := strings.IndexAny(rest, " =,")
+			if 0 > i {
+				i = len(rest)
+			}
+			token := rest[:i]
+			rest = strings.TrimLeft(rest[i:], " ")
+			if strings.HasPrefix(rest, "=") {
+				rest = strings.TrimLeft(rest[1:], " ")
+				val := ""
+				if strings.HasPrefix(rest, "\"") {
+					var b strings.Builder
+					j := 1
+					for ; j < len(rest) && '"' != rest[j]; j++ {
+						if '\\' == rest[j] && j+1 < len(rest) {
+							j++
+						}
+						b.WriteByte(rest[j])
+					}
+					val = b.String()
+					if j < len(rest) {
+						j++
+					}
+					rest = rest[j:]
+				} else {
+					j := strings.IndexByte(rest, ',')
+					if 0 > j {
+						j = len(rest)
+					}
+					val = strings.TrimSpace(rest[:j])
+					rest = rest[j:]
+				}
+				if nil != cur {
+					cur.params[strings.ToLower(token)] = val
+				}
+			} else if "" != token {
+				challenges = append(challenges, authChallenge{scheme: strings.ToLower(token), params: map[string]string{}})
+				cur = &challenges[len(challenges)-1]
+			}
+			rest = strings.TrimLeft(rest, " ,")
+		}
+	}
+	return challenges
+}
+
+func digestHash(algorithm string) (func() hash.Hash, error) {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS")) {
+	case "", "MD5":
+		return md5.New, nil
+	case "SHA-256":
+		return sha256.New, nil
+	}
+	return nil, fmt.Errorf("unsupported digest algorithm %s", algorithm)
+}
+
+func hashHex(newHash func() hash.Hash, s string) string {
+	h := newHash()
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func newCnonce() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Computes the Authorization header value answering a Digest challenge (RFC 7616), with qop=auth
+// when the server offers it.
+func digestAuthorization(challenge *authChallenge, nc uint32, username, password, method, uri string) (string, error) {
+	algorithm := challenge.params["algorithm"]
+	newHash, err := digestHash(algorithm)
+	if nil != err {
+		return "", err
+	}
+	realm := challenge.params["realm"]
+	nonce := challenge.params["nonce"]
+	cnonce := newCnonce()
+	ha1 := hashHex(newHash, username+":"+realm+":"+password)
+	if strings.HasSuffix(strings.ToLower(algorithm), "-sess") {
+		ha1 = hashHex(newHash, ha1+":"+nonce+":"+cnonce)
+	}
+	ha2 := hashHex(newHash, method+":"+uri)
+	qop := ""
+	for _, sub := range strings.Split(challenge.params["qop"], ",") {
+		if "auth" == strings.TrimSpace(sub) {
+			qop = "auth"
+		}
+	}
+	ncStr := fmt.Sprintf("%08x", nc)
+	var response string
+	if "" != qop {
+		response = hashHex(newHash, ha1+":"+nonce+":"+ncStr+":"+cnonce+":"+qop+":"+ha2)
+	} else {
+		response = hashHex(newHash, ha1+":"+nonce+":"+ha2)
+	}
+	auth := fmt.Sprintf("Digest username=\"%s\", realm=\"%s\", nonce=\"%s\", uri=\"%s\", response=\"%s\"", username, realm, nonce, uri, response)
+	if "" != algorithm {
+		auth += fmt.Sprintf(", algorithm=%s", algorithm)
+	}
+	if "" != qop {
+		auth += fmt.Sprintf(", qop=%s, nc=%s, cnonce=\"%s\"", qop, ncStr, cnonce)
+	}
+	if opaque, ok := challenge.params["opaque"]; ok {
+		auth += fmt.Sprintf(", opaque=\"%s\"", opaque)
+	}
+	return auth, nil
+}
+
+func basicAuthorization(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+// Sets the Authorization and CIMRoleAuthorization headers of a request.
+func (conn *WBEMConnection) authorize(req *http.Request, username, password string) error {
+	conn.auth.mu.Lock()
+	defer conn.auth.mu.Unlock()
+	sendRole := true == conn.auth.roleIsSet &&
+		(RoleCredentialRequired == conn.auth.roleAuth || RoleCredentialOptional == conn.auth.roleAuth)
+	if nil == conn.auth.digest {
+		if false == conn.auth.basic {
+			// no scheme yet, until challenged
+			return nil
+		}
+		req.Header.Set("Authorization", basicAuthorization(username, password))
+		if true == sendRole {
+			req.Header.Set(HttpHdrRoleAuthorization, basicAuthorization(conn.auth.roleUser, conn.auth.rolePass))
+		}
+		return nil
+	}
+	conn.auth.nc++
+	auth, err := digestAuthorization(conn.auth.digest, conn.auth.nc, username, password, req.Method, req.URL.RequestURI())
+	if nil != err {
+		return err
+	}
+	req.Header.Set("Authorization", auth)
+	if true == sendRole {
+		conn.auth.nc++
+		auth, err = digestAuthorization(conn.auth.digest, conn.auth.nc, conn.auth.roleUser, conn.auth.rolePass, req.Method, req.URL.RequestURI())
+		if nil != err {
+			return err
+		}
+		req.Header.Set(HttpHdrRoleAuthorization, auth)
+	}
+	return nil
+}
+
+// Records the challenges of a 401 response. It returns true if the request is worth sending again,
+// which is the case when the server asks for Digest with a new or stale nonce, for Basic when the
+// request had no credentials, or for role credentials. A server that asks for nothing gets Basic.
+func (conn *WBEMConnection) challenged(res *http.Response) bool {
+	conn.auth.mu.Lock()
+	defer conn.auth.mu.Unlock()
+	retry := false
+	roleAuth := strings.ToLower(strings.TrimSpace(res.Header.Get(HttpHdrAuthenticate)))
+	if "" != roleAuth && roleAuth != conn.auth.roleAuth {
+		conn.auth.roleAuth = roleAuth
+		retry = true == conn.auth.roleIsSet && RoleCredentialNotRequired != roleAuth
+	}
+	var digest *authChallenge
+	basic := false
+	challenges := parseChallenges(res.Header.Values("WWW-Authenticate"))
+	for _, challenge := range challenges {
+		if "basic" == challenge.scheme {
+			basic = true
+		}
+		if "digest" != challenge.scheme {
+			continue
+		}
+		if _, err := digestHash(challenge.params["algorithm"]); nil != err {
+			continue
+		}
+		// prefer SHA-256 over MD5 when both are offered
+		if nil == digest || strings.HasPrefix(strings.ToUpper(challenge.params["algorithm"]), "SHA-256") {
+			c := challenge
+			digest = &c
+		}
+	}
+	if nil != digest && (nil == conn.auth.digest || digest.params["nonce"] != conn.auth.digest.params["nonce"]) {
+		conn.auth.digest = digest
+		conn.auth.nc = 0
+		retry = true
+	}
+	unauthenticated := nil == res.Request || "" == res.Request.Header.Get("Authorization")
+	if nil == conn.auth.digest && false == conn.auth.basic && (basic || 0 == len(challenges)) && unauthenticated {
+		conn.auth.basic = true
+		retry = true
+	}
+	return retry
+}
@@ -0,0 +1,236 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// A WBEM server that asks for Digest, MD5 with qop=auth, and optionally for role credentials.
+type digestServer struct {
+	username string
+	password string
+	// the role credentials asked for with CIMRoleAuthenticate, if any
+	roleUser string
+	rolePass string
+
+	mu       sync.Mutex
+	nonce    int
+	requests int
+	basic    int
+}
+
+func (srv *digestServer) currentNonce() string {
+	return fmt.Sprintf("nonce-%d", srv.nonce)
+}
+
+// Reports whether the Digest authorization answers the current nonce with the credentials.
+func (srv *digestServer) verify(req *http.Request, header, username, password string) (ok, stale bool) {
+	value := req.Header.Get(header)
+	if false == strings.HasPrefix(value, "Digest ") {
+		return false, false
+	}
+	challenges := parseChallenges([]string{value})
+	if 1 != len(challenges) {
+		return false, false
+	}
+	params := challenges[0].params
+	if username != params["username"] || "cimom" != params["realm"] || req.URL.RequestURI() != params["uri"] {
+		return false, false
+	}
+	ha1 := hashHex(md5.New, username+":cimom:"+password)
+	ha2 := hashHex(md5.New, req.Method+":"+params["uri"])
+	want := hashHex(md5.New, ha1+":"+params["nonce"]+":"+params["nc"]+":"+params["cnonce"]+":auth:"+ha2)
+	if want != params["response"] {
+		return false, false
+	}
+	return true, srv.currentNonce() != params["nonce"]
+}
+
+func (srv *digestServer) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.requests++
+	if strings.HasPrefix(req.Header.Get("Authorization"), "Basic ") || strings.HasPrefix(req.Header.Get(HttpHdrRoleAuthorization), "Basic ") {
+		srv.basic++
+	}
+	ok, stale := srv.verify(req, "Authorization", srv.username, srv.password)
+	if ok && false == stale && "" != srv.roleUser {
+		ok, stale = srv.verify(req, HttpHdrRoleAuthorization, srv.roleUser, srv.rolePass)
+	}
+	if false == ok || stale {
+		challenge := fmt.Sprintf(`Digest realm="cimom", qop="auth", nonce="%s", algorithm=MD5`, srv.currentNonce())
+		if stale {
+			challenge += ", stale=true"
+		}
+		writer.Header().Set("WWW-Authenticate", challenge)
+		if "" != srv.roleUser {
+			writer.Header().Set(HttpHdrAuthenticate, RoleCredentialRequired)
+		}
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writer.Write([]byte("<CIM/>"))
+}
+
+func (srv *digestServer) counts() (requests, basic int) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	requests, basic = srv.requests, srv.basic
+	srv.requests = 0
+	return requests, basic
+}
+
+func newDigestTest(t *testing.T, srv *digestServer, opts ...Option) (*WBEMConnection, func()) {
+	ts := httptest.NewServer(srv)
+	conn, err := NewWBEMConnWithOptions(ts.URL, append([]Option{WithCredentials(srv.username, srv.password)}, opts...)...)
+	if nil != err {
+		ts.Close()
+		t.Fatalf("NewWBEMConnWithOptions: %v", err)
+	}
+	return conn, ts.Close
+}
+
+func post(conn *WBEMConnection) error {
+	_, err := conn.doPost(context.Background(), "GetClass", "root/cimv2", []byte("<CIM/>"), true)
+	return err
+}
+
+func TestDigestChallenge(t *testing.T) {
+	srv := &digestServer{username: "admin", password: "secret"}
+	conn, done := newDigestTest(t, srv)
+	defer done()
+
+	if err := post(conn); nil != err {
+		t.Fatalf("first request: %v", err)
+	}
+	if requests, basic := srv.counts(); 2 != requests || 0 != basic {
+		t.Errorf("first request: %d requests, %d with Basic, want 2 and 0", requests, basic)
+	}
+	// the cached challenge authenticates without another round trip
+	if err := post(conn); nil != err {
+		t.Fatalf("second request: %v", err)
+	}
+	if requests, basic := srv.counts(); 1 != requests || 0 != basic {
+		t.Errorf("second request: %d requests, %d with Basic, want 1 and 0", requests, basic)
+	}
+}
+
+func TestDigestStaleNonce(t *testing.T) {
+	srv := &digestServer{username: "admin", password: "secret"}
+	conn, done := newDigestTest(t, srv)
+	defer done()
+
+	if err := post(conn); nil != err {
+		t.Fatalf("first request: %v", err)
+	}
+	srv.counts()
+	srv.mu.Lock()
+	srv.nonce++
+	srv.mu.Unlock()
+	if err := post(conn); nil != err {
+		t.Fatalf("request after the nonce expired: %v", err)
+	}
+	if requests, _ := srv.counts(); 2 != requests {
+		t.Errorf("%d requests after the nonce expired, want 2", requests)
+	}
+}
+
+func TestDigestWrongPassword(t *testing.T) {
+	srv := &digestServer{username: "admin", password: "secret"}
+	conn, done := newDigestTest(t, srv, WithCredentials("admin", "wrong"))
+	defer done()
+
+	err := post(conn)
+	if httpErr, ok := err.(HttpErr); false == ok || http.StatusUnauthorized != httpErr.StatusCode {
+		t.Fatalf("got %v, want a 401 HttpErr", err)
+	}
+	if requests, basic := srv.counts(); 2 != requests || 0 != basic {
+		t.Errorf("%d requests, %d with Basic, want 2 and 0", requests, basic)
+	}
+}
+
+func TestRoleAuthorization(t *testing.T) {
+	srv := &digestServer{username: "admin", password: "secret", roleUser: "operator", rolePass: "role-secret"}
+	conn, done := newDigestTest(t, srv, WithRoleCredentials("operator", "role-secret"))
+	defer done()
+
+	if err := post(conn); nil != err {
+		t.Fatalf("first request: %v", err)
+	}
+	if err := post(conn); nil != err {
+		t.Fatalf("second request: %v", err)
+	}
+	if requests, basic := srv.counts(); 3 != requests || 0 != basic {
+		t.Errorf("%d requests, %d with Basic, want 3 and 0", requests, basic)
+	}
+}
+
+func TestBasicChallenge(t *testing.T) {
+	var mu sync.Mutex
+	var unauthenticated, authorized int
+	ts := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		username, password, ok := req.BasicAuth()
+		if false == ok {
+			unauthenticated++
+			writer.Header().Set("WWW-Authenticate", `Basic realm="cimom"`)
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if "admin" != username || "secret" != password {
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		authorized++
+		writer.Write([]byte("<CIM/>"))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		opts            []Option
+		unauthenticated int
+	}{
+		{"challenged", nil, 1},
+		{"preemptive", []Option{WithPreemptiveBasic()}, 0},
+	}
+	for _, test := range tests {
+		unauthenticated, authorized = 0, 0
+		conn, err := NewWBEMConnWithOptions(ts.URL, append([]Option{WithCredentials("admin", "secret")}, test.opts...)...)
+		if nil != err {
+			t.Fatalf("%s: NewWBEMConnWithOptions: %v", test.name, err)
+		}
+		for i := 0; i < 2; i++ {
+			if err = post(conn); nil != err {
+				t.Fatalf("%s: request %d: %v", test.name, i, err)
+			}
+		}
+		if test.unauthenticated != unauthenticated || 2 != authorized {
+			t.Errorf("%s: %d requests without credentials and %d authorized, want %d and 2", test.name, unauthenticated, authorized, test.unauthenticated)
+		}
+	}
+}
@@ -19,96 +19,181 @@ package gowbem
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 )
 
+// StatusCode is a CIM status code. The Err* codes are errors themselves, so that a CIMErr can be
+// identified with errors.Is(err, ErrNotFound).
+type StatusCode int
+
 const (
 	// A general error occurred that is not covered by a more specific error code.
-	ErrFailed = 1
+	ErrFailed StatusCode = 1
 
 	// Access to a CIM resource is not available to the client.
-	ErrAccessDenied = 2
+	ErrAccessDenied StatusCode = 2
 
 	// The target namespace does not exist.
-	ErrInvalidNamespace = 3
+	ErrInvalidNamespace StatusCode = 3
 
 	// One or more parameter values passed to the method are not valid.
-	ErrInvalidParameter = 4
+	ErrInvalidParameter StatusCode = 4
 
 	// The specified class does not exist.
-	ErrInvalidClass = 5
+	ErrInvalidClass StatusCode = 5
 
 	// The requested object cannot be found. The operation can be unsupported on behalf of the WBEM server in general or on behalf of an implementation of a management profile.
-	ErrNotFound = 6
+	ErrNotFound StatusCode = 6
 
 	// The requested operation is not supported on behalf of the WBEM server, or on behalf of a provided class. If the operation is supported for a provided class but is not supported for particular instances of that class, then CIM_ERR_FAILED shall be used.
-	ErrNotSupported = 7
+	ErrNotSupported StatusCode = 7
 
 	// The operation cannot be invoked on this class because it has subclasses.
-	ErrClassHasChildren = 8
+	ErrClassHasChildren StatusCode = 8
 
 	// The operation cannot be invoked on this class because one or more instances of this class exist.
-	ErrClassHasInstances = 9
+	ErrClassHasInstances StatusCode = 9
 
 	// The operation cannot be invoked because the specified superclass does not exist.
-	ErrInvalidSuperclass = 10
+	ErrInvalidSuperclass StatusCode = 10
 
 	// The operation cannot be invoked because an object already exists.
-	ErrAlreadyExists = 11
+	ErrAlreadyExists StatusCode = 11
 
 	// The specified property does not exist.
-	ErrNoSuchProperty = 12
+	ErrNoSuchProperty StatusCode = 12
 
 	// The value supplied is not compatible with the type.
-	ErrTypeMismatch = 13
+	ErrTypeMismatch StatusCode = 13
 
 	// The query language is not recognized or supported.
-	ErrQueryLanguageNotSupported = 14
+	ErrQueryLanguageNotSupported StatusCode = 14
 
 	// The query is not valid for the specified query language.
-	ErrInvalidQuery = 15
+	ErrInvalidQuery StatusCode = 15
 
 	// The extrinsic method cannot be invoked.
-	ErrMethodNotAvailable = 16
+	ErrMethodNotAvailable StatusCode = 16
 
 	// The specified extrinsic method does not exist.
-	ErrMethodNotFound = 17
+	ErrMethodNotFound StatusCode = 17
 
 	// The specified namespace is not empty.
-	ErrNameSpaceNotEmpty = 20
+	ErrNameSpaceNotEmpty StatusCode = 20
 
 	// The enumeration identified by the specified context cannot be found, is in a closed state, does not exist, or is otherwise invalid.
-	ErrInvalidEnumerationContext = 21
+	ErrInvalidEnumerationContext StatusCode = 21
 
 	// The specified operation timeout is not supported by the WBEM server.
-	ErrInvalidOperationTimeout = 22
+	ErrInvalidOperationTimeout StatusCode = 22
 
 	// The pull operation has been abandoned due to execution of a concurrent CloseEnumeration operation on the same enumeration.
-	ErrPullHasBeenAbandoned = 23
+	ErrPullHasBeenAbandoned StatusCode = 23
 
 	// The attempt to abandon a concurrent pull operation on the same enumeration
 	// failed. The concurrent pull operation proceeds normally.
-	ErrPullCannotBeAbandoned = 24
+	ErrPullCannotBeAbandoned StatusCode = 24
 
 	// Using a a filter query in pulled enumerations is not supported by the WBEM server.
-	ErrFilteredEnumerationNotSupported = 25
+	ErrFilteredEnumerationNotSupported StatusCode = 25
 
 	// The WBEM server does not support continuation on error.
-	ErrContinuationOnErrorNotSupported = 26
+	ErrContinuationOnErrorNotSupported StatusCode = 26
 
 	// The WBEM server has failed the operation based upon exceeding server limits.
-	ErrServerLimitsExceeded = 27
+	ErrServerLimitsExceeded StatusCode = 27
 
 	// The WBEM server is shutting down and cannot process the operation.
-	ErrServerIsShuttingDown = 28
+	ErrServerIsShuttingDown StatusCode = 28
 )
 
+// Values of the CIMError header, returned in a HTTP response to a CIM message request that is not a CIM message response.
+type HttpCIMError string
+
+const (
+	// The CIM server does not support the CIM protocol version of the request.
+	HttpErrUnsupportedProtocolVersion HttpCIMError = "unsupported-protocol-version"
+
+	// The CIM server does not support multiple operation requests.
+	HttpErrMultipleRequestsUnsupported HttpCIMError = "multiple-requests-unsupported"
+
+	// The CIM server does not support the CIM version of the request.
+	HttpErrUnsupportedCIMVersion HttpCIMError = "unsupported-cim-version"
+
+	// The CIM server does not support the DTD version of the request.
+	HttpErrUnsupportedDTDVersion HttpCIMError = "unsupported-dtd-version"
+
+	// The request is not valid with respect to the DTD.
+	HttpErrRequestNotValid HttpCIMError = "request-not-valid"
+
+	// The request is not well-formed XML.
+	HttpErrRequestNotWellFormed HttpCIMError = "request-not-well-formed"
+
+	// The request is not loosely valid with respect to the DTD.
+	HttpErrRequestNotLooselyValid HttpCIMError = "request-not-loosely-valid"
+
+	// The CIM extension headers of the request do not match its content.
+	HttpErrHeaderMismatch HttpCIMError = "header-mismatch"
+
+	// The CIM server does not support the operation of the request.
+	HttpErrUnsupportedOperation HttpCIMError = "unsupported-operation"
+)
+
+func (err HttpCIMError) Error() string {
+	return string(err)
+}
+
+// HttpErr is returned when the WBEM server answers with a HTTP status other than 200. CIMError
+// holds the value of the CIMError header, if any, so that errors.Is(err, HttpErrHeaderMismatch)
+// identifies the cause.
+type HttpErr struct {
+	StatusCode int
+	Status     string
+	CIMError   HttpCIMError
+}
+
+func (err HttpErr) Error() string {
+	if "" != err.CIMError {
+		return fmt.Sprintf("HTTP_ERR - %d - %s - %s", err.StatusCode, err.Status, err.CIMError)
+	}
+	return fmt.Sprintf("HTTP_ERR - %d - %s", err.StatusCode, err.Status)
+}
+
+func (err HttpErr) Is(target error) bool {
+	cimError, ok := target.(HttpCIMError)
+	return ok && "" != err.CIMError && cimError == err.CIMError
+}
+
+// CIMErr is the error returned by an operation that failed on the WBEM server. Instances holds the
+// CIM_Error instances embedded in the ERROR element, which are decoded by CIMErrors.
 type CIMErr struct {
-	ErrCode int
-	ErrName string
-	ErrDesc string
+	ErrCode   StatusCode
+	ErrName   string
+	ErrDesc   string
+	Instances []Instance
 }
 
-func errName(err int) string {
+// CIMError holds the diagnostics of a CIM_Error instance.
+type CIMError struct {
+	ErrorType                uint16
+	OtherErrorType           string
+	OwningEntity             string
+	MessageID                string
+	Message                  string
+	MessageArguments         []string
+	PerceivedSeverity        uint16
+	ProbableCause            uint16
+	ProbableCauseDescription string
+	RecommendedActions       []string
+	ErrorSource              string
+	ErrorSourceFormat        uint16
+	OtherErrorSourceFormat   string
+	CIMStatusCode            uint32
+	CIMStatusCodeDescription string
+}
+
+func errName(err StatusCode) string {
 	switch err {
 	case ErrFailed:
 		return "CIM_ERR_FAILED"
@@ -167,7 +252,7 @@ func errName(err int) string {
 	return "CIM_ERR_FAILED"
 }
 
-func errDesc(err int) string {
+func errDesc(err StatusCode) string {
 	switch err {
 	case ErrFailed:
 		return "A general error occurred"
@@ -226,11 +311,121 @@ func errDesc(err int) string {
 	return "A general error occurred"
 }
 
+func (code StatusCode) Error() string {
+	return fmt.Sprintf("%d - %s - %s", int(code), errName(code), errDesc(code))
+}
+
 func (err CIMErr) Error() string {
-	return fmt.Sprintf("%v - %v - %v", err.ErrCode, err.ErrName, err.ErrDesc)
+	return fmt.Sprintf("%d - %v - %v", int(err.ErrCode), err.ErrName, err.ErrDesc)
+}
+
+// Is reports whether target is the status code of err, or a CIMErr with the same status code.
+func (err CIMErr) Is(target error) bool {
+	switch target := target.(type) {
+	case StatusCode:
+		return target == err.ErrCode
+	case CIMErr:
+		return target.ErrCode == err.ErrCode
+	case *CIMErr:
+		return nil != target && target.ErrCode == err.ErrCode
+	}
+	return false
+}
+
+func propertyString(inst *Instance, name string) string {
+	for _, prop := range inst.Property {
+		if strings.EqualFold(name, prop.Name) && nil != prop.Value {
+			return prop.Value.Value
+		}
+	}
+	return ""
+}
+
+func propertyUint(inst *Instance, name string, bitSize int) uint64 {
+	i, _ := strconv.ParseUint(strings.TrimSpace(propertyString(inst, name)), 10, bitSize)
+	return i
+}
+
+func propertyStrings(inst *Instance, name string) []string {
+	var strs []string
+	for _, prop := range inst.PropertyArray {
+		if strings.EqualFold(name, prop.Name) && nil != prop.ValueArray {
+			for _, val := range prop.ValueArray.Value {
+				strs = append(strs, val.Value)
+			}
+		}
+	}
+	return strs
+}
+
+// CIMErrors decodes the CIM_Error instances returned with the error.
+func (err CIMErr) CIMErrors() []CIMError {
+	var errs []CIMError
+	for i := range err.Instances {
+		inst := &err.Instances[i]
+		errs = append(errs, CIMError{
+			ErrorType:                uint16(propertyUint(inst, "ErrorType", 16)),
+			OtherErrorType:           propertyString(inst, "OtherErrorType"),
+			OwningEntity:             propertyString(inst, "OwningEntity"),
+			MessageID:                propertyString(inst, "MessageID"),
+			Message:                  propertyString(inst, "Message"),
+			MessageArguments:         propertyStrings(inst, "MessageArguments"),
+			PerceivedSeverity:        uint16(propertyUint(inst, "PerceivedSeverity", 16)),
+			ProbableCause:            uint16(propertyUint(inst, "ProbableCause", 16)),
+			ProbableCauseDescription: propertyString(inst, "ProbableCauseDescription"),
+			RecommendedActions:       propertyStrings(inst, "RecommendedActions"),
+			ErrorSource:              propertyString(inst, "ErrorSource"),
+			ErrorSourceFormat:        uint16(propertyUint(inst, "ErrorSourceFormat", 16)),
+			OtherErrorSourceFormat:   propertyString(inst, "OtherErrorSourceFormat"),
+			CIMStatusCode:            uint32(propertyUint(inst, "CIMStatusCode", 32)),
+			CIMStatusCodeDescription: propertyString(inst, "CIMStatusCodeDescription"),
+		})
+	}
+	return errs
+}
+
+// The accessors below return the diagnostics of the first CIM_Error instance, if any.
+
+func (err CIMErr) MessageID() string {
+	if errs := err.CIMErrors(); 0 != len(errs) {
+		return errs[0].MessageID
+	}
+	return ""
+}
+
+func (err CIMErr) Message() string {
+	if errs := err.CIMErrors(); 0 != len(errs) {
+		return errs[0].Message
+	}
+	return ""
+}
+
+func (err CIMErr) ProbableCause() uint16 {
+	if errs := err.CIMErrors(); 0 != len(errs) {
+		return errs[0].ProbableCause
+	}
+	return 0
+}
+
+func (err CIMErr) ErrorSource() string {
+	if errs := err.CIMErrors(); 0 != len(errs) {
+		return errs[0].ErrorSource
+	}
+	return ""
 }
 
-func (conn *WBEMConnection) oops(err int, desc string) error {
+func (err CIMErr) RecommendedActions() []string {
+	if errs := err.CIMErrors(); 0 != len(errs) {
+		return errs[0].RecommendedActions
+	}
+	return nil
+}
+
+func (conn *WBEMConnection) oops(err StatusCode, desc string) error {
+	return newCIMErr(err, desc, nil)
+}
+
+func newCIMErr(err StatusCode, desc string, instances []Instance) CIMErr {
 	if 1 > err || 18 == err || 19 == err || 28 < err {
 		err = ErrFailed
 	}
@@ -238,8 +433,15 @@ func (conn *WBEMConnection) oops(err int, desc string) error {
 		desc = errDesc(err)
 	}
 	return CIMErr{
-		err,
-		errName(err),
-		desc,
+		ErrCode:   err,
+		ErrName:   errName(err),
+		ErrDesc:   desc,
+		Instances: instances,
 	}
 }
+
+// Converts the ERROR element of a response.
+func (conn *WBEMConnection) cimErr(err *Error) CIMErr {
+	i, _ := strconv.Atoi(strings.TrimSpace(err.Code))
+	return newCIMErr(StatusCode(i), err.Description, err.Instance)
+}
@@ -18,7 +18,10 @@
 package gowbem
 
 import (
+	"context"
+	"fmt"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -78,6 +81,10 @@ const (
 	// The CIMRoleAuthorization header is supplied along with the normal authorization header that the CIM client populates to perform user authentication. If the CIM client needs to perform role assumption and the server challenge is credentialrequired, the CIMRoleAuthorization header must be supplied with the appropriate credentials. The credentials supplied as part of the CIMRoleAuthorization header must use the same scheme as those specified for the authorization header, as specified in RFC 2617. Therefore, both Basic and Digest authentication are possible for the role credential.
 	HttpHdrRoleAuthorization = "CIMRoleAuthorization"
 
+	// The CIMStatusCode trailer may be present in a chunked CIM response message. A value other than 0 indicates that the CIM server failed while sending the response, and that the content received may be incomplete.
+	//      CIMStatusCode = "CIMStatusCode" ":" 1*DIGIT
+	HttpHdrStatusCode = "CIMStatusCode"
+
 	// If a CIM product includes the CIMStatusCode trailer, it may also include the CIMStatusCodeDescription trailer.
 	HttpHdrStatusCodeDescription = "CIMStatusCodeDescription"
 
@@ -115,6 +122,12 @@ type NamedInstance ValueNamedInstance
 // <instanceWithPath>: VALUE.INSTANCEWITHPATH
 type InstanceWithPath ValueInstanceWithPath
 
+// <enumerationContext>: the EnumerationContext and EndOfSequence output parameters of the pull operations
+type EnumerationContext struct {
+	EnumerationContext string
+	EndOfSequence      bool
+}
+
 // The GetClass operation returns a single CIM class from the target namespace:
 //      GetClass <class>GetClass (
 //           [IN] <className> ClassName,
@@ -124,6 +137,11 @@ type InstanceWithPath ValueInstanceWithPath
 //           [IN,OPTIONAL,NULL] string PropertyList [] = NULL
 //      )
 func (conn *WBEMConnection) GetClass(className *ClassName, localOnly bool, includeQualifiers bool, includeClassOrigin bool, propertyList []string) ([]Class, error) {
+	return conn.GetClassContext(context.Background(), className, localOnly, includeQualifiers, includeClassOrigin, propertyList)
+}
+
+// GetClassContext is the same as GetClass, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) GetClassContext(ctx context.Context, className *ClassName, localOnly bool, includeQualifiers bool, includeClassOrigin bool, propertyList []string) ([]Class, error) {
 	if nil == className {
 		return nil, conn.oops(ErrFailed, "")
 	}
@@ -142,13 +160,12 @@ func (conn *WBEMConnection) GetClass(className *ClassName, localOnly bool, inclu
 	if nil != propertyList {
 		iMethCall.appendParamVal("PropertyList", propertyList)
 	}
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return nil, err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return nil, conn.oops(i, iMethRes.Error.Description)
+		return nil, conn.cimErr(iMethRes.Error)
 	}
 	if nil == iMethRes.IReturnValue {
 		return nil, nil
@@ -165,6 +182,11 @@ func (conn *WBEMConnection) GetClass(className *ClassName, localOnly bool, inclu
 //           [IN,OPTIONAL,NULL] string PropertyList [] = NULL
 //      )
 func (conn *WBEMConnection) GetInstance(instanceName *InstanceName, includeClassOrigin bool, propertyList []string) ([]Instance, error) {
+	return conn.GetInstanceContext(context.Background(), instanceName, includeClassOrigin, propertyList)
+}
+
+// GetInstanceContext is the same as GetInstance, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) GetInstanceContext(ctx context.Context, instanceName *InstanceName, includeClassOrigin bool, propertyList []string) ([]Instance, error) {
 	if nil == instanceName {
 		return nil, conn.oops(ErrFailed, "")
 	}
@@ -177,13 +199,12 @@ func (conn *WBEMConnection) GetInstance(instanceName *InstanceName, includeClass
 	if nil != propertyList {
 		iMethCall.appendParamVal("PropertyList", propertyList)
 	}
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return nil, err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return nil, conn.oops(i, iMethRes.Error.Description)
+		return nil, conn.cimErr(iMethRes.Error)
 	}
 	if nil == iMethRes.IReturnValue {
 		return nil, nil
@@ -196,19 +217,23 @@ func (conn *WBEMConnection) GetInstance(instanceName *InstanceName, includeClass
 //           [IN] <className> ClassName
 //      )
 func (conn *WBEMConnection) DeleteClass(className *ClassName) error {
+	return conn.DeleteClassContext(context.Background(), className)
+}
+
+// DeleteClassContext is the same as DeleteClass, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) DeleteClassContext(ctx context.Context, className *ClassName) error {
 	if nil == className {
 		return conn.oops(ErrFailed, "")
 	}
 	iMethCall := newIMechCall("DeleteClass")
 	iMethCall.appendNamespace(conn.namespace)
 	iMethCall.appendParamVal("ClassName", className)
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return conn.oops(i, iMethRes.Error.Description)
+		return conn.cimErr(iMethRes.Error)
 	}
 	return nil
 }
@@ -218,19 +243,23 @@ func (conn *WBEMConnection) DeleteClass(className *ClassName) error {
 //           [IN] <instanceName> InstanceName
 //      )
 func (conn *WBEMConnection) DeleteInstance(instanceName *InstanceName) error {
+	return conn.DeleteInstanceContext(context.Background(), instanceName)
+}
+
+// DeleteInstanceContext is the same as DeleteInstance, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) DeleteInstanceContext(ctx context.Context, instanceName *InstanceName) error {
 	if nil == instanceName {
 		return conn.oops(ErrFailed, "")
 	}
 	iMethCall := newIMechCall("DeleteInstance")
 	iMethCall.appendNamespace(conn.namespace)
 	iMethCall.appendParamVal("InstanceName", instanceName)
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return conn.oops(i, iMethRes.Error.Description)
+		return conn.cimErr(iMethRes.Error)
 	}
 	return nil
 }
@@ -240,19 +269,23 @@ func (conn *WBEMConnection) DeleteInstance(instanceName *InstanceName) error {
 //           [IN] <class> NewClass
 //      )
 func (conn *WBEMConnection) CreateClass(newClass *Class) error {
+	return conn.CreateClassContext(context.Background(), newClass)
+}
+
+// CreateClassContext is the same as CreateClass, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) CreateClassContext(ctx context.Context, newClass *Class) error {
 	if nil == newClass {
 		return conn.oops(ErrFailed, "")
 	}
 	iMethCall := newIMechCall("CreateClass")
 	iMethCall.appendNamespace(conn.namespace)
 	iMethCall.appendParamVal("NewClass", newClass)
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return conn.oops(i, iMethRes.Error.Description)
+		return conn.cimErr(iMethRes.Error)
 	}
 	return nil
 }
@@ -262,21 +295,34 @@ func (conn *WBEMConnection) CreateClass(newClass *Class) error {
 //           [IN] <instance> NewInstance
 //      )
 func (conn *WBEMConnection) CreateInstance(newInstance *Instance) error {
+	return conn.CreateInstanceContext(context.Background(), newInstance)
+}
+
+// CreateInstanceContext is the same as CreateInstance, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) CreateInstanceContext(ctx context.Context, newInstance *Instance) error {
+	_, err := conn.createInstance(ctx, newInstance)
+	return err
+}
+
+// Creates an instance, and returns the instance name the server returns, nil if it returns none.
+func (conn *WBEMConnection) createInstance(ctx context.Context, newInstance *Instance) (*InstanceName, error) {
 	if nil == newInstance {
-		return conn.oops(ErrFailed, "")
+		return nil, conn.oops(ErrFailed, "")
 	}
 	iMethCall := newIMechCall("CreateInstance")
 	iMethCall.appendNamespace(conn.namespace)
 	iMethCall.appendParamVal("NewInstance", newInstance)
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
-		return err
+		return nil, err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return conn.oops(i, iMethRes.Error.Description)
+		return nil, conn.cimErr(iMethRes.Error)
 	}
-	return nil
+	if nil != iMethRes.IReturnValue && 0 != len(iMethRes.IReturnValue.InstanceName) {
+		return &iMethRes.IReturnValue.InstanceName[0], nil
+	}
+	return nil, nil
 }
 
 // The ModifyClass operation modifies an existing CIM class in the target namespace. The class shall already exist:
@@ -284,19 +330,23 @@ func (conn *WBEMConnection) CreateInstance(newInstance *Instance) error {
 //           [IN] <class> ModifiedClass
 //      )
 func (conn *WBEMConnection) ModifyClass(modifiedClass *Class) error {
+	return conn.ModifyClassContext(context.Background(), modifiedClass)
+}
+
+// ModifyClassContext is the same as ModifyClass, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) ModifyClassContext(ctx context.Context, modifiedClass *Class) error {
 	if nil == modifiedClass {
 		return conn.oops(ErrFailed, "")
 	}
 	iMethCall := newIMechCall("ModifyClass")
 	iMethCall.appendNamespace(conn.namespace)
 	iMethCall.appendParamVal("ModifiedClass", modifiedClass)
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return conn.oops(i, iMethRes.Error.Description)
+		return conn.cimErr(iMethRes.Error)
 	}
 	return nil
 }
@@ -308,19 +358,23 @@ func (conn *WBEMConnection) ModifyClass(modifiedClass *Class) error {
 //           [IN, OPTIONAL, NULL] string propertyList[] = NULL
 //      )
 func (conn *WBEMConnection) ModifyInstance(modifiedInstance *ValueNamedInstance, propertyList []string) error {
+	return conn.ModifyInstanceContext(context.Background(), modifiedInstance, propertyList)
+}
+
+// ModifyInstanceContext is the same as ModifyInstance, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) ModifyInstanceContext(ctx context.Context, modifiedInstance *ValueNamedInstance, propertyList []string) error {
 	iMethCall := newIMechCall("ModifyInstance")
 	iMethCall.appendNamespace(conn.namespace)
 	iMethCall.appendParamVal("ModifiedInstance", modifiedInstance)
 	if nil != propertyList {
 		iMethCall.appendParamVal("PropertyList", propertyList)
 	}
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return conn.oops(i, iMethRes.Error.Description)
+		return conn.cimErr(iMethRes.Error)
 	}
 	return nil
 }
@@ -334,6 +388,11 @@ func (conn *WBEMConnection) ModifyInstance(modifiedInstance *ValueNamedInstance,
 //           [IN,OPTIONAL] boolean IncludeClassOrigin = false
 //      )
 func (conn *WBEMConnection) EnumerateClasses(className *ClassName, deepInheritance bool, localOnly bool, includeQualifiers bool, includeClassOrigin bool) ([]Class, error) {
+	return conn.EnumerateClassesContext(context.Background(), className, deepInheritance, localOnly, includeQualifiers, includeClassOrigin)
+}
+
+// EnumerateClassesContext is the same as EnumerateClasses, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) EnumerateClassesContext(ctx context.Context, className *ClassName, deepInheritance bool, localOnly bool, includeQualifiers bool, includeClassOrigin bool) ([]Class, error) {
 	iMethCall := newIMechCall("EnumerateClasses")
 	iMethCall.appendNamespace(conn.namespace)
 	if nil != className {
@@ -351,13 +410,12 @@ func (conn *WBEMConnection) EnumerateClasses(className *ClassName, deepInheritan
 	if false != includeClassOrigin {
 		iMethCall.appendParamVal("IncludeClassOrigin", includeClassOrigin)
 	}
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return nil, err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return nil, conn.oops(i, iMethRes.Error.Description)
+		return nil, conn.cimErr(iMethRes.Error)
 	}
 	if nil == iMethRes.IReturnValue {
 		return nil, nil
@@ -371,6 +429,11 @@ func (conn *WBEMConnection) EnumerateClasses(className *ClassName, deepInheritan
 //           [IN,OPTIONAL] boolean DeepInheritance = false
 //      )
 func (conn *WBEMConnection) EnumerateClassNames(className *ClassName, deepInheritance bool) ([]Class, error) {
+	return conn.EnumerateClassNamesContext(context.Background(), className, deepInheritance)
+}
+
+// EnumerateClassNamesContext is the same as EnumerateClassNames, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) EnumerateClassNamesContext(ctx context.Context, className *ClassName, deepInheritance bool) ([]Class, error) {
 	iMethCall := newIMechCall("EnumerateInstances")
 	iMethCall.appendNamespace(conn.namespace)
 	if nil != className {
@@ -379,13 +442,12 @@ func (conn *WBEMConnection) EnumerateClassNames(className *ClassName, deepInheri
 	if false != deepInheritance {
 		iMethCall.appendParamVal("DeepInheritance", deepInheritance)
 	}
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return nil, err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return nil, conn.oops(i, iMethRes.Error.Description)
+		return nil, conn.cimErr(iMethRes.Error)
 	}
 	if nil == iMethRes.IReturnValue {
 		return nil, nil
@@ -403,6 +465,11 @@ func (conn *WBEMConnection) EnumerateClassNames(className *ClassName, deepInheri
 //           [IN,OPTIONAL,NULL] string PropertyList [] = NULL
 //      )
 func (conn *WBEMConnection) EnumerateInstances(className *ClassName, deepInheritance bool, includeClassOrigin bool, propertyList []string) ([]ValueNamedInstance, error) {
+	return conn.EnumerateInstancesContext(context.Background(), className, deepInheritance, includeClassOrigin, propertyList)
+}
+
+// EnumerateInstancesContext is the same as EnumerateInstances, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) EnumerateInstancesContext(ctx context.Context, className *ClassName, deepInheritance bool, includeClassOrigin bool, propertyList []string) ([]ValueNamedInstance, error) {
 	if nil == className {
 		return nil, conn.oops(ErrFailed, "")
 	}
@@ -418,13 +485,12 @@ func (conn *WBEMConnection) EnumerateInstances(className *ClassName, deepInherit
 	if nil != propertyList {
 		iMethCall.appendParamVal("PropertyList", propertyList)
 	}
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return nil, err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return nil, conn.oops(i, iMethRes.Error.Description)
+		return nil, conn.cimErr(iMethRes.Error)
 	}
 	if nil == iMethRes.IReturnValue {
 		return nil, nil
@@ -437,19 +503,23 @@ func (conn *WBEMConnection) EnumerateInstances(className *ClassName, deepInherit
 //           [IN] <className> ClassName
 //      )
 func (conn *WBEMConnection) EnumerateInstanceNames(className *ClassName) ([]InstanceName, error) {
+	return conn.EnumerateInstanceNamesContext(context.Background(), className)
+}
+
+// EnumerateInstanceNamesContext is the same as EnumerateInstanceNames, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) EnumerateInstanceNamesContext(ctx context.Context, className *ClassName) ([]InstanceName, error) {
 	if nil == className {
 		return nil, conn.oops(ErrFailed, "")
 	}
 	iMethCall := newIMechCall("EnumerateInstanceNames")
 	iMethCall.appendNamespace(conn.namespace)
 	iMethCall.appendParamVal("ClassName", className)
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return nil, err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return nil, conn.oops(i, iMethRes.Error.Description)
+		return nil, conn.cimErr(iMethRes.Error)
 	}
 	if nil == iMethRes.IReturnValue {
 		return nil, nil
@@ -463,17 +533,21 @@ func (conn *WBEMConnection) EnumerateInstanceNames(className *ClassName) ([]Inst
 //           [IN] string Query
 //      )
 func (conn *WBEMConnection) ExecQuery(queryLanguage string, query string) (*Object, error) {
+	return conn.ExecQueryContext(context.Background(), queryLanguage, query)
+}
+
+// ExecQueryContext is the same as ExecQuery, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) ExecQueryContext(ctx context.Context, queryLanguage string, query string) (*Object, error) {
 	iMethCall := newIMechCall("ExecQuery")
 	iMethCall.appendNamespace(conn.namespace)
 	iMethCall.appendParamVal("QueryLanguage", queryLanguage)
 	iMethCall.appendParamVal("Query", query)
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return nil, err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return nil, conn.oops(i, iMethRes.Error.Description)
+		return nil, conn.cimErr(iMethRes.Error)
 	}
 	if nil == iMethRes.IReturnValue {
 		return nil, nil
@@ -498,6 +572,11 @@ func (conn *WBEMConnection) ExecQuery(queryLanguage string, query string) (*Obje
 //           [IN,OPTIONAL,NULL] string PropertyList [] = NULL
 //      )
 func (conn *WBEMConnection) Associators(objectName *ObjectName, assocClass *ClassName, resultClass *ClassName, role, resultRole *string, includeClassOrigin bool, propertyList []string) ([]ValueObjectWithPath, error) {
+	return conn.AssociatorsContext(context.Background(), objectName, assocClass, resultClass, role, resultRole, includeClassOrigin, propertyList)
+}
+
+// AssociatorsContext is the same as Associators, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) AssociatorsContext(ctx context.Context, objectName *ObjectName, assocClass *ClassName, resultClass *ClassName, role, resultRole *string, includeClassOrigin bool, propertyList []string) ([]ValueObjectWithPath, error) {
 	iMethCall := newIMechCall("Associators")
 	iMethCall.appendNamespace(conn.namespace)
 	if nil != objectName.ClassName {
@@ -523,13 +602,12 @@ func (conn *WBEMConnection) Associators(objectName *ObjectName, assocClass *Clas
 	if nil != propertyList {
 		iMethCall.appendParamVal("PropertyList", propertyList)
 	}
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return nil, err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return nil, conn.oops(i, iMethRes.Error.Description)
+		return nil, conn.cimErr(iMethRes.Error)
 	}
 	if nil == iMethRes.IReturnValue {
 		return nil, nil
@@ -546,6 +624,11 @@ func (conn *WBEMConnection) Associators(objectName *ObjectName, assocClass *Clas
 //           [IN,OPTIONAL,NULL] string ResultRole = NULL
 //      )
 func (conn *WBEMConnection) AssociatorNames(objectName *ObjectName, assocClass *ClassName, resultClass *ClassName, role, resultRole *string) ([]ObjectPath, error) {
+	return conn.AssociatorNamesContext(context.Background(), objectName, assocClass, resultClass, role, resultRole)
+}
+
+// AssociatorNamesContext is the same as AssociatorNames, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) AssociatorNamesContext(ctx context.Context, objectName *ObjectName, assocClass *ClassName, resultClass *ClassName, role, resultRole *string) ([]ObjectPath, error) {
 	iMethCall := newIMechCall("AssociatorNames")
 	iMethCall.appendNamespace(conn.namespace)
 	if nil != objectName.ClassName {
@@ -565,13 +648,12 @@ func (conn *WBEMConnection) AssociatorNames(objectName *ObjectName, assocClass *
 	if nil != resultRole {
 		iMethCall.appendParamVal("ResultRole", *resultRole)
 	}
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return nil, err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return nil, conn.oops(i, iMethRes.Error.Description)
+		return nil, conn.cimErr(iMethRes.Error)
 	}
 	if nil == iMethRes.IReturnValue {
 		return nil, nil
@@ -589,6 +671,11 @@ func (conn *WBEMConnection) AssociatorNames(objectName *ObjectName, assocClass *
 //           [IN,OPTIONAL,NULL] string PropertyList [] = NULL
 //      )
 func (conn *WBEMConnection) References(objectName *ObjectName, resultClass *ClassName, role *string, includeClassOrigin bool, propertyList []string) ([]ValueObjectWithPath, error) {
+	return conn.ReferencesContext(context.Background(), objectName, resultClass, role, includeClassOrigin, propertyList)
+}
+
+// ReferencesContext is the same as References, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) ReferencesContext(ctx context.Context, objectName *ObjectName, resultClass *ClassName, role *string, includeClassOrigin bool, propertyList []string) ([]ValueObjectWithPath, error) {
 	iMethCall := newIMechCall("References")
 	iMethCall.appendNamespace(conn.namespace)
 	if nil != objectName.ClassName {
@@ -608,13 +695,12 @@ func (conn *WBEMConnection) References(objectName *ObjectName, resultClass *Clas
 	if nil != propertyList {
 		iMethCall.appendParamVal("PropertyList", propertyList)
 	}
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return nil, err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return nil, conn.oops(i, iMethRes.Error.Description)
+		return nil, conn.cimErr(iMethRes.Error)
 	}
 	if nil == iMethRes.IReturnValue {
 		return nil, nil
@@ -629,6 +715,11 @@ func (conn *WBEMConnection) References(objectName *ObjectName, resultClass *Clas
 //           [IN,OPTIONAL,NULL] string Role = NULL
 //      )
 func (conn *WBEMConnection) ReferenceNames(objectName *ObjectName, assocClass *ClassName, role *string) ([]ObjectPath, error) {
+	return conn.ReferenceNamesContext(context.Background(), objectName, assocClass, role)
+}
+
+// ReferenceNamesContext is the same as ReferenceNames, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) ReferenceNamesContext(ctx context.Context, objectName *ObjectName, assocClass *ClassName, role *string) ([]ObjectPath, error) {
 	iMethCall := newIMechCall("ReferenceNames")
 	iMethCall.appendNamespace(conn.namespace)
 	if nil != objectName.ClassName {
@@ -642,13 +733,12 @@ func (conn *WBEMConnection) ReferenceNames(objectName *ObjectName, assocClass *C
 	if nil != role {
 		iMethCall.appendParamVal("Role", *role)
 	}
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return nil, err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return nil, conn.oops(i, iMethRes.Error.Description)
+		return nil, conn.cimErr(iMethRes.Error)
 	}
 	if nil == iMethRes.IReturnValue {
 		return nil, nil
@@ -663,6 +753,49 @@ func (conn *WBEMConnection) ReferenceNames(objectName *ObjectName, assocClass *C
 //           [IN] <paramValue> ParamValue
 //      )
 func (conn *WBEMConnection) InvokeMethod(objectName *ObjectName, methodName string, paramValue []ParamValue) (int, []ParamValue, error) {
+	return conn.InvokeMethodContext(context.Background(), objectName, methodName, paramValue)
+}
+
+// InvokeMethodContext is the same as InvokeMethod, with ctx carried to the HTTP request.
+// The returned int is the return value when it is an integer that fits in an int, and -1 otherwise;
+// use InvokeMethodValueContext for the typed return value.
+func (conn *WBEMConnection) InvokeMethodContext(ctx context.Context, objectName *ObjectName, methodName string, paramValue []ParamValue) (int, []ParamValue, error) {
+	methRes, err := conn.invokeMethod(ctx, objectName, methodName, paramValue)
+	if nil != err {
+		if cimErr, ok := err.(CIMErr); ok && nil != methRes {
+			return int(cimErr.ErrCode), nil, err
+		}
+		return -1, nil, err
+	}
+	retCode := -1
+	if retVal, err := methRes.ReturnValue.CIMValue(); nil == err {
+		retCode = retVal.returnCode()
+	}
+	return retCode, methRes.ParamValue, nil
+}
+
+// InvokeMethodValue is the same as InvokeMethod, but returns the typed return value of the method,
+// which may be of any CIM type, including a string, a boolean or a reference.
+func (conn *WBEMConnection) InvokeMethodValue(objectName *ObjectName, methodName string, paramValue []ParamValue) (*CIMValue, []ParamValue, error) {
+	return conn.InvokeMethodValueContext(context.Background(), objectName, methodName, paramValue)
+}
+
+// InvokeMethodValueContext is the same as InvokeMethodValue, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) InvokeMethodValueContext(ctx context.Context, objectName *ObjectName, methodName string, paramValue []ParamValue) (*CIMValue, []ParamValue, error) {
+	methRes, err := conn.invokeMethod(ctx, objectName, methodName, paramValue)
+	if nil != err {
+		return nil, nil, err
+	}
+	retVal, err := methRes.ReturnValue.CIMValue()
+	if nil != err {
+		return nil, methRes.ParamValue, err
+	}
+	return retVal, methRes.ParamValue, nil
+}
+
+// invokeMethod sends the method call and returns the response, which has a RETURNVALUE unless err is set.
+// When the server answers with an ERROR, both the response and the CIMErr are returned.
+func (conn *WBEMConnection) invokeMethod(ctx context.Context, objectName *ObjectName, methodName string, paramValue []ParamValue) (*MethodResponse, error) {
 	methCall := newMechCall(methodName)
 	if nil != objectName.ClassName {
 		methCall.appendLocalClassPath(conn.namespace, objectName.ClassName)
@@ -670,19 +803,17 @@ func (conn *WBEMConnection) InvokeMethod(objectName *ObjectName, methodName stri
 		methCall.appendLocalInstancePath(conn.namespace, objectName.InstanceName)
 	}
 	methCall.ParamValue = paramValue
-	methRes, err := conn.methodCall(methCall)
+	methRes, err := conn.methodCall(ctx, methCall)
 	if nil != err {
-		return -1, nil, err
+		return nil, err
 	}
 	if nil != methRes.Error {
-		i, _ := strconv.Atoi(methRes.Error.Code)
-		return i, nil, conn.oops(i, methRes.Error.Description)
+		return methRes, conn.cimErr(methRes.Error)
 	}
 	if nil == methRes.ReturnValue {
-		return -1, nil, conn.oops(ErrFailed, "")
+		return nil, conn.oops(ErrFailed, "")
 	}
-	retCode, _ := strconv.Atoi(methRes.ReturnValue.Value.Value)
-	return retCode, methRes.ParamValue, nil
+	return methRes, nil
 }
 
 // The GetProperty operation retrieves a single property value from a CIM instance in the target namespace:
@@ -691,17 +822,21 @@ func (conn *WBEMConnection) InvokeMethod(objectName *ObjectName, methodName stri
 //           [IN] string PropertyName
 //      )
 func (conn *WBEMConnection) GetProperty(instanceName *InstanceName, propertyName string) (*PropertyValue, error) {
+	return conn.GetPropertyContext(context.Background(), instanceName, propertyName)
+}
+
+// GetPropertyContext is the same as GetProperty, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) GetPropertyContext(ctx context.Context, instanceName *InstanceName, propertyName string) (*PropertyValue, error) {
 	iMethCall := newIMechCall("GetProperty")
 	iMethCall.appendNamespace(conn.namespace)
 	iMethCall.appendParamVal("InstanceName", instanceName)
 	iMethCall.appendParamVal("PropertyName", propertyName)
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return nil, err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return nil, conn.oops(i, iMethRes.Error.Description)
+		return nil, conn.cimErr(iMethRes.Error)
 	}
 	if nil == iMethRes.IReturnValue {
 		return nil, nil
@@ -723,6 +858,11 @@ func (conn *WBEMConnection) GetProperty(instanceName *InstanceName, propertyName
 //           [IN,OPTIONAL,NULL] <propertyValue> NewValue = NULL
 //      )
 func (conn *WBEMConnection) SetProperty(instanceName *InstanceName, propertyName string, newValue *PropertyValue) error {
+	return conn.SetPropertyContext(context.Background(), instanceName, propertyName, newValue)
+}
+
+// SetPropertyContext is the same as SetProperty, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) SetPropertyContext(ctx context.Context, instanceName *InstanceName, propertyName string, newValue *PropertyValue) error {
 	iMethCall := newIMechCall("SetProperty")
 	iMethCall.appendNamespace(conn.namespace)
 	iMethCall.appendParamVal("InstanceName", instanceName)
@@ -730,13 +870,12 @@ func (conn *WBEMConnection) SetProperty(instanceName *InstanceName, propertyName
 	if nil != newValue {
 		iMethCall.appendParamVal("NewValue", newValue)
 	}
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return conn.oops(i, iMethRes.Error.Description)
+		return conn.cimErr(iMethRes.Error)
 	}
 	return nil
 }
@@ -746,16 +885,20 @@ func (conn *WBEMConnection) SetProperty(instanceName *InstanceName, propertyName
 //           [IN] string QualifierName
 //      )
 func (conn *WBEMConnection) GetQualifier(qualifierName string) (*QualifierDeclaration, error) {
+	return conn.GetQualifierContext(context.Background(), qualifierName)
+}
+
+// GetQualifierContext is the same as GetQualifier, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) GetQualifierContext(ctx context.Context, qualifierName string) (*QualifierDeclaration, error) {
 	iMethCall := newIMechCall("GetQualifier")
 	iMethCall.appendNamespace(conn.namespace)
 	iMethCall.appendParamVal("QualifierName", qualifierName)
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return nil, err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return nil, conn.oops(i, iMethRes.Error.Description)
+		return nil, conn.cimErr(iMethRes.Error)
 	}
 	if nil == iMethRes.IReturnValue {
 		return nil, nil
@@ -771,16 +914,20 @@ func (conn *WBEMConnection) GetQualifier(qualifierName string) (*QualifierDeclar
 //           [IN] <qualifierDecl> QualifierDeclaration
 //      )
 func (conn *WBEMConnection) SetQualifier(qualifierDecl *QualifierDeclaration) error {
+	return conn.SetQualifierContext(context.Background(), qualifierDecl)
+}
+
+// SetQualifierContext is the same as SetQualifier, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) SetQualifierContext(ctx context.Context, qualifierDecl *QualifierDeclaration) error {
 	iMethCall := newIMechCall("SetQualifier")
 	iMethCall.appendNamespace(conn.namespace)
 	iMethCall.appendParamVal("QualifierDeclaration", qualifierDecl)
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return conn.oops(i, iMethRes.Error.Description)
+		return conn.cimErr(iMethRes.Error)
 	}
 	return nil
 }
@@ -790,16 +937,20 @@ func (conn *WBEMConnection) SetQualifier(qualifierDecl *QualifierDeclaration) er
 //           [IN] string QualifierName
 //      )
 func (conn *WBEMConnection) DeleteQualifier(qualifierName string) error {
+	return conn.DeleteQualifierContext(context.Background(), qualifierName)
+}
+
+// DeleteQualifierContext is the same as DeleteQualifier, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) DeleteQualifierContext(ctx context.Context, qualifierName string) error {
 	iMethCall := newIMechCall("DeleteQualifier")
 	iMethCall.appendNamespace(conn.namespace)
 	iMethCall.appendParamVal("QualifierName", qualifierName)
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return conn.oops(i, iMethRes.Error.Description)
+		return conn.cimErr(iMethRes.Error)
 	}
 	return nil
 }
@@ -808,18 +959,532 @@ func (conn *WBEMConnection) DeleteQualifier(qualifierName string) error {
 //      <qualifierDecl>*EnumerateQualifiers (
 //      )
 func (conn *WBEMConnection) EnumerateQualifiers() ([]QualifierDeclaration, error) {
+	return conn.EnumerateQualifiersContext(context.Background())
+}
+
+// EnumerateQualifiersContext is the same as EnumerateQualifiers, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) EnumerateQualifiersContext(ctx context.Context) ([]QualifierDeclaration, error) {
 	iMethCall := newIMechCall("EnumerateQualifiers")
 	iMethCall.appendNamespace(conn.namespace)
-	iMethRes, err := conn.iMethodCall(iMethCall)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
 	if nil != err {
 		return nil, err
 	}
 	if nil != iMethRes.Error {
-		i, _ := strconv.Atoi(iMethRes.Error.Code)
-		return nil, conn.oops(i, iMethRes.Error.Description)
+		return nil, conn.cimErr(iMethRes.Error)
 	}
 	if nil == iMethRes.IReturnValue {
 		return nil, nil
 	}
 	return iMethRes.IReturnValue.QualifierDeclaration, nil
 }
+
+func (iMethRes *IMethodResponse) enumerationContext() (*EnumerationContext, error) {
+	var enumCtx EnumerationContext
+	for _, paramVal := range iMethRes.ParamValue {
+		if nil == paramVal.Value {
+			continue
+		}
+		switch paramVal.Name {
+		case "EnumerationContext":
+			enumCtx.EnumerationContext = paramVal.Value.Value
+		case "EndOfSequence":
+			b, err := strconv.ParseBool(strings.TrimSpace(paramVal.Value.Value))
+			if nil != err {
+				return nil, err
+			}
+			enumCtx.EndOfSequence = b
+		}
+	}
+	if false == enumCtx.EndOfSequence && "" == enumCtx.EnumerationContext {
+		return nil, fmt.Errorf("missing EnumerationContext in %s response", iMethRes.Name)
+	}
+	return &enumCtx, nil
+}
+
+// The OpenEnumerateInstances operation establishes and opens an enumeration session of the instances of a CIM class (including instances of its subclasses) in the target namespace. Optionally, it retrieves a first set of instances:
+//      <instanceWithPath>*OpenEnumerateInstances (
+//           [OUT] <enumerationContext> EnumerationContext,
+//           [OUT] Boolean EndOfSequence,
+//           [IN] <className> ClassName,
+//           [IN,OPTIONAL] boolean DeepInheritance = true,
+//           [IN,OPTIONAL] boolean IncludeClassOrigin = false,
+//           [IN,OPTIONAL,NULL] string PropertyList [] = NULL,
+//           [IN,OPTIONAL,NULL] string FilterQueryLanguage = NULL,
+//           [IN,OPTIONAL,NULL] string FilterQuery = NULL,
+//           [IN,OPTIONAL,NULL] uint32 OperationTimeout = NULL,
+//           [IN,OPTIONAL] Boolean ContinueOnError = false,
+//           [IN,OPTIONAL] uint32 MaxObjectCount = 0
+//      )
+func (conn *WBEMConnection) OpenEnumerateInstances(className *ClassName, deepInheritance bool, includeClassOrigin bool, propertyList []string, filterQueryLanguage, filterQuery *string, operationTimeout *uint32, continueOnError bool, maxObjectCount uint32) ([]ValueInstanceWithPath, *EnumerationContext, error) {
+	return conn.OpenEnumerateInstancesContext(context.Background(), className, deepInheritance, includeClassOrigin, propertyList, filterQueryLanguage, filterQuery, operationTimeout, continueOnError, maxObjectCount)
+}
+
+// OpenEnumerateInstancesContext is the same as OpenEnumerateInstances, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) OpenEnumerateInstancesContext(ctx context.Context, className *ClassName, deepInheritance bool, includeClassOrigin bool, propertyList []string, filterQueryLanguage, filterQuery *string, operationTimeout *uint32, continueOnError bool, maxObjectCount uint32) ([]ValueInstanceWithPath, *EnumerationContext, error) {
+	if nil == className {
+		return nil, nil, conn.oops(ErrFailed, "")
+	}
+	iMethCall := newIMechCall("OpenEnumerateInstances")
+	iMethCall.appendNamespace(conn.namespace)
+	iMethCall.appendParamVal("ClassName", className)
+	if true != deepInheritance {
+		iMethCall.appendParamVal("DeepInheritance", deepInheritance)
+	}
+	if false != includeClassOrigin {
+		iMethCall.appendParamVal("IncludeClassOrigin", includeClassOrigin)
+	}
+	if nil != propertyList {
+		iMethCall.appendParamVal("PropertyList", propertyList)
+	}
+	if nil != filterQueryLanguage {
+		iMethCall.appendParamVal("FilterQueryLanguage", *filterQueryLanguage)
+	}
+	if nil != filterQuery {
+		iMethCall.appendParamVal("FilterQuery", *filterQuery)
+	}
+	if nil != operationTimeout {
+		iMethCall.appendParamVal("OperationTimeout", *operationTimeout)
+	}
+	if false != continueOnError {
+		iMethCall.appendParamVal("ContinueOnError", continueOnError)
+	}
+	if 0 != maxObjectCount {
+		iMethCall.appendParamVal("MaxObjectCount", maxObjectCount)
+	}
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
+	if nil != err {
+		return nil, nil, err
+	}
+	if nil != iMethRes.Error {
+		return nil, nil, conn.cimErr(iMethRes.Error)
+	}
+	enumCtx, err := iMethRes.enumerationContext()
+	if nil != err {
+		return nil, nil, err
+	}
+	if nil == iMethRes.IReturnValue {
+		return nil, enumCtx, nil
+	}
+	return iMethRes.IReturnValue.ValueInstanceWithPath, enumCtx, nil
+}
+
+// The OpenEnumerateInstancePaths operation establishes and opens an enumeration session of the instance paths of the instances of a CIM class (including instances of its subclasses) in the target namespace. Optionally, it retrieves a first set of instance paths:
+//      <instancePath>*OpenEnumerateInstancePaths (
+//           [OUT] <enumerationContext> EnumerationContext,
+//           [OUT] Boolean EndOfSequence,
+//           [IN] <className> ClassName,
+//           [IN,OPTIONAL,NULL] string FilterQueryLanguage = NULL,
+//           [IN,OPTIONAL,NULL] string FilterQuery = NULL,
+//           [IN,OPTIONAL,NULL] uint32 OperationTimeout = NULL,
+//           [IN,OPTIONAL] Boolean ContinueOnError = false,
+//           [IN,OPTIONAL] uint32 MaxObjectCount = 0
+//      )
+func (conn *WBEMConnection) OpenEnumerateInstancePaths(className *ClassName, filterQueryLanguage, filterQuery *string, operationTimeout *uint32, continueOnError bool, maxObjectCount uint32) ([]InstancePath, *EnumerationContext, error) {
+	return conn.OpenEnumerateInstancePathsContext(context.Background(), className, filterQueryLanguage, filterQuery, operationTimeout, continueOnError, maxObjectCount)
+}
+
+// OpenEnumerateInstancePathsContext is the same as OpenEnumerateInstancePaths, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) OpenEnumerateInstancePathsContext(ctx context.Context, className *ClassName, filterQueryLanguage, filterQuery *string, operationTimeout *uint32, continueOnError bool, maxObjectCount uint32) ([]InstancePath, *EnumerationContext, error) {
+	if nil == className {
+		return nil, nil, conn.oops(ErrFailed, "")
+	}
+	iMethCall := newIMechCall("OpenEnumerateInstancePaths")
+	iMethCall.appendNamespace(conn.namespace)
+	iMethCall.appendParamVal("ClassName", className)
+	if nil != filterQueryLanguage {
+		iMethCall.appendParamVal("FilterQueryLanguage", *filterQueryLanguage)
+	}
+	if nil != filterQuery {
+		iMethCall.appendParamVal("FilterQuery", *filterQuery)
+	}
+	if nil != operationTimeout {
+		iMethCall.appendParamVal("OperationTimeout", *operationTimeout)
+	}
+	if false != continueOnError {
+		iMethCall.appendParamVal("ContinueOnError", continueOnError)
+	}
+	if 0 != maxObjectCount {
+		iMethCall.appendParamVal("MaxObjectCount", maxObjectCount)
+	}
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
+	if nil != err {
+		return nil, nil, err
+	}
+	if nil != iMethRes.Error {
+		return nil, nil, conn.cimErr(iMethRes.Error)
+	}
+	enumCtx, err := iMethRes.enumerationContext()
+	if nil != err {
+		return nil, nil, err
+	}
+	if nil == iMethRes.IReturnValue {
+		return nil, enumCtx, nil
+	}
+	return iMethRes.IReturnValue.InstancePath, enumCtx, nil
+}
+
+// The OpenAssociatorInstances operation establishes and opens an enumeration session of the instances associated with a source instance. Optionally, it retrieves a first set of instances:
+//      <instanceWithPath>*OpenAssociatorInstances (
+//           [OUT] <enumerationContext> EnumerationContext,
+//           [OUT] Boolean EndOfSequence,
+//           [IN] <instanceName> InstanceName,
+//           [IN,OPTIONAL,NULL] <className> AssocClass = NULL,
+//           [IN,OPTIONAL,NULL] <className> ResultClass = NULL,
+//           [IN,OPTIONAL,NULL] string Role = NULL,
+//           [IN,OPTIONAL,NULL] string ResultRole = NULL,
+//           [IN,OPTIONAL] boolean IncludeClassOrigin = false,
+//           [IN,OPTIONAL,NULL] string PropertyList [] = NULL,
+//           [IN,OPTIONAL,NULL] string FilterQueryLanguage = NULL,
+//           [IN,OPTIONAL,NULL] string FilterQuery = NULL,
+//           [IN,OPTIONAL,NULL] uint32 OperationTimeout = NULL,
+//           [IN,OPTIONAL] Boolean ContinueOnError = false,
+//           [IN,OPTIONAL] uint32 MaxObjectCount = 0
+//      )
+func (conn *WBEMConnection) OpenAssociatorInstances(instanceName *InstanceName, assocClass *ClassName, resultClass *ClassName, role, resultRole *string, includeClassOrigin bool, propertyList []string, filterQueryLanguage, filterQuery *string, operationTimeout *uint32, continueOnError bool, maxObjectCount uint32) ([]ValueInstanceWithPath, *EnumerationContext, error) {
+	return conn.OpenAssociatorInstancesContext(context.Background(), instanceName, assocClass, resultClass, role, resultRole, includeClassOrigin, propertyList, filterQueryLanguage, filterQuery, operationTimeout, continueOnError, maxObjectCount)
+}
+
+// OpenAssociatorInstancesContext is the same as OpenAssociatorInstances, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) OpenAssociatorInstancesContext(ctx context.Context, instanceName *InstanceName, assocClass *ClassName, resultClass *ClassName, role, resultRole *string, includeClassOrigin bool, propertyList []string, filterQueryLanguage, filterQuery *string, operationTimeout *uint32, continueOnError bool, maxObjectCount uint32) ([]ValueInstanceWithPath, *EnumerationContext, error) {
+	if nil == instanceName {
+		return nil, nil, conn.oops(ErrFailed, "")
+	}
+	iMethCall := newIMechCall("OpenAssociatorInstances")
+	iMethCall.appendNamespace(conn.namespace)
+	iMethCall.appendParamVal("InstanceName", instanceName)
+	if nil != assocClass {
+		iMethCall.appendParamVal("AssocClass", assocClass)
+	}
+	if nil != resultClass {
+		iMethCall.appendParamVal("ResultClass", resultClass)
+	}
+	if nil != role {
+		iMethCall.appendParamVal("Role", *role)
+	}
+	if nil != resultRole {
+		iMethCall.appendParamVal("ResultRole", *resultRole)
+	}
+	if false != includeClassOrigin {
+		iMethCall.appendParamVal("IncludeClassOrigin", includeClassOrigin)
+	}
+	if nil != propertyList {
+		iMethCall.appendParamVal("PropertyList", propertyList)
+	}
+	if nil != filterQueryLanguage {
+		iMethCall.appendParamVal("FilterQueryLanguage", *filterQueryLanguage)
+	}
+	if nil != filterQuery {
+		iMethCall.appendParamVal("FilterQuery", *filterQuery)
+	}
+	if nil != operationTimeout {
+		iMethCall.appendParamVal("OperationTimeout", *operationTimeout)
+	}
+	if false != continueOnError {
+		iMethCall.appendParamVal("ContinueOnError", continueOnError)
+	}
+	if 0 != maxObjectCount {
+		iMethCall.appendParamVal("MaxObjectCount", maxObjectCount)
+	}
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
+	if nil != err {
+		return nil, nil, err
+	}
+	if nil != iMethRes.Error {
+		return nil, nil, conn.cimErr(iMethRes.Error)
+	}
+	enumCtx, err := iMethRes.enumerationContext()
+	if nil != err {
+		return nil, nil, err
+	}
+	if nil == iMethRes.IReturnValue {
+		return nil, enumCtx, nil
+	}
+	return iMethRes.IReturnValue.ValueInstanceWithPath, enumCtx, nil
+}
+
+// The OpenReferenceInstances operation establishes and opens an enumeration session of the association instances that refer to a source instance. Optionally, it retrieves a first set of instances:
+//      <instanceWithPath>*OpenReferenceInstances (
+//           [OUT] <enumerationContext> EnumerationContext,
+//           [OUT] Boolean EndOfSequence,
+//           [IN] <instanceName> InstanceName,
+//           [IN,OPTIONAL,NULL] <className> ResultClass = NULL,
+//           [IN,OPTIONAL,NULL] string Role = NULL,
+//           [IN,OPTIONAL] boolean IncludeClassOrigin = false,
+//           [IN,OPTIONAL,NULL] string PropertyList [] = NULL,
+//           [IN,OPTIONAL,NULL] string FilterQueryLanguage = NULL,
+//           [IN,OPTIONAL,NULL] string FilterQuery = NULL,
+//           [IN,OPTIONAL,NULL] uint32 OperationTimeout = NULL,
+//           [IN,OPTIONAL] Boolean ContinueOnError = false,
+//           [IN,OPTIONAL] uint32 MaxObjectCount = 0
+//      )
+func (conn *WBEMConnection) OpenReferenceInstances(instanceName *InstanceName, resultClass *ClassName, role *string, includeClassOrigin bool, propertyList []string, filterQueryLanguage, filterQuery *string, operationTimeout *uint32, continueOnError bool, maxObjectCount uint32) ([]ValueInstanceWithPath, *EnumerationContext, error) {
+	return conn.OpenReferenceInstancesContext(context.Background(), instanceName, resultClass, role, includeClassOrigin, propertyList, filterQueryLanguage, filterQuery, operationTimeout, continueOnError, maxObjectCount)
+}
+
+// OpenReferenceInstancesContext is the same as OpenReferenceInstances, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) OpenReferenceInstancesContext(ctx context.Context, instanceName *InstanceName, resultClass *ClassName, role *string, includeClassOrigin bool, propertyList []string, filterQueryLanguage, filterQuery *string, operationTimeout *uint32, continueOnError bool, maxObjectCount uint32) ([]ValueInstanceWithPath, *EnumerationContext, error) {
+	if nil == instanceName {
+		return nil, nil, conn.oops(ErrFailed, "")
+	}
+	iMethCall := newIMechCall("OpenReferenceInstances")
+	iMethCall.appendNamespace(conn.namespace)
+	iMethCall.appendParamVal("InstanceName", instanceName)
+	if nil != resultClass {
+		iMethCall.appendParamVal("ResultClass", resultClass)
+	}
+	if nil != role {
+		iMethCall.appendParamVal("Role", *role)
+	}
+	if false != includeClassOrigin {
+		iMethCall.appendParamVal("IncludeClassOrigin", includeClassOrigin)
+	}
+	if nil != propertyList {
+		iMethCall.appendParamVal("PropertyList", propertyList)
+	}
+	if nil != filterQueryLanguage {
+		iMethCall.appendParamVal("FilterQueryLanguage", *filterQueryLanguage)
+	}
+	if nil != filterQuery {
+		iMethCall.appendParamVal("FilterQuery", *filterQuery)
+	}
+	if nil != operationTimeout {
+		iMethCall.appendParamVal("OperationTimeout", *operationTimeout)
+	}
+	if false != continueOnError {
+		iMethCall.appendParamVal("ContinueOnError", continueOnError)
+	}
+	if 0 != maxObjectCount {
+		iMethCall.appendParamVal("MaxObjectCount", maxObjectCount)
+	}
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
+	if nil != err {
+		return nil, nil, err
+	}
+	if nil != iMethRes.Error {
+		return nil, nil, conn.cimErr(iMethRes.Error)
+	}
+	enumCtx, err := iMethRes.enumerationContext()
+	if nil != err {
+		return nil, nil, err
+	}
+	if nil == iMethRes.IReturnValue {
+		return nil, enumCtx, nil
+	}
+	return iMethRes.IReturnValue.ValueInstanceWithPath, enumCtx, nil
+}
+
+// The OpenQueryInstances operation establishes and opens an enumeration session of the instances of a CIM class (including instances of its subclasses) in the target namespace that match a query. Optionally, it retrieves a first set of instances:
+//      <instance>*OpenQueryInstances (
+//           [IN] string FilterQuery,
+//           [IN] string FilterQueryLanguage,
+//           [IN,OPTIONAL] Boolean ReturnQueryResultClass = false,
+//           [IN,OPTIONAL,NULL] uint32 OperationTimeout = NULL,
+//           [IN,OPTIONAL] Boolean ContinueOnError = false,
+//           [IN,OPTIONAL] uint32 MaxObjectCount = 0,
+//           [OUT,OPTIONAL,NULL] <class> QueryResultClass,
+//           [OUT] <enumerationContext> EnumerationContext,
+//           [OUT] Boolean EndOfSequence
+//      )
+func (conn *WBEMConnection) OpenQueryInstances(filterQueryLanguage string, filterQuery string, operationTimeout *uint32, continueOnError bool, maxObjectCount uint32) ([]Instance, *EnumerationContext, error) {
+	return conn.OpenQueryInstancesContext(context.Background(), filterQueryLanguage, filterQuery, operationTimeout, continueOnError, maxObjectCount)
+}
+
+// OpenQueryInstancesContext is the same as OpenQueryInstances, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) OpenQueryInstancesContext(ctx context.Context, filterQueryLanguage string, filterQuery string, operationTimeout *uint32, continueOnError bool, maxObjectCount uint32) ([]Instance, *EnumerationContext, error) {
+	iMethCall := newIMechCall("OpenQueryInstances")
+	iMethCall.appendNamespace(conn.namespace)
+	iMethCall.appendParamVal("FilterQuery", filterQuery)
+	iMethCall.appendParamVal("FilterQueryLanguage", filterQueryLanguage)
+	if nil != operationTimeout {
+		iMethCall.appendParamVal("OperationTimeout", *operationTimeout)
+	}
+	if false != continueOnError {
+		iMethCall.appendParamVal("ContinueOnError", continueOnError)
+	}
+	if 0 != maxObjectCount {
+		iMethCall.appendParamVal("MaxObjectCount", maxObjectCount)
+	}
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
+	if nil != err {
+		return nil, nil, err
+	}
+	if nil != iMethRes.Error {
+		return nil, nil, conn.cimErr(iMethRes.Error)
+	}
+	enumCtx, err := iMethRes.enumerationContext()
+	if nil != err {
+		return nil, nil, err
+	}
+	if nil == iMethRes.IReturnValue {
+		return nil, enumCtx, nil
+	}
+	return iMethRes.IReturnValue.Instance, enumCtx, nil
+}
+
+// The PullInstancesWithPath operation retrieves the next set of instances (including their instance paths) from an open enumeration session established by OpenEnumerateInstances, OpenAssociatorInstances, or OpenReferenceInstances:
+//      <instanceWithPath>*PullInstancesWithPath (
+//           [IN,OUT] <enumerationContext> EnumerationContext,
+//           [OUT] Boolean EndOfSequence,
+//           [IN] uint32 MaxObjectCount
+//      )
+func (conn *WBEMConnection) PullInstancesWithPath(enumCtx *EnumerationContext, maxObjectCount uint32) ([]ValueInstanceWithPath, *EnumerationContext, error) {
+	return conn.PullInstancesWithPathContext(context.Background(), enumCtx, maxObjectCount)
+}
+
+// PullInstancesWithPathContext is the same as PullInstancesWithPath, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) PullInstancesWithPathContext(ctx context.Context, enumCtx *EnumerationContext, maxObjectCount uint32) ([]ValueInstanceWithPath, *EnumerationContext, error) {
+	if nil == enumCtx {
+		return nil, nil, conn.oops(ErrFailed, "")
+	}
+	iMethCall := newIMechCall("PullInstancesWithPath")
+	iMethCall.appendNamespace(conn.namespace)
+	iMethCall.appendParamVal("EnumerationContext", enumCtx.EnumerationContext)
+	iMethCall.appendParamVal("MaxObjectCount", maxObjectCount)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
+	if nil != err {
+		return nil, nil, err
+	}
+	if nil != iMethRes.Error {
+		return nil, nil, conn.cimErr(iMethRes.Error)
+	}
+	enumCtx, err = iMethRes.enumerationContext()
+	if nil != err {
+		return nil, nil, err
+	}
+	if nil == iMethRes.IReturnValue {
+		return nil, enumCtx, nil
+	}
+	return iMethRes.IReturnValue.ValueInstanceWithPath, enumCtx, nil
+}
+
+// The PullInstances operation retrieves the next set of instances (without instance paths) from an open enumeration session established by OpenQueryInstances:
+//      <instance>*PullInstances (
+//           [IN,OUT] <enumerationContext> EnumerationContext,
+//           [OUT] Boolean EndOfSequence,
+//           [IN] uint32 MaxObjectCount
+//      )
+func (conn *WBEMConnection) PullInstances(enumCtx *EnumerationContext, maxObjectCount uint32) ([]Instance, *EnumerationContext, error) {
+	return conn.PullInstancesContext(context.Background(), enumCtx, maxObjectCount)
+}
+
+// PullInstancesContext is the same as PullInstances, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) PullInstancesContext(ctx context.Context, enumCtx *EnumerationContext, maxObjectCount uint32) ([]Instance, *EnumerationContext, error) {
+	if nil == enumCtx {
+		return nil, nil, conn.oops(ErrFailed, "")
+	}
+	iMethCall := newIMechCall("PullInstances")
+	iMethCall.appendNamespace(conn.namespace)
+	iMethCall.appendParamVal("EnumerationContext", enumCtx.EnumerationContext)
+	iMethCall.appendParamVal("MaxObjectCount", maxObjectCount)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
+	if nil != err {
+		return nil, nil, err
+	}
+	if nil != iMethRes.Error {
+		return nil, nil, conn.cimErr(iMethRes.Error)
+	}
+	enumCtx, err = iMethRes.enumerationContext()
+	if nil != err {
+		return nil, nil, err
+	}
+	if nil == iMethRes.IReturnValue {
+		return nil, enumCtx, nil
+	}
+	return iMethRes.IReturnValue.Instance, enumCtx, nil
+}
+
+// The PullInstancePaths operation retrieves the next set of instance paths from an open enumeration session established by OpenEnumerateInstancePaths, OpenAssociatorInstancePaths, or OpenReferenceInstancePaths:
+//      <instancePath>*PullInstancePaths (
+//           [IN,OUT] <enumerationContext> EnumerationContext,
+//           [OUT] Boolean EndOfSequence,
+//           [IN] uint32 MaxObjectCount
+//      )
+func (conn *WBEMConnection) PullInstancePaths(enumCtx *EnumerationContext, maxObjectCount uint32) ([]InstancePath, *EnumerationContext, error) {
+	return conn.PullInstancePathsContext(context.Background(), enumCtx, maxObjectCount)
+}
+
+// PullInstancePathsContext is the same as PullInstancePaths, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) PullInstancePathsContext(ctx context.Context, enumCtx *EnumerationContext, maxObjectCount uint32) ([]InstancePath, *EnumerationContext, error) {
+	if nil == enumCtx {
+		return nil, nil, conn.oops(ErrFailed, "")
+	}
+	iMethCall := newIMechCall("PullInstancePaths")
+	iMethCall.appendNamespace(conn.namespace)
+	iMethCall.appendParamVal("EnumerationContext", enumCtx.EnumerationContext)
+	iMethCall.appendParamVal("MaxObjectCount", maxObjectCount)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
+	if nil != err {
+		return nil, nil, err
+	}
+	if nil != iMethRes.Error {
+		return nil, nil, conn.cimErr(iMethRes.Error)
+	}
+	enumCtx, err = iMethRes.enumerationContext()
+	if nil != err {
+		return nil, nil, err
+	}
+	if nil == iMethRes.IReturnValue {
+		return nil, enumCtx, nil
+	}
+	return iMethRes.IReturnValue.InstancePath, enumCtx, nil
+}
+
+// The CloseEnumeration operation closes an open enumeration session, performing an early termination of an incomplete enumeration session:
+//      void CloseEnumeration (
+//           [IN] <enumerationContext> EnumerationContext
+//      )
+func (conn *WBEMConnection) CloseEnumeration(enumCtx *EnumerationContext) error {
+	return conn.CloseEnumerationContext(context.Background(), enumCtx)
+}
+
+// CloseEnumerationContext is the same as CloseEnumeration, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) CloseEnumerationContext(ctx context.Context, enumCtx *EnumerationContext) error {
+	if nil == enumCtx {
+		return conn.oops(ErrFailed, "")
+	}
+	iMethCall := newIMechCall("CloseEnumeration")
+	iMethCall.appendNamespace(conn.namespace)
+	iMethCall.appendParamVal("EnumerationContext", enumCtx.EnumerationContext)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
+	if nil != err {
+		return err
+	}
+	if nil != iMethRes.Error {
+		return conn.cimErr(iMethRes.Error)
+	}
+	return nil
+}
+
+// The EnumerationCount operation provides an estimated count of the total number of objects in an open enumeration session. A nil count means the WBEM server cannot provide the estimate:
+//      uint64 EnumerationCount (
+//           [IN] <enumerationContext> EnumerationContext
+//      )
+func (conn *WBEMConnection) EnumerationCount(enumCtx *EnumerationContext) (*uint64, error) {
+	return conn.EnumerationCountContext(context.Background(), enumCtx)
+}
+
+// EnumerationCountContext is the same as EnumerationCount, with ctx carried to the HTTP request.
+func (conn *WBEMConnection) EnumerationCountContext(ctx context.Context, enumCtx *EnumerationContext) (*uint64, error) {
+	if nil == enumCtx {
+		return nil, conn.oops(ErrFailed, "")
+	}
+	iMethCall := newIMechCall("EnumerationCount")
+	iMethCall.appendNamespace(conn.namespace)
+	iMethCall.appendParamVal("EnumerationContext", enumCtx.EnumerationContext)
+	iMethRes, err := conn.iMethodCall(ctx, iMethCall)
+	if nil != err {
+		return nil, err
+	}
+	if nil != iMethRes.Error {
+		return nil, conn.cimErr(iMethRes.Error)
+	}
+	if nil == iMethRes.IReturnValue || 0 == len(iMethRes.IReturnValue.Value) {
+		return nil, nil
+	}
+	count, err := strconv.ParseUint(strings.TrimSpace(iMethRes.IReturnValue.Value[0].Value), 10, 64)
+	if nil != err {
+		return nil, err
+	}
+	return &count, nil
+}
@@ -0,0 +1,862 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CIMType is an intrinsic data type of DSP0004, as carried by the TYPE and PARAMTYPE attributes.
+type CIMType string
+
+const (
+	CIMTypeUint8     CIMType = "uint8"
+	CIMTypeUint16    CIMType = "uint16"
+	CIMTypeUint32    CIMType = "uint32"
+	CIMTypeUint64    CIMType = "uint64"
+	CIMTypeSint8     CIMType = "sint8"
+	CIMTypeSint16    CIMType = "sint16"
+	CIMTypeSint32    CIMType = "sint32"
+	CIMTypeSint64    CIMType = "sint64"
+	CIMTypeReal32    CIMType = "real32"
+	CIMTypeReal64    CIMType = "real64"
+	CIMTypeBoolean   CIMType = "boolean"
+	CIMTypeChar16    CIMType = "char16"
+	CIMTypeString    CIMType = "string"
+	CIMTypeDateTime  CIMType = "datetime"
+	CIMTypeReference CIMType = "reference"
+)
+
+// ErrNullValue is returned by the accessors when the value is NULL.
+var ErrNullValue = errors.New("CIM value is NULL")
+
+// The Go type that carries each CIM type in CIMValue.
+var cimGoTypes = map[CIMType]reflect.Type{
+	CIMTypeUint8:     reflect.TypeOf(uint8(0)),
+	CIMTypeUint16:    reflect.TypeOf(uint16(0)),
+	CIMTypeUint32:    reflect.TypeOf(uint32(0)),
+	CIMTypeUint64:    reflect.TypeOf(uint64(0)),
+	CIMTypeSint8:     reflect.TypeOf(int8(0)),
+	CIMTypeSint16:    reflect.TypeOf(int16(0)),
+	CIMTypeSint32:    reflect.TypeOf(int32(0)),
+	CIMTypeSint64:    reflect.TypeOf(int64(0)),
+	CIMTypeReal32:    reflect.TypeOf(float32(0)),
+	CIMTypeReal64:    reflect.TypeOf(float64(0)),
+	CIMTypeBoolean:   reflect.TypeOf(false),
+	CIMTypeChar16:    reflect.TypeOf(rune(0)),
+	CIMTypeString:    reflect.TypeOf(""),
+	CIMTypeDateTime:  reflect.TypeOf(CIMDateTime{}),
+	CIMTypeReference: reflect.TypeOf(&ValueReference{}),
+}
+
+// IsValid reports whether t is one of the intrinsic types.
+func (t CIMType) IsValid() bool {
+	_, ok := cimGoTypes[t]
+	return ok
+}
+
+// IsInteger reports whether t is one of the uint or sint types.
+func (t CIMType) IsInteger() bool {
+	return strings.HasPrefix(string(t), "uint") || strings.HasPrefix(string(t), "sint")
+}
+
+func (t CIMType) bitSize() int {
+	i, _ := strconv.Atoi(string(t)[4:])
+	return i
+}
+
+// CIMValue is a typed value of a property, a parameter, a return value or a key binding.
+// A scalar is carried in Value as the Go type below, an array as a slice of it, and NULL as nil:
+//      uint8 .. uint64 -> uint8 .. uint64
+//      sint8 .. sint64 -> int8 .. int64
+//      real32, real64  -> float32, float64
+//      boolean         -> bool
+//      char16          -> rune
+//      string          -> string
+//      datetime        -> CIMDateTime
+//      reference       -> *ValueReference
+// An array with VALUE.NULL elements is an error, as VALUE.ARRAY does not keep their positions.
+type CIMValue struct {
+	Type    CIMType
+	IsArray bool
+	Value   interface{}
+}
+
+// NewCIMValue converts v to a CIMValue of type typ. v may be any Go integer, float, bool or string
+// that fits typ, a slice of them for an array, or nil for NULL.
+func NewCIMValue(typ CIMType, v interface{}) (*CIMValue, error) {
+	goType, ok := cimGoTypes[typ]
+	if false == ok {
+		return nil, newCIMErr(ErrTypeMismatch, fmt.Sprintf("unknown CIM type %q", typ), nil)
+	}
+	if nil == v {
+		return &CIMValue{Type: typ}, nil
+	}
+	rv := reflect.ValueOf(v)
+	if reflect.Slice == rv.Kind() || reflect.Array == rv.Kind() {
+		arry := reflect.MakeSlice(reflect.SliceOf(goType), 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			elem, err := convertCIMValue(typ, rv.Index(i).Interface())
+			if nil != err {
+				return nil, err
+			}
+			arry = reflect.Append(arry, reflect.ValueOf(elem))
+		}
+		return &CIMValue{Type: typ, IsArray: true, Value: arry.Interface()}, nil
+	}
+	elem, err := convertCIMValue(typ, v)
+	if nil != err {
+		return nil, err
+	}
+	return &CIMValue{Type: typ, Value: elem}, nil
+}
+
+// Converts a Go scalar to the Go type that carries typ, checking the range.
+func convertCIMValue(typ CIMType, v interface{}) (interface{}, error) {
+	if ref, ok := v.(*ValueReference); ok {
+		if CIMTypeReference != typ {
+			return nil, newCIMErr(ErrTypeMismatch, fmt.Sprintf("reference is not a %s value", typ), nil)
+		}
+		return ref, nil
+	}
+	if ref, ok := v.(ValueReference); ok {
+		return convertCIMValue(typ, &ref)
+	}
+	s, err := FormatCIMValue(typ, v)
+	if nil != err {
+		return nil, err
+	}
+	return ParseCIMValue(typ, s)
+}
+
+// IsNull reports whether the value is NULL.
+func (val *CIMValue) IsNull() bool {
+	return nil == val || nil == val.Value
+}
+
+// Strings formats the value as the content of VALUE elements, one per array entry.
+func (val *CIMValue) Strings() ([]string, error) {
+	if val.IsNull() {
+		return nil, nil
+	}
+	if false == val.IsArray {
+		s, err := FormatCIMValue(val.Type, val.Value)
+		if nil != err {
+			return nil, err
+		}
+		return []string{s}, nil
+	}
+	rv := reflect.ValueOf(val.Value)
+	strs := make([]string, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		s, err := FormatCIMValue(val.Type, rv.Index(i).Interface())
+		if nil != err {
+			return nil, err
+		}
+		strs = append(strs, s)
+	}
+	return strs, nil
+}
+
+// The decimal reals of DSP0004, with or without a fraction or an exponent.
+var realPattern = regexp.MustCompile(`^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+
+// ParseCIMValue strictly parses the content of a VALUE element of type typ, returning the Go type listed at CIMValue.
+// Integers are decimal or 0x-prefixed hexadecimal, reals are decimal, with neither NaN nor infinities,
+// and booleans are true or false in any case.
+func ParseCIMValue(typ CIMType, s string) (interface{}, error) {
+	mismatch := func() error {
+		return newCIMErr(ErrTypeMismatch, fmt.Sprintf("invalid %s value %q", typ, s), nil)
+	}
+	switch typ {
+	case CIMTypeUint8, CIMTypeUint16, CIMTypeUint32, CIMTypeUint64:
+		digits, base := integerDigits(strings.TrimSpace(s))
+		if strings.HasPrefix(digits, "-") {
+			return nil, mismatch()
+		}
+		i, err := strconv.ParseUint(strings.TrimPrefix(digits, "+"), base, typ.bitSize())
+		if nil != err {
+			return nil, mismatch()
+		}
+		return reflect.ValueOf(i).Convert(cimGoTypes[typ]).Interface(), nil
+	case CIMTypeSint8, CIMTypeSint16, CIMTypeSint32, CIMTypeSint64:
+		digits, base := integerDigits(strings.TrimSpace(s))
+		i, err := strconv.ParseInt(digits, base, typ.bitSize())
+		if nil != err {
+			return nil, mismatch()
+		}
+		return reflect.ValueOf(i).Convert(cimGoTypes[typ]).Interface(), nil
+	case CIMTypeReal32, CIMTypeReal64:
+		if false == realPattern.MatchString(strings.TrimSpace(s)) {
+			return nil, mismatch()
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), typ.bitSize())
+		if nil != err {
+			return nil, mismatch()
+		}
+		if CIMTypeReal32 == typ {
+			return float32(f), nil
+		}
+		return f, nil
+	case CIMTypeBoolean:
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, mismatch()
+	case CIMTypeChar16:
+		r, size := utf8.DecodeRuneInString(s)
+		if utf8.RuneError == r || len(s) != size || 0xFFFF < r {
+			return nil, mismatch()
+		}
+		return r, nil
+	case CIMTypeString:
+		return s, nil
+	case CIMTypeDateTime:
+		dt, err := ParseCIMDateTime(s)
+		if nil != err {
+			return nil, err
+		}
+		return dt, nil
+	case CIMTypeReference:
+		return nil, newCIMErr(ErrTypeMismatch, "a reference is carried by VALUE.REFERENCE, not VALUE", nil)
+	}
+	return nil, newCIMErr(ErrTypeMismatch, fmt.Sprintf("unknown CIM type %q", typ), nil)
+}
+
+// Splits the optional sign and 0x prefix from an integer value.
+func integerDigits(s string) (string, int) {
+	sign := ""
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		sign, s = s[:1], s[1:]
+	}
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return sign + s[2:], 16
+	}
+	return sign + s, 10
+}
+
+// FormatCIMValue formats the Go scalar v as the content of a VALUE element of type typ.
+// v must be of a Go kind that fits typ: integers for the integer types, integers or floats for the real types,
+// bool for boolean, a rune or a one-character string for char16, a string for string, and a CIMDateTime,
+// time.Time, time.Duration or datetime string for datetime.
+func FormatCIMValue(typ CIMType, v interface{}) (string, error) {
+	mismatch := func() error {
+		return newCIMErr(ErrTypeMismatch, fmt.Sprintf("%T value %v does not fit %s", v, v, typ), nil)
+	}
+	rv := reflect.ValueOf(v)
+	if false == rv.IsValid() {
+		return "", mismatch()
+	}
+	switch typ {
+	case CIMTypeUint8, CIMTypeUint16, CIMTypeUint32, CIMTypeUint64:
+		var u uint64
+		switch rv.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			u = rv.Uint()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if 0 > rv.Int() {
+				return "", mismatch()
+			}
+			u = uint64(rv.Int())
+		default:
+			return "", mismatch()
+		}
+		if 64 > typ.bitSize() && 1<<uint(typ.bitSize()) <= u {
+			return "", mismatch()
+		}
+		return strconv.FormatUint(u, 10), nil
+	case CIMTypeSint8, CIMTypeSint16, CIMTypeSint32, CIMTypeSint64:
+		var i int64
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i = rv.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if math.MaxInt64 < rv.Uint() {
+				return "", mismatch()
+			}
+			i = int64(rv.Uint())
+		default:
+			return "", mismatch()
+		}
+		if 64 > typ.bitSize() && (-1<<uint(typ.bitSize()-1) > i || 1<<uint(typ.bitSize()-1) <= i) {
+			return "", mismatch()
+		}
+		return strconv.FormatInt(i, 10), nil
+	case CIMTypeReal32, CIMTypeReal64:
+		var f float64
+		switch rv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			f = rv.Float()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = float64(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			f = float64(rv.Uint())
+		default:
+			return "", mismatch()
+		}
+		if math.IsInf(f, 0) || math.IsNaN(f) || (CIMTypeReal32 == typ && math.MaxFloat32 < math.Abs(f)) {
+			return "", mismatch()
+		}
+		return formatReal(f, typ.bitSize()), nil
+	case CIMTypeBoolean:
+		if reflect.Bool != rv.Kind() {
+			return "", mismatch()
+		}
+		return strconv.FormatBool(rv.Bool()), nil
+	case CIMTypeChar16:
+		var r rune
+		switch rv.Kind() {
+		case reflect.Int32, reflect.Uint16:
+			r = rune(rv.Convert(reflect.TypeOf(rune(0))).Int())
+		case reflect.String:
+			if 1 != utf8.RuneCountInString(rv.String()) {
+				return "", mismatch()
+			}
+			r, _ = utf8.DecodeRuneInString(rv.String())
+		default:
+			return "", mismatch()
+		}
+		if 0 > r || 0xFFFF < r || false == utf8.ValidRune(r) {
+			return "", mismatch()
+		}
+		return string(r), nil
+	case CIMTypeString:
+		if reflect.String != rv.Kind() {
+			return "", mismatch()
+		}
+		return rv.String(), nil
+	case CIMTypeDateTime:
+		var dt CIMDateTime
+		var err error
+		switch v := v.(type) {
+		case CIMDateTime:
+			dt = v
+		case time.Time:
+			dt, err = NewCIMDateTime(v)
+		case time.Duration:
+			dt, err = NewCIMInterval(v)
+		case string:
+			dt, err = ParseCIMDateTime(v)
+		default:
+			return "", mismatch()
+		}
+		if nil != err {
+			return "", err
+		}
+		if dt.IsZero() {
+			return "", mismatch()
+		}
+		return dt.String(), nil
+	case CIMTypeReference:
+		return "", newCIMErr(ErrTypeMismatch, "a reference is carried by VALUE.REFERENCE, not VALUE", nil)
+	}
+	return "", newCIMErr(ErrTypeMismatch, fmt.Sprintf("unknown CIM type %q", typ), nil)
+}
+
+// Formats a finite real the way DSP0004 writes it, always with a decimal point.
+func formatReal(f float64, bitSize int) string {
+	s := strconv.FormatFloat(f, 'G', -1, bitSize)
+	if strings.ContainsRune(s, '.') {
+		return s
+	}
+	if i := strings.IndexByte(s, 'E'); 0 <= i {
+		return s[:i] + ".0" + s[i:]
+	}
+	return s + ".0"
+}
+
+// Parses the VALUE elements of an array.
+func parseValueArray(typ CIMType, arry *ValueArray) (*CIMValue, error) {
+	if nil == arry {
+		return &CIMValue{Type: typ, IsArray: true}, nil
+	}
+	if 0 != len(arry.ValueNull) {
+		return nil, newCIMErr(ErrNotSupported, fmt.Sprintf("the %s array has NULL elements, whose positions are unknown", typ), nil)
+	}
+	vals := reflect.MakeSlice(reflect.SliceOf(cimGoTypes[typ]), 0, len(arry.Value))
+	for _, v := range arry.Value {
+		elem, err := ParseCIMValue(typ, v.Value)
+		if nil != err {
+			return nil, err
+		}
+		vals = reflect.Append(vals, reflect.ValueOf(elem))
+	}
+	return &CIMValue{Type: typ, IsArray: true, Value: vals.Interface()}, nil
+}
+
+func parseValue(typ CIMType, val *Value) (*CIMValue, error) {
+	if nil == val {
+		return &CIMValue{Type: typ}, nil
+	}
+	elem, err := ParseCIMValue(typ, val.Value)
+	if nil != err {
+		return nil, err
+	}
+	return &CIMValue{Type: typ, Value: elem}, nil
+}
+
+func parseValueRefArray(arry *ValueRefArray) *CIMValue {
+	if nil == arry {
+		return &CIMValue{Type: CIMTypeReference, IsArray: true}
+	}
+	refs := make([]*ValueReference, 0, len(arry.ValueReference))
+	for i := range arry.ValueReference {
+		refs = append(refs, &arry.ValueReference[i])
+	}
+	return &CIMValue{Type: CIMTypeReference, IsArray: true, Value: refs}
+}
+
+func parseValueReference(ref *ValueReference) *CIMValue {
+	if nil == ref {
+		return &CIMValue{Type: CIMTypeReference}
+	}
+	return &CIMValue{Type: CIMTypeReference, Value: ref}
+}
+
+// Returns the TYPE of an element, failing when it is not an intrinsic type.
+func cimType(typ string) (CIMType, error) {
+	t := CIMType(typ)
+	if false == t.IsValid() {
+		return "", newCIMErr(ErrTypeMismatch, fmt.Sprintf("unknown CIM type %q", typ), nil)
+	}
+	return t, nil
+}
+
+// CIMValue returns the typed value of the property.
+func (prop *Property) CIMValue() (*CIMValue, error) {
+	typ, err := cimType(prop.Type)
+	if nil != err {
+		return nil, err
+	}
+	return parseValue(typ, prop.Value)
+}
+
+// CIMValue returns the typed value of the array property.
+func (prop *PropertyArray) CIMValue() (*CIMValue, error) {
+	typ, err := cimType(prop.Type)
+	if nil != err {
+		return nil, err
+	}
+	return parseValueArray(typ, prop.ValueArray)
+}
+
+// CIMValue returns the reference of the reference property.
+func (prop *PropertyReference) CIMValue() (*CIMValue, error) {
+	return parseValueReference(prop.ValueReference), nil
+}
+
+// CIMValue returns the typed value of the parameter. A value without PARAMTYPE is taken as a string.
+func (param *ParamValue) CIMValue() (*CIMValue, error) {
+	return param.cimValueAs(CIMTypeString)
+}
+
+// Returns the typed value of the parameter, taking a value without PARAMTYPE as typ.
+func (param *ParamValue) cimValueAs(typ CIMType) (*CIMValue, error) {
+	if "" != param.ParamType {
+		var err error
+		if typ, err = cimType(param.ParamType); nil != err {
+			return nil, err
+		}
+	}
+	switch {
+	case nil != param.ValueReference:
+		return parseValueReference(param.ValueReference), nil
+	case nil != param.ValueRefArray:
+		return parseValueRefArray(param.ValueRefArray), nil
+	case nil != param.ValueArray:
+		return parseValueArray(typ, param.ValueArray)
+	}
+	return parseValue(typ, param.Value)
+}
+
+// CIMValue returns the typed return value. A value without PARAMTYPE is taken as a sint64 when it
+// looks like an integer, and as a string otherwise.
+func (ret *ReturnValue) CIMValue() (*CIMValue, error) {
+	if nil != ret.ValueReference {
+		return parseValueReference(ret.ValueReference), nil
+	}
+	if "" == ret.ParamType {
+		if nil != ret.Value {
+			if v, err := parseValue(CIMTypeSint64, ret.Value); nil == err {
+				return v, nil
+			}
+		}
+		return parseValue(CIMTypeString, ret.Value)
+	}
+	typ, err := cimType(ret.ParamType)
+	if nil != err {
+		return nil, err
+	}
+	return parseValue(typ, ret.Value)
+}
+
+// CIMValue returns the typed value of the key. Without TYPE, the type follows VALUETYPE:
+// a numeric key is a sint64 when it is negative and a uint64 otherwise.
+func (kv *KeyValue) CIMValue() (*CIMValue, error) {
+	if "" != kv.Type {
+		typ, err := cimType(kv.Type)
+		if nil != err {
+			return nil, err
+		}
+		return parseValue(typ, &Value{kv.KeyValue})
+	}
+	switch kv.ValueType {
+	case "boolean":
+		return parseValue(CIMTypeBoolean, &Value{kv.KeyValue})
+	case "numeric":
+		if strings.HasPrefix(strings.TrimSpace(kv.KeyValue), "-") {
+			return parseValue(CIMTypeSint64, &Value{kv.KeyValue})
+		}
+		return parseValue(CIMTypeUint64, &Value{kv.KeyValue})
+	}
+	return parseValue(CIMTypeString, &Value{kv.KeyValue})
+}
+
+// NewKeyValue returns the KEYVALUE of a scalar value.
+func NewKeyValue(val *CIMValue) (*KeyValue, error) {
+	if val.IsNull() || val.IsArray || CIMTypeReference == val.Type {
+		return nil, newCIMErr(ErrTypeMismatch, "a key value shall be a non-NULL scalar", nil)
+	}
+	s, err := FormatCIMValue(val.Type, val.Value)
+	if nil != err {
+		return nil, err
+	}
+	valueType := "string"
+	switch {
+	case CIMTypeBoolean == val.Type:
+		valueType = "boolean"
+	case val.Type.IsInteger(), CIMTypeReal32 == val.Type, CIMTypeReal64 == val.Type:
+		valueType = "numeric"
+	}
+	return &KeyValue{ValueType: valueType, Type: string(val.Type), KeyValue: s}, nil
+}
+
+// NewParamValue returns the PARAMVALUE that passes val as the parameter name of InvokeMethod.
+func NewParamValue(name string, val *CIMValue) (*ParamValue, error) {
+	param := &ParamValue{Name: name, ParamType: string(val.Type)}
+	if val.IsNull() {
+		return param, nil
+	}
+	if CIMTypeReference == val.Type {
+		if val.IsArray {
+			var arry ValueRefArray
+			for _, ref := range val.Value.([]*ValueReference) {
+				arry.ValueReference = append(arry.ValueReference, *ref)
+			}
+			param.ValueRefArray = &arry
+		} else {
+			param.ValueReference = val.Value.(*ValueReference)
+		}
+		return param, nil
+	}
+	strs, err := val.Strings()
+	if nil != err {
+		return nil, err
+	}
+	if val.IsArray {
+		param.ValueArray = newValueArray(strs)
+	} else {
+		param.Value = &Value{strs[0]}
+	}
+	return param, nil
+}
+
+func newValueArray(strs []string) *ValueArray {
+	var arry ValueArray
+	for _, s := range strs {
+		arry.Value = append(arry.Value, Value{s})
+	}
+	return &arry
+}
+
+// GetValue returns the typed value of the named property. Property names are case-insensitive.
+func (inst *Instance) GetValue(name string) (*CIMValue, error) {
+	for i := range inst.Property {
+		if strings.EqualFold(name, inst.Property[i].Name) {
+			return inst.Property[i].CIMValue()
+		}
+	}
+	for i := range inst.PropertyArray {
+		if strings.EqualFold(name, inst.PropertyArray[i].Name) {
+			return inst.PropertyArray[i].CIMValue()
+		}
+	}
+	for i := range inst.PropertyReference {
+		if strings.EqualFold(name, inst.PropertyReference[i].Name) {
+			return inst.PropertyReference[i].CIMValue()
+		}
+	}
+	return nil, newCIMErr(ErrNoSuchProperty, fmt.Sprintf("no property %s in %s", name, inst.ClassName), nil)
+}
+
+// SetValue sets the named property to val, replacing the property of the same name if any.
+func (inst *Instance) SetValue(name string, val *CIMValue) error {
+	inst.removeProperty(name)
+	if CIMTypeReference == val.Type {
+		if val.IsArray {
+			return newCIMErr(ErrTypeMismatch, "a reference property shall be a scalar", nil)
+		}
+		prop := PropertyReference{Name: name}
+		if false == val.IsNull() {
+			prop.ValueReference = val.Value.(*ValueReference)
+		}
+		inst.PropertyReference = append(inst.PropertyReference, prop)
+		return nil
+	}
+	strs, err := val.Strings()
+	if nil != err {
+		return err
+	}
+	if val.IsArray {
+		prop := PropertyArray{Name: name, Type: string(val.Type)}
+		if false == val.IsNull() {
+			prop.ValueArray = newValueArray(strs)
+		}
+		inst.PropertyArray = append(inst.PropertyArray, prop)
+		return nil
+	}
+	prop := Property{Name: name, Type: string(val.Type)}
+	if false == val.IsNull() {
+		prop.Value = &Value{strs[0]}
+	}
+	inst.Property = append(inst.Property, prop)
+	return nil
+}
+
+func (inst *Instance) removeProperty(name string) {
+	props := inst.Property[:0]
+	for _, prop := range inst.Property {
+		if false == strings.EqualFold(name, prop.Name) {
+			props = append(props, prop)
+		}
+	}
+	inst.Property = props
+	arrays := inst.PropertyArray[:0]
+	for _, prop := range inst.PropertyArray {
+		if false == strings.EqualFold(name, prop.Name) {
+			arrays = append(arrays, prop)
+		}
+	}
+	inst.PropertyArray = arrays
+	refs := inst.PropertyReference[:0]
+	for _, prop := range inst.PropertyReference {
+		if false == strings.EqualFold(name, prop.Name) {
+			refs = append(refs, prop)
+		}
+	}
+	inst.PropertyReference = refs
+}
+
+// Returns the non-NULL value of the named property, checking it is of type typ.
+func (inst *Instance) getTyped(name string, typ CIMType, isArray bool) (interface{}, error) {
+	val, err := inst.GetValue(name)
+	if nil != err {
+		return nil, err
+	}
+	if typ != val.Type || isArray != val.IsArray {
+		return nil, newCIMErr(ErrTypeMismatch, fmt.Sprintf("property %s is %s, not %s", name, val.describe(), (&CIMValue{Type: typ, IsArray: isArray}).describe()), nil)
+	}
+	if val.IsNull() {
+		return nil, ErrNullValue
+	}
+	return val.Value, nil
+}
+
+// Converts the return value of an extrinsic method, which is an integer for nearly all methods, to int,
+// or to -1 when it is not an integer that fits.
+func (val *CIMValue) returnCode() int {
+	if false == val.IsNull() && false == val.IsArray {
+		rv := reflect.ValueOf(val.Value)
+		switch {
+		case val.Type.IsInteger() && strings.HasPrefix(string(val.Type), "uint"):
+			if rv.Uint() <= math.MaxInt32 {
+				return int(rv.Uint())
+			}
+		case val.Type.IsInteger():
+			if math.MinInt32 <= rv.Int() && rv.Int() <= math.MaxInt32 {
+				return int(rv.Int())
+			}
+		}
+	}
+	return -1
+}
+
+func (val *CIMValue) describe() string {
+	if val.IsArray {
+		return string(val.Type) + "[]"
+	}
+	return string(val.Type)
+}
+
+// The accessors below return the value of the named property, failing with ErrNoSuchProperty when there
+// is no such property, with ErrTypeMismatch when it is not of the type asked for, and with ErrNullValue when it is NULL.
+
+func (inst *Instance) GetUint8(name string) (uint8, error) {
+	v, err := inst.getTyped(name, CIMTypeUint8, false)
+	if nil != err {
+		return 0, err
+	}
+	return v.(uint8), nil
+}
+
+func (inst *Instance) GetUint16(name string) (uint16, error) {
+	v, err := inst.getTyped(name, CIMTypeUint16, false)
+	if nil != err {
+		return 0, err
+	}
+	return v.(uint16), nil
+}
+
+func (inst *Instance) GetUint32(name string) (uint32, error) {
+	v, err := inst.getTyped(name, CIMTypeUint32, false)
+	if nil != err {
+		return 0, err
+	}
+	return v.(uint32), nil
+}
+
+func (inst *Instance) GetUint64(name string) (uint64, error) {
+	v, err := inst.getTyped(name, CIMTypeUint64, false)
+	if nil != err {
+		return 0, err
+	}
+	return v.(uint64), nil
+}
+
+func (inst *Instance) GetSint8(name string) (int8, error) {
+	v, err := inst.getTyped(name, CIMTypeSint8, false)
+	if nil != err {
+		return 0, err
+	}
+	return v.(int8), nil
+}
+
+func (inst *Instance) GetSint16(name string) (int16, error) {
+	v, err := inst.getTyped(name, CIMTypeSint16, false)
+	if nil != err {
+		return 0, err
+	}
+	return v.(int16), nil
+}
+
+func (inst *Instance) GetSint32(name string) (int32, error) {
+	v, err := inst.getTyped(name, CIMTypeSint32, false)
+	if nil != err {
+		return 0, err
+	}
+	return v.(int32), nil
+}
+
+func (inst *Instance) GetSint64(name string) (int64, error) {
+	v, err := inst.getTyped(name, CIMTypeSint64, false)
+	if nil != err {
+		return 0, err
+	}
+	return v.(int64), nil
+}
+
+func (inst *Instance) GetReal32(name string) (float32, error) {
+	v, err := inst.getTyped(name, CIMTypeReal32, false)
+	if nil != err {
+		return 0, err
+	}
+	return v.(float32), nil
+}
+
+func (inst *Instance) GetReal64(name string) (float64, error) {
+	v, err := inst.getTyped(name, CIMTypeReal64, false)
+	if nil != err {
+		return 0, err
+	}
+	return v.(float64), nil
+}
+
+func (inst *Instance) GetBoolean(name string) (bool, error) {
+	v, err := inst.getTyped(name, CIMTypeBoolean, false)
+	if nil != err {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+func (inst *Instance) GetChar16(name string) (rune, error) {
+	v, err := inst.getTyped(name, CIMTypeChar16, false)
+	if nil != err {
+		return 0, err
+	}
+	return v.(rune), nil
+}
+
+func (inst *Instance) GetString(name string) (string, error) {
+	v, err := inst.getTyped(name, CIMTypeString, false)
+	if nil != err {
+		return "", err
+	}
+	return v.(string), nil
+}
+
+func (inst *Instance) GetDateTime(name string) (CIMDateTime, error) {
+	v, err := inst.getTyped(name, CIMTypeDateTime, false)
+	if nil != err {
+		return CIMDateTime{}, err
+	}
+	return v.(CIMDateTime), nil
+}
+
+func (inst *Instance) GetReference(name string) (*ValueReference, error) {
+	v, err := inst.getTyped(name, CIMTypeReference, false)
+	if nil != err {
+		return nil, err
+	}
+	return v.(*ValueReference), nil
+}
+
+func (inst *Instance) GetUint16Array(name string) ([]uint16, error) {
+	v, err := inst.getTyped(name, CIMTypeUint16, true)
+	if nil != err {
+		return nil, err
+	}
+	return v.([]uint16), nil
+}
+
+func (inst *Instance) GetUint32Array(name string) ([]uint32, error) {
+	v, err := inst.getTyped(name, CIMTypeUint32, true)
+	if nil != err {
+		return nil, err
+	}
+	return v.([]uint32), nil
+}
+
+func (inst *Instance) GetStringArray(name string) ([]string, error) {
+	v, err := inst.getTyped(name, CIMTypeString, true)
+	if nil != err {
+		return nil, err
+	}
+	return v.([]string), nil
+}
@@ -0,0 +1,88 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseCIMValueReal(t *testing.T) {
+	tests := []struct {
+		typ  CIMType
+		s    string
+		want interface{}
+	}{
+		{CIMTypeReal64, "1.5", 1.5},
+		{CIMTypeReal64, " -2.5E-3 ", -2.5e-3},
+		{CIMTypeReal64, "+.5", 0.5},
+		{CIMTypeReal64, "3", 3.0},
+		{CIMTypeReal64, "1e10", 1e10},
+		{CIMTypeReal32, "0.25", float32(0.25)},
+		{CIMTypeReal64, "NaN", nil},
+		{CIMTypeReal64, "INF", nil},
+		{CIMTypeReal64, "-Infinity", nil},
+		{CIMTypeReal64, "0x1p-2", nil},
+		{CIMTypeReal64, "1_000.0", nil},
+		{CIMTypeReal64, "1e400", nil},
+		{CIMTypeReal32, "1e39", nil},
+		{CIMTypeReal64, ".", nil},
+		{CIMTypeReal64, "1e", nil},
+		{CIMTypeReal64, "", nil},
+	}
+	for _, test := range tests {
+		v, err := ParseCIMValue(test.typ, test.s)
+		if nil == test.want {
+			if false == errors.Is(err, ErrTypeMismatch) {
+				t.Errorf("%s %q: got %v, %v, want CIM_ERR_TYPE_MISMATCH", test.typ, test.s, v, err)
+			}
+			continue
+		}
+		if nil != err || test.want != v {
+			t.Errorf("%s %q: got %v, %v, want %v", test.typ, test.s, v, err, test.want)
+		}
+	}
+}
+
+func TestFormatCIMValueReal(t *testing.T) {
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if s, err := FormatCIMValue(CIMTypeReal64, f); false == errors.Is(err, ErrTypeMismatch) {
+			t.Errorf("%v: got %q, %v, want CIM_ERR_TYPE_MISMATCH", f, s, err)
+		}
+	}
+	if s, err := FormatCIMValue(CIMTypeReal32, 2.0); nil != err || "2.0" != s {
+		t.Errorf("got %q, %v, want 2.0", s, err)
+	}
+}
+
+func TestParseValueArrayNull(t *testing.T) {
+	val, err := parseValueArray(CIMTypeUint16, &ValueArray{Value: []Value{{"1"}, {"3"}}})
+	if nil != err || false == reflect.DeepEqual([]uint16{1, 3}, val.Value) {
+		t.Errorf("got %+v, %v", val, err)
+	}
+	arry := &ValueArray{Value: []Value{{"1"}, {"3"}}, ValueNull: []ValueNull{{}}}
+	if val, err = parseValueArray(CIMTypeUint16, arry); false == errors.Is(err, ErrNotSupported) {
+		t.Errorf("got %+v, %v, want CIM_ERR_NOT_SUPPORTED", val, err)
+	}
+	inst := &Instance{ClassName: "Test_Element", PropertyArray: []PropertyArray{{Name: "Codes", Type: "uint16", ValueArray: arry}}}
+	if _, err = inst.GetValue("Codes"); false == errors.Is(err, ErrNotSupported) {
+		t.Errorf("GetValue: got %v, want CIM_ERR_NOT_SUPPORTED", err)
+	}
+}
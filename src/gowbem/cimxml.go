@@ -17,6 +17,11 @@
 
 package gowbem
 
+import (
+	"encoding/xml"
+	"strings"
+)
+
 // <!--
 // **************************************************
 // Top-level element
@@ -121,7 +126,69 @@ type Scope struct {
 // <!ELEMENT VALUE (#PCDATA)>
 //type Value string
 type Value struct {
-	Value string `xml:",innerxml" json:",omitempty"`
+	Value string `xml:",chardata" json:",omitempty"`
+}
+
+// UnmarshalXML reads the text of a VALUE, with the entity references and CDATA sections expanded.
+// DSP0201 has embedded objects escaped as text, but some servers send the INSTANCE or CLASS
+// elements unescaped; such nested elements are kept as their XML text, the way an escaped
+// embedded object reads:
+//      <VALUE>&lt;INSTANCE CLASSNAME="CIM_Error"&gt;...&lt;/INSTANCE&gt;</VALUE>
+//      <VALUE><![CDATA[<INSTANCE CLASSNAME="CIM_Error">...</INSTANCE>]]></VALUE>
+//      <VALUE><INSTANCE CLASSNAME="CIM_Error">...</INSTANCE></VALUE>
+// On marshal the text is escaped; the control characters XML 1.0 cannot carry go out as U+FFFD.
+func (val *Value) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var b strings.Builder
+	for {
+		tok, err := d.Token()
+		if nil != err {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			b.Write(tok)
+		case xml.StartElement:
+			if err = copyElement(&b, d, tok); nil != err {
+				return err
+			}
+		case xml.EndElement:
+			val.Value = b.String()
+			return nil
+		}
+	}
+}
+
+// Writes the XML of the element that starts with start, reading the rest of it from d.
+func copyElement(b *strings.Builder, d *xml.Decoder, start xml.StartElement) error {
+	enc := xml.NewEncoder(b)
+	depth := 0
+	for tok := xml.Token(start); ; {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			// the names of CIM-XML have no namespaces, which the encoder would declare anew
+			t.Name.Space = ""
+			tok = t
+		case xml.EndElement:
+			depth--
+			t.Name.Space = ""
+			tok = t
+		case xml.ProcInst, xml.Directive:
+			tok = nil
+		}
+		if nil != tok {
+			if err := enc.EncodeToken(tok); nil != err {
+				return err
+			}
+		}
+		if 0 == depth {
+			return enc.Flush()
+		}
+		var err error
+		if tok, err = d.Token(); nil != err {
+			return err
+		}
+	}
 }
 
 // <!ELEMENT VALUE.ARRAY (VALUE | VALUE.NULL)*>
@@ -211,7 +278,7 @@ type LocalNamespacePath struct {
 // <!ELEMENT HOST (#PCDATA)>
 //type Host string
 type Host struct {
-	Host string `xml:",innerxml" json:",omitempty"`
+	Host string `xml:",chardata" json:",omitempty"`
 }
 
 // <!ELEMENT NAMESPACE EMPTY>
@@ -289,7 +356,7 @@ type KeyBinding struct {
 type KeyValue struct {
 	ValueType string `xml:"VALUETYPE,attr,omitempty" json:",omitempty"`
 	Type      string `xml:"TYPE,attr,omitempty" json:",omitempty"`
-	KeyValue  string `xml:",innerxml" json:",omitempty"`
+	KeyValue  string `xml:",chardata" json:",omitempty"`
 }
 
 // <!--
@@ -362,6 +429,10 @@ type Property struct {
 	EmbeddedObject string      `xml:"EmbeddedObject,attr,omitempty" json:",omitempty"`
 	Qualifier      []Qualifier `xml:"QUALIFIER" json:",omitempty"`
 	Value          *Value      `xml:"VALUE" json:",omitempty"`
+	// the decoded VALUE of an embedded object, which takes the place of the VALUE on send
+	EmbeddedValue *ValueObject `xml:"-" json:",omitempty"`
+	// the text EmbeddedValue was decoded from
+	decoded []string
 }
 
 // <!ELEMENT PROPERTY.ARRAY (QUALIFIER*, VALUE.ARRAY?)>
@@ -383,6 +454,10 @@ type PropertyArray struct {
 	EmbeddedObject string      `xml:"EmbeddedObject,attr,omitempty" json:",omitempty"`
 	Qualifier      []Qualifier `xml:"QUALIFIER" json:",omitempty"`
 	ValueArray     *ValueArray `xml:"VALUE.ARRAY" json:",omitempty"`
+	// the decoded VALUE.ARRAY of embedded objects, which takes the place of the VALUE.ARRAY on send
+	EmbeddedValues []ValueObject `xml:"-" json:",omitempty"`
+	// the texts EmbeddedValues were decoded from
+	decoded []string
 }
 
 // <!ELEMENT PROPERTY.REFERENCE (QUALIFIER*, VALUE.REFERENCE?)>
@@ -534,6 +609,11 @@ type ParamValue struct {
 	Class              *Class              `xml:"CLASS" json:",omitempty"`
 	Instance           *Instance           `xml:"INSTANCE" json:",omitempty"`
 	ValueNamedInstance *ValueNamedInstance `xml:"VALUE.NAMEDINSTANCE" json:",omitempty"`
+	// the decoded VALUE or VALUE.ARRAY of embedded objects, which take their place on send
+	EmbeddedValue  *ValueObject  `xml:"-" json:",omitempty"`
+	EmbeddedValues []ValueObject `xml:"-" json:",omitempty"`
+	// the texts EmbeddedValue or EmbeddedValues were decoded from
+	decoded []string
 }
 
 // <!ELEMENT IMETHODCALL (LOCALNAMESPACEPATH, IPARAMVALUE*)>
@@ -603,8 +683,9 @@ type IMethodResponse struct {
 //     DESCRIPTION CDATA #IMPLIED
 // >
 type Error struct {
-	Code        string `xml:"CODE,attr" json:",omitempty"`
-	Description string `xml:"DESCRIPTION,attr" json:",omitempty"`
+	Code        string     `xml:"CODE,attr" json:",omitempty"`
+	Description string     `xml:"DESCRIPTION,attr" json:",omitempty"`
+	Instance    []Instance `xml:"INSTANCE" json:",omitempty"`
 }
 
 // <!ELEMENT RETURNVALUE (VALUE | VALUE.REFERENCE)?>
@@ -617,6 +698,10 @@ type ReturnValue struct {
 	ParamType      string          `xml:"PARAMTYPE,attr,omitempty" json:",omitempty"`
 	Value          *Value          `xml:"VALUE" json:",omitempty"`
 	ValueReference *ValueReference `xml:"VALUE.REFERENCE" json:",omitempty"`
+	// the decoded VALUE of an embedded object
+	EmbeddedValue *ValueObject `xml:"-" json:",omitempty"`
+	// the text EmbeddedValue was decoded from
+	decoded []string
 }
 
 // <!ELEMENT IRETURNVALUE (CLASSNAME* | INSTANCENAME* | VALUE* | VALUE.OBJECTWITHPATH* | VALUE.OBJECTWITHLOCALPATH* | VALUE.OBJECT* | OBJECTPATH* | QUALIFIER.DECLARATION* | VALUE.ARRAY? | VALUE.REFERENCE? | CLASS* | INSTANCE* | INSTANCEPATH* | VALUE.NAMEDINSTANCE* | VALUE.INSTANCEWITHPATH*)>
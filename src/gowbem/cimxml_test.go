@@ -0,0 +1,163 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+// Texts that VALUE, KEYVALUE and HOST shall carry unchanged through marshal and unmarshal.
+var cimxmlTexts = []struct {
+	name string
+	text string
+}{
+	{"empty", ""},
+	{"plain", "CIM_ComputerSystem"},
+	{"quotes", `say "hi" and 'bye'`},
+	{"markup", `a < b && c > d`},
+	{"entities", "&amp; &lt; is text"},
+	{"whitespace controls", "tab\there\nnew line\r\nCRLF"},
+	{"cdata lookalike", "<![CDATA[not a section]]>"},
+	{"multi-byte", "héllo wörld 世界 🙂"},
+	{"embedded instance", `<INSTANCE CLASSNAME="CIM_Error"><PROPERTY NAME="Message" TYPE="string"><VALUE>a &lt; b</VALUE></PROPERTY></INSTANCE>`},
+}
+
+func TestValueRoundTrip(t *testing.T) {
+	for _, test := range cimxmlTexts {
+		raw, err := xml.Marshal(&Value{test.text})
+		if nil != err {
+			t.Fatalf("%s: marshal: %v", test.name, err)
+		}
+		var val Value
+		if err = xml.Unmarshal(raw, &val); nil != err {
+			t.Fatalf("%s: unmarshal %s: %v", test.name, raw, err)
+		}
+		if test.text != val.Value {
+			t.Errorf("%s: got %q from %s, want %q", test.name, val.Value, raw, test.text)
+		}
+	}
+}
+
+func TestKeyValueRoundTrip(t *testing.T) {
+	for _, test := range cimxmlTexts {
+		in := KeyValue{ValueType: "string", KeyValue: test.text}
+		raw, err := xml.Marshal(&in)
+		if nil != err {
+			t.Fatalf("%s: marshal: %v", test.name, err)
+		}
+		var out KeyValue
+		if err = xml.Unmarshal(raw, &out); nil != err {
+			t.Fatalf("%s: unmarshal %s: %v", test.name, raw, err)
+		}
+		if in != out {
+			t.Errorf("%s: got %+v from %s, want %+v", test.name, out, raw, in)
+		}
+	}
+}
+
+func TestHostRoundTrip(t *testing.T) {
+	for _, host := range []string{"10.0.0.1:5989", "[fe80::1%25eth0]:5989", "wbem.example.com", "bmc-ü.example"} {
+		raw, err := xml.Marshal(&Host{host})
+		if nil != err {
+			t.Fatalf("%s: marshal: %v", host, err)
+		}
+		var out Host
+		if err = xml.Unmarshal(raw, &out); nil != err {
+			t.Fatalf("%s: unmarshal %s: %v", host, raw, err)
+		}
+		if host != out.Host {
+			t.Errorf("got %q from %s, want %q", out.Host, raw, host)
+		}
+	}
+}
+
+func TestValueControlCharacters(t *testing.T) {
+	raw, err := xml.Marshal(&Value{"bell\x07 and nul\x00"})
+	if nil != err {
+		t.Fatalf("marshal: %v", err)
+	}
+	var val Value
+	if err = xml.Unmarshal(raw, &val); nil != err {
+		t.Fatalf("unmarshal %s: %v", raw, err)
+	}
+	if want := "bell� and nul�"; want != val.Value {
+		t.Errorf("got %q from %s, want %q", val.Value, raw, want)
+	}
+}
+
+func TestValueUnmarshal(t *testing.T) {
+	instance := `<INSTANCE CLASSNAME="CIM_Error"><PROPERTY NAME="Message" TYPE="string"><VALUE>a &lt; b</VALUE></PROPERTY></INSTANCE>`
+	tests := []struct {
+		name string
+		xml  string
+		want string
+	}{
+		{"escaped", `<VALUE>&lt;INSTANCE CLASSNAME=&quot;CIM_Error&quot;&gt;&lt;PROPERTY NAME=&quot;Message&quot; TYPE=&quot;string&quot;&gt;&lt;VALUE&gt;a &amp;lt; b&lt;/VALUE&gt;&lt;/PROPERTY&gt;&lt;/INSTANCE&gt;</VALUE>`, instance},
+		{"cdata", `<VALUE><![CDATA[` + instance + `]]></VALUE>`, instance},
+		{"unescaped", `<VALUE>` + instance + `</VALUE>`, instance},
+		{"mixed cdata", `<VALUE>a<![CDATA[ <&> ]]>b</VALUE>`, "a <&> b"},
+		{"character references", `<VALUE>&#x4E16;&#30028; &#x1F642;</VALUE>`, "世界 🙂"},
+	}
+	for _, test := range tests {
+		var val Value
+		if err := xml.Unmarshal([]byte(test.xml), &val); nil != err {
+			t.Fatalf("%s: unmarshal: %v", test.name, err)
+		}
+		if test.want != val.Value {
+			t.Errorf("%s: got %q, want %q", test.name, val.Value, test.want)
+		}
+	}
+}
+
+func TestUnescapedEmbeddedInstance(t *testing.T) {
+	raw := `<PROPERTY NAME="Error" TYPE="string" EmbeddedObject="instance"><VALUE>` +
+		`<INSTANCE CLASSNAME="CIM_Error"><PROPERTY NAME="Message" TYPE="string"><VALUE>disk &quot;0&quot; &lt; 5%</VALUE></PROPERTY></INSTANCE>` +
+		`</VALUE></PROPERTY>`
+	var prop Property
+	if err := xml.Unmarshal([]byte(raw), &prop); nil != err {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if nil == prop.Value || false == strings.HasPrefix(prop.Value.Value, `<INSTANCE CLASSNAME="CIM_Error">`) {
+		t.Fatalf("got VALUE %+v", prop.Value)
+	}
+	var inst Instance
+	if err := xml.Unmarshal([]byte(prop.Value.Value), &inst); nil != err {
+		t.Fatalf("unmarshal the embedded instance %q: %v", prop.Value.Value, err)
+	}
+	if msg := propertyString(&inst, "Message"); `disk "0" < 5%` != msg {
+		t.Errorf("embedded Message %q", msg)
+	}
+
+	// marshaled again, the embedded instance is escaped text that reads back the same
+	out, err := xml.Marshal(&prop)
+	if nil != err {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(out), "<INSTANCE") {
+		t.Errorf("embedded instance not escaped: %s", out)
+	}
+	var back Property
+	if err = xml.Unmarshal(out, &back); nil != err {
+		t.Fatalf("unmarshal %s: %v", out, err)
+	}
+	if nil == back.Value || prop.Value.Value != back.Value.Value {
+		t.Errorf("got %+v from %s, want %q", back.Value, out, prop.Value.Value)
+	}
+}
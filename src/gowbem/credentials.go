@@ -0,0 +1,196 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	DefaultEnvUsername = "WBEM_USERNAME"
+	DefaultEnvPassword = "WBEM_PASSWORD"
+)
+
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// CredentialsProvider supplies the user credentials of a connection. It is called before every
+// request and again when the WBEM server rejects a request with 401, so that rotated passwords are
+// picked up without creating a new connection.
+type CredentialsProvider interface {
+	GetCredentials(ctx context.Context, host string) (*Credentials, error)
+}
+
+// StaticCredentials always returns the same credentials.
+type StaticCredentials Credentials
+
+func (creds StaticCredentials) GetCredentials(ctx context.Context, host string) (*Credentials, error) {
+	return &Credentials{creds.Username, creds.Password}, nil
+}
+
+// EnvCredentials reads the credentials from environment variables, DefaultEnvUsername and
+// DefaultEnvPassword if the names are empty.
+type EnvCredentials struct {
+	UsernameVar string
+	PasswordVar string
+}
+
+func (env EnvCredentials) GetCredentials(ctx context.Context, host string) (*Credentials, error) {
+	userVar := env.UsernameVar
+	if "" == userVar {
+		userVar = DefaultEnvUsername
+	}
+	passVar := env.PasswordVar
+	if "" == passVar {
+		passVar = DefaultEnvPassword
+	}
+	username, ok := os.LookupEnv(userVar)
+	if false == ok {
+		return nil, fmt.Errorf("environment variable %s not set", userVar)
+	}
+	return &Credentials{username, os.Getenv(passVar)}, nil
+}
+
+// NetrcCredentials reads the credentials of the WBEM server host from a netrc file, re-reading it
+// on every call. Path defaults to $NETRC, then ~/.netrc.
+type NetrcCredentials struct {
+	Path string
+}
+
+func (netrc NetrcCredentials) path() (string, error) {
+	if "" != netrc.Path {
+		return netrc.Path, nil
+	}
+	if path := os.Getenv("NETRC"); "" != path {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if nil != err {
+		return "", err
+	}
+	return filepath.Join(home, ".netrc"), nil
+}
+
+func (netrc NetrcCredentials) GetCredentials(ctx context.Context, host string) (*Credentials, error) {
+	path, err := netrc.path()
+	if nil != err {
+		return nil, err
+	}
+	raw, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, err
+	}
+	creds := parseNetrc(string(raw), host)
+	if nil == creds {
+		return nil, fmt.Errorf("no credentials for %s in %s", host, path)
+	}
+	return creds, nil
+}
+
+// Looks up the machine entry of host in the content of a netrc file, falling back to the default
+// entry. Macro definitions are skipped.
+func parseNetrc(content string, host string) *Credentials {
+	var found, fallback *Credentials
+	var cur *Credentials
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		for j := 0; j < len(fields); j++ {
+			switch fields[j] {
+			case "machine":
+				cur = nil
+				if j+1 < len(fields) {
+					j++
+					if nil == found && strings.EqualFold(fields[j], host) {
+						found = &Credentials{}
+						cur = found
+					}
+				}
+			case "default":
+				cur = nil
+				if nil == fallback {
+					fallback = &Credentials{}
+					cur = fallback
+				}
+			case "login", "password", "account":
+				if j+1 < len(fields) {
+					j++
+					if nil != cur && "login" == fields[j-1] {
+						cur.Username = fields[j]
+					} else if nil != cur && "password" == fields[j-1] {
+						cur.Password = fields[j]
+					}
+				}
+			case "macdef":
+				for i+1 < len(lines) && "" != strings.TrimSpace(lines[i+1]) {
+					i++
+				}
+				j = len(fields)
+			}
+		}
+	}
+	if nil != found {
+		return found
+	}
+	return fallback
+}
+
+// CredentialsFunc adapts a function to a CredentialsProvider.
+type CredentialsFunc func(ctx context.Context, host string) (*Credentials, error)
+
+func (fn CredentialsFunc) GetCredentials(ctx context.Context, host string) (*Credentials, error) {
+	return fn(ctx, host)
+}
+
+// WithCredentialsProvider sets the source of the user credentials, overriding the userinfo of
+// the URL.
+func WithCredentialsProvider(provider CredentialsProvider) Option {
+	return func(conn *WBEMConnection) error {
+		if nil == provider {
+			return fmt.Errorf("nil credentials provider")
+		}
+		conn.creds = provider
+		return nil
+	}
+}
+
+// SetCredentialsProvider replaces the source of the user credentials.
+func (conn *WBEMConnection) SetCredentialsProvider(provider CredentialsProvider) {
+	if nil == provider {
+		provider = StaticCredentials{}
+	}
+	conn.creds = provider
+}
+
+func (conn *WBEMConnection) credentials(ctx context.Context) (*Credentials, error) {
+	creds, err := conn.creds.GetCredentials(ctx, conn.host)
+	if nil != err {
+		return nil, err
+	}
+	if nil == creds {
+		creds = &Credentials{}
+	}
+	return creds, nil
+}
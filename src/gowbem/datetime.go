@@ -0,0 +1,301 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CIMDateTime is a value of the CIM datetime type, either a timestamp or an interval:
+//      yyyymmddhhmmss.mmmmmmsutc    timestamp, s is + or - and utc the offset from UTC in minutes
+//      ddddddddhhmmss.mmmmmm:000    interval
+// Fields that are not significant are replaced with asterisks; in the microseconds only the
+// trailing digits may be. The value is kept as received, so that it formats back exactly.
+type CIMDateTime struct {
+	value string
+}
+
+// The layout of the fields in a datetime value.
+type dateTimeField struct {
+	start, end int
+	min, max   int
+}
+
+var timestampFields = []dateTimeField{
+	{0, 4, 0, 9999}, // year
+	{4, 6, 1, 12},   // month
+	{6, 8, 1, 31},   // day
+	{8, 10, 0, 23},  // hour
+	{10, 12, 0, 59}, // minute
+	{12, 14, 0, 59}, // second
+}
+
+var intervalFields = []dateTimeField{
+	{0, 8, 0, 99999999}, // days
+	{8, 10, 0, 23},      // hours
+	{10, 12, 0, 59},     // minutes
+	{12, 14, 0, 59},     // seconds
+}
+
+// ParseCIMDateTime parses a timestamp or an interval.
+func ParseCIMDateTime(s string) (CIMDateTime, error) {
+	invalid := func() (CIMDateTime, error) {
+		return CIMDateTime{}, newCIMErr(ErrTypeMismatch, fmt.Sprintf("invalid datetime value %q", s), nil)
+	}
+	if 25 != len(s) || '.' != s[14] {
+		return invalid()
+	}
+	fields := timestampFields
+	switch s[21] {
+	case '+', '-':
+		if _, ok := dateTimeDigits(s[22:25]); false == ok {
+			return invalid()
+		}
+	case ':':
+		if "000" != s[22:25] {
+			return invalid()
+		}
+		fields = intervalFields
+	default:
+		return invalid()
+	}
+	for _, f := range fields {
+		if "" == strings.Trim(s[f.start:f.end], "*") {
+			continue
+		}
+		i, ok := dateTimeDigits(s[f.start:f.end])
+		if false == ok || f.min > i || f.max < i {
+			return invalid()
+		}
+	}
+	micro := strings.TrimRight(s[15:21], "*")
+	if _, ok := dateTimeDigits(micro); false == ok && "" != micro {
+		return invalid()
+	}
+	dt := CIMDateTime{s}
+	if false == dt.IsInterval() && false == strings.ContainsRune(s[0:8], '*') {
+		// reject days that do not exist in the month, such as February 30
+		t, _ := dt.Time()
+		if t.Day() != dt.field(6, 8) {
+			return invalid()
+		}
+	}
+	return dt, nil
+}
+
+// Parses a field of digits only.
+func dateTimeDigits(s string) (int, bool) {
+	for _, c := range s {
+		if '0' > c || '9' < c {
+			return 0, false
+		}
+	}
+	i, err := strconv.Atoi(s)
+	return i, nil == err
+}
+
+// Returns a field, with asterisks read as zeros.
+func (dt CIMDateTime) field(start, end int) int {
+	i, _ := strconv.Atoi(strings.Replace(dt.value[start:end], "*", "0", -1))
+	return i
+}
+
+// NewCIMDateTime returns the timestamp of t, with the UTC offset of its location in minutes.
+func NewCIMDateTime(t time.Time) (CIMDateTime, error) {
+	_, offset := t.Zone()
+	offset /= 60
+	sign := '+'
+	if 0 > offset {
+		sign, offset = '-', -offset
+	}
+	if 0 > t.Year() || 9999 < t.Year() || 999 < offset {
+		return CIMDateTime{}, newCIMErr(ErrTypeMismatch, fmt.Sprintf("time %v is out of the range of datetime", t), nil)
+	}
+	return CIMDateTime{fmt.Sprintf("%04d%02d%02d%02d%02d%02d.%06d%c%03d",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond()/1000, sign, offset)}, nil
+}
+
+// NewCIMInterval returns the interval of d, truncated to microseconds.
+func NewCIMInterval(d time.Duration) (CIMDateTime, error) {
+	if 0 > d {
+		return CIMDateTime{}, newCIMErr(ErrTypeMismatch, fmt.Sprintf("negative interval %v", d), nil)
+	}
+	return newCIMInterval(int64(d / time.Microsecond)), nil
+}
+
+func newCIMInterval(micro int64) CIMDateTime {
+	secs := micro / 1000000
+	return CIMDateTime{fmt.Sprintf("%08d%02d%02d%02d.%06d:000",
+		secs/86400, secs/3600%24, secs/60%60, secs%60, micro%1000000)}
+}
+
+// String returns the datetime exactly as it was parsed.
+func (dt CIMDateTime) String() string {
+	return dt.value
+}
+
+// IsZero reports whether dt holds no value.
+func (dt CIMDateTime) IsZero() bool {
+	return "" == dt.value
+}
+
+// IsInterval reports whether dt is an interval rather than a timestamp.
+func (dt CIMDateTime) IsInterval() bool {
+	return 25 == len(dt.value) && ':' == dt.value[21]
+}
+
+// HasWildcards reports whether some fields of dt are replaced with asterisks.
+func (dt CIMDateTime) HasWildcards() bool {
+	return strings.ContainsRune(dt.value, '*')
+}
+
+// UTCOffset returns the offset of a timestamp from UTC in minutes.
+func (dt CIMDateTime) UTCOffset() int {
+	if dt.IsZero() || dt.IsInterval() {
+		return 0
+	}
+	offset := dt.field(22, 25)
+	if '-' == dt.value[21] {
+		offset = -offset
+	}
+	return offset
+}
+
+// Time converts a timestamp to a time.Time in a fixed zone of its UTC offset.
+// Fields replaced with asterisks are taken as their lowest value.
+func (dt CIMDateTime) Time() (time.Time, error) {
+	if dt.IsZero() || dt.IsInterval() {
+		return time.Time{}, newCIMErr(ErrTypeMismatch, fmt.Sprintf("datetime %q is not a timestamp", dt.value), nil)
+	}
+	month, day := dt.field(4, 6), dt.field(6, 8)
+	if 0 == month {
+		month = 1
+	}
+	if 0 == day {
+		day = 1
+	}
+	zone := time.FixedZone("", dt.UTCOffset()*60)
+	return time.Date(dt.field(0, 4), time.Month(month), day, dt.field(8, 10), dt.field(10, 12), dt.field(12, 14),
+		dt.field(15, 21)*1000, zone), nil
+}
+
+// Duration converts an interval to a time.Duration. Fields replaced with asterisks are taken as zero.
+// Intervals beyond the range of time.Duration, about 106751 days, fail.
+func (dt CIMDateTime) Duration() (time.Duration, error) {
+	if false == dt.IsInterval() {
+		return 0, newCIMErr(ErrTypeMismatch, fmt.Sprintf("datetime %q is not an interval", dt.value), nil)
+	}
+	days := dt.field(0, 8)
+	if int64(days) > int64((1<<63-1)/(24*time.Hour)) {
+		return 0, newCIMErr(ErrTypeMismatch, fmt.Sprintf("interval %q overflows time.Duration", dt.value), nil)
+	}
+	return time.Duration(days)*24*time.Hour + time.Duration(dt.field(8, 10))*time.Hour +
+		time.Duration(dt.field(10, 12))*time.Minute + time.Duration(dt.field(12, 14))*time.Second +
+		time.Duration(dt.field(15, 21))*time.Microsecond, nil
+}
+
+// Formats an interval as a duration of RFC 3339, appendix A.
+func (dt CIMDateTime) rfc3339Duration() string {
+	s := fmt.Sprintf("P%dDT%dH%dM%d", dt.field(0, 8), dt.field(8, 10), dt.field(10, 12), dt.field(12, 14))
+	if micro := dt.field(15, 21); 0 != micro {
+		s += strings.TrimRight(fmt.Sprintf(".%06d", micro), "0")
+	}
+	return s + "S"
+}
+
+// MarshalJSON writes a timestamp in the RFC 3339 format and an interval as a duration of RFC 3339, appendix A.
+// A datetime with asterisks has no such form and is written as the CIM datetime string.
+func (dt CIMDateTime) MarshalJSON() ([]byte, error) {
+	switch {
+	case dt.IsZero():
+		return []byte("null"), nil
+	case dt.HasWildcards():
+		return json.Marshal(dt.value)
+	case dt.IsInterval():
+		return json.Marshal(dt.rfc3339Duration())
+	}
+	t, err := dt.Time()
+	if nil != err {
+		return nil, err
+	}
+	return json.Marshal(t.Format(time.RFC3339Nano))
+}
+
+// UnmarshalJSON reads what MarshalJSON writes.
+func (dt *CIMDateTime) UnmarshalJSON(data []byte) error {
+	if "null" == string(data) {
+		*dt = CIMDateTime{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); nil != err {
+		return err
+	}
+	var err error
+	switch {
+	case strings.HasPrefix(s, "P"):
+		*dt, err = parseRFC3339Duration(s)
+	case 25 == len(s) && '.' == s[14]:
+		*dt, err = ParseCIMDateTime(s)
+	default:
+		var t time.Time
+		if t, err = time.Parse(time.RFC3339Nano, s); nil == err {
+			*dt, err = NewCIMDateTime(t)
+		}
+	}
+	return err
+}
+
+// Parses the days, hours, minutes and seconds of a duration of RFC 3339, appendix A.
+func parseRFC3339Duration(s string) (CIMDateTime, error) {
+	invalid := fmt.Errorf("invalid duration %q", s)
+	units := map[byte]int64{'D': 86400000000, 'H': 3600000000, 'M': 60000000, 'S': 1000000}
+	var micro int64
+	rest := strings.Replace(s[1:], "T", "", 1)
+	if "" == rest {
+		return CIMDateTime{}, invalid
+	}
+	for "" != rest {
+		i := strings.IndexAny(rest, "DHMS")
+		if 1 > i {
+			return CIMDateTime{}, invalid
+		}
+		whole, frac := rest[:i], ""
+		if dot := strings.IndexByte(whole, '.'); 0 <= dot && 'S' == rest[i] {
+			whole, frac = whole[:dot], (whole[dot+1:] + "000000")[:6]
+		}
+		n, err := strconv.ParseInt(whole, 10, 64)
+		if nil != err || 0 > n || n > (100000000*units['D']-1-micro)/units[rest[i]] {
+			return CIMDateTime{}, invalid
+		}
+		micro += n * units[rest[i]]
+		if "" != frac {
+			f, ok := dateTimeDigits(frac)
+			if false == ok {
+				return CIMDateTime{}, invalid
+			}
+			micro += int64(f)
+		}
+		rest = rest[i+1:]
+	}
+	return newCIMInterval(micro), nil
+}
@@ -0,0 +1,364 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Embedded objects, DSP0201 3.2.1.1 and DSP0004 5.6.3.28:
+// a string property, parameter or return value with the EmbeddedObject attribute, or the
+// EmbeddedObject or EmbeddedInstance qualifier, has the CIM-XML of an INSTANCE or CLASS as its
+// value, escaped as text:
+//      <PROPERTY NAME="Error" TYPE="string" EmbeddedObject="instance">
+//          <VALUE>&lt;INSTANCE CLASSNAME="CIM_Error"&gt;...&lt;/INSTANCE&gt;</VALUE>
+//      </PROPERTY>
+// On receive, such values are decoded into EmbeddedValue (EmbeddedValues for arrays), and the objects
+// nested in them in turn, while Value (ValueArray) keeps the text. Values that are not an INSTANCE
+// or CLASS are left as text only.
+// On send, an EmbeddedValue (EmbeddedValues) that is set takes the place of Value (ValueArray),
+// unless Value (ValueArray) was changed since it was received, or was set without decoding, in which
+// case the text is sent as it is; EmbeddedObject and the type default to "instance" or "object" and "string".
+
+// Returns whether the EmbeddedObject attribute or the qualifiers mark a value as embedded objects.
+func isEmbeddedValue(embeddedObject string, quals []Qualifier) bool {
+	if strings.EqualFold("object", embeddedObject) || strings.EqualFold("instance", embeddedObject) {
+		return true
+	}
+	for _, q := range quals {
+		switch {
+		case strings.EqualFold("EmbeddedInstance", q.Name):
+			return true
+		case strings.EqualFold("EmbeddedObject", q.Name) && (nil == q.Value || strings.EqualFold("true", q.Value.Value)):
+			return true
+		}
+	}
+	return false
+}
+
+// Decodes the CIM-XML text of an embedded INSTANCE or CLASS.
+func decodeEmbedded(text string) (*ValueObject, error) {
+	d := xml.NewDecoder(strings.NewReader(text))
+	for {
+		tok, err := d.Token()
+		if io.EOF == err {
+			return nil, errors.New("embedded object is empty")
+		}
+		if nil != err {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "INSTANCE":
+			inst := &Instance{}
+			if err := d.DecodeElement(inst, &start); nil != err {
+				return nil, err
+			}
+			return &ValueObject{Instance: inst}, nil
+		case "CLASS":
+			cls := &Class{}
+			if err := d.DecodeElement(cls, &start); nil != err {
+				return nil, err
+			}
+			return &ValueObject{Class: cls}, nil
+		default:
+			return nil, fmt.Errorf("embedded object is %s, not INSTANCE or CLASS", start.Name.Local)
+		}
+	}
+}
+
+// Returns the text of a VALUE or the texts of a VALUE.ARRAY, nil when there is neither.
+func valueTexts(val *Value, arry *ValueArray) []string {
+	switch {
+	case nil != val:
+		return []string{val.Value}
+	case nil != arry:
+		texts := make([]string, 0, len(arry.Value))
+		for _, v := range arry.Value {
+			texts = append(texts, v.Value)
+		}
+		return texts
+	}
+	return nil
+}
+
+// Reports whether the embedded objects stand for the VALUE or VALUE.ARRAY: when there is none, or
+// it still has the texts decoded on receive.
+func embeddedCurrent(val *Value, arry *ValueArray, decoded []string) bool {
+	texts := valueTexts(val, arry)
+	if nil == texts {
+		return true
+	}
+	if nil == decoded || len(texts) != len(decoded) {
+		return false
+	}
+	for i := range texts {
+		if texts[i] != decoded[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Decodes the values of an embedded object array, nil when one of them is not an object.
+func decodeEmbeddedArray(arry *ValueArray) []ValueObject {
+	if nil == arry || 0 != len(arry.ValueNull) {
+		return nil
+	}
+	objs := make([]ValueObject, 0, len(arry.Value))
+	for _, val := range arry.Value {
+		obj, err := decodeEmbedded(val.Value)
+		if nil != err {
+			return nil
+		}
+		objs = append(objs, *obj)
+	}
+	return objs
+}
+
+// Returns the CIM-XML text of an embedded object, and the EmbeddedObject attribute for it.
+func encodeEmbedded(obj *ValueObject) (string, string, error) {
+	var b strings.Builder
+	enc := xml.NewEncoder(&b)
+	var err error
+	var embeddedObject string
+	switch {
+	case nil != obj.Instance:
+		err = enc.EncodeElement(obj.Instance, xml.StartElement{Name: xml.Name{Local: "INSTANCE"}})
+		embeddedObject = "instance"
+	case nil != obj.Class:
+		err = enc.EncodeElement(obj.Class, xml.StartElement{Name: xml.Name{Local: "CLASS"}})
+		embeddedObject = "object"
+	default:
+		return "", "", errors.New("embedded object has neither an instance nor a class")
+	}
+	if nil != err {
+		return "", "", err
+	}
+	return b.String(), embeddedObject, nil
+}
+
+// Returns the VALUE.ARRAY of embedded objects, and the EmbeddedObject attribute for them,
+// "object" unless all of them are instances.
+func encodeEmbeddedArray(objs []ValueObject) (*ValueArray, string, error) {
+	arry := &ValueArray{}
+	embeddedObject := "instance"
+	for i := range objs {
+		text, kind, err := encodeEmbedded(&objs[i])
+		if nil != err {
+			return nil, "", err
+		}
+		if "instance" != kind {
+			embeddedObject = kind
+		}
+		arry.Value = append(arry.Value, Value{Value: text})
+	}
+	return arry, embeddedObject, nil
+}
+
+type property Property
+
+func (prop *Property) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	if err := d.DecodeElement((*property)(prop), &start); nil != err {
+		return err
+	}
+	prop.decoded = nil
+	if nil != prop.Value && isEmbeddedValue(prop.EmbeddedObject, prop.Qualifier) {
+		if prop.EmbeddedValue, _ = decodeEmbedded(prop.Value.Value); nil != prop.EmbeddedValue {
+			prop.decoded = valueTexts(prop.Value, nil)
+		}
+	}
+	return nil
+}
+
+// Returns the decoded embedded object, nil when Value was changed since.
+func (prop *Property) embedded() *ValueObject {
+	if embeddedCurrent(prop.Value, nil, prop.decoded) {
+		return prop.EmbeddedValue
+	}
+	return nil
+}
+
+func (prop Property) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if nil != prop.embedded() {
+		text, embeddedObject, err := encodeEmbedded(prop.EmbeddedValue)
+		if nil != err {
+			return fmt.Errorf("property %s: %w", prop.Name, err)
+		}
+		prop.Value = &Value{Value: text}
+		if "" == prop.EmbeddedObject {
+			prop.EmbeddedObject = embeddedObject
+		}
+		if "" == prop.Type {
+			prop.Type = string(CIMTypeString)
+		}
+	}
+	return e.EncodeElement(property(prop), start)
+}
+
+type propertyArray PropertyArray
+
+func (prop *PropertyArray) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	if err := d.DecodeElement((*propertyArray)(prop), &start); nil != err {
+		return err
+	}
+	prop.decoded = nil
+	if isEmbeddedValue(prop.EmbeddedObject, prop.Qualifier) {
+		if prop.EmbeddedValues = decodeEmbeddedArray(prop.ValueArray); nil != prop.EmbeddedValues {
+			prop.decoded = valueTexts(nil, prop.ValueArray)
+		}
+	}
+	return nil
+}
+
+// Returns the decoded embedded objects, nil when ValueArray was changed since.
+func (prop *PropertyArray) embedded() []ValueObject {
+	if embeddedCurrent(nil, prop.ValueArray, prop.decoded) {
+		return prop.EmbeddedValues
+	}
+	return nil
+}
+
+func (prop PropertyArray) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if nil != prop.embedded() {
+		arry, embeddedObject, err := encodeEmbeddedArray(prop.EmbeddedValues)
+		if nil != err {
+			return fmt.Errorf("property %s: %w", prop.Name, err)
+		}
+		prop.ValueArray = arry
+		if "" == prop.EmbeddedObject {
+			prop.EmbeddedObject = embeddedObject
+		}
+		if "" == prop.Type {
+			prop.Type = string(CIMTypeString)
+		}
+	}
+	return e.EncodeElement(propertyArray(prop), start)
+}
+
+type paramValue ParamValue
+
+func (param *ParamValue) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	if err := d.DecodeElement((*paramValue)(param), &start); nil != err {
+		return err
+	}
+	param.decoded = nil
+	if isEmbeddedValue(param.EmbeddedObject, nil) {
+		if nil != param.Value {
+			param.EmbeddedValue, _ = decodeEmbedded(param.Value.Value)
+		}
+		param.EmbeddedValues = decodeEmbeddedArray(param.ValueArray)
+		if nil != param.EmbeddedValue || nil != param.EmbeddedValues {
+			param.decoded = valueTexts(param.Value, param.ValueArray)
+		}
+	}
+	return nil
+}
+
+// Returns the decoded embedded objects, both nil when Value or ValueArray was changed since.
+func (param *ParamValue) embedded() (*ValueObject, []ValueObject) {
+	if embeddedCurrent(param.Value, param.ValueArray, param.decoded) {
+		return param.EmbeddedValue, param.EmbeddedValues
+	}
+	return nil, nil
+}
+
+func (param ParamValue) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	var embeddedObject string
+	obj, objs := param.embedded()
+	switch {
+	case nil != obj:
+		text, kind, err := encodeEmbedded(obj)
+		if nil != err {
+			return fmt.Errorf("parameter %s: %w", param.Name, err)
+		}
+		param.Value = &Value{Value: text}
+		embeddedObject = kind
+	case nil != objs:
+		arry, kind, err := encodeEmbeddedArray(objs)
+		if nil != err {
+			return fmt.Errorf("parameter %s: %w", param.Name, err)
+		}
+		param.ValueArray = arry
+		embeddedObject = kind
+	}
+	if "" != embeddedObject {
+		if "" == param.EmbeddedObject {
+			param.EmbeddedObject = embeddedObject
+		}
+		if "" == param.ParamType {
+			param.ParamType = string(CIMTypeString)
+		}
+	}
+	return e.EncodeElement(paramValue(param), start)
+}
+
+type returnValue ReturnValue
+
+func (ret *ReturnValue) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	if err := d.DecodeElement((*returnValue)(ret), &start); nil != err {
+		return err
+	}
+	ret.decoded = nil
+	if nil != ret.Value && isEmbeddedValue(ret.EmbeddedObject, nil) {
+		if ret.EmbeddedValue, _ = decodeEmbedded(ret.Value.Value); nil != ret.EmbeddedValue {
+			ret.decoded = valueTexts(ret.Value, nil)
+		}
+	}
+	return nil
+}
+
+func (ret ReturnValue) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if nil != ret.EmbeddedValue && embeddedCurrent(ret.Value, nil, ret.decoded) {
+		text, embeddedObject, err := encodeEmbedded(ret.EmbeddedValue)
+		if nil != err {
+			return fmt.Errorf("return value: %w", err)
+		}
+		ret.Value = &Value{Value: text}
+		if "" == ret.EmbeddedObject {
+			ret.EmbeddedObject = embeddedObject
+		}
+		if "" == ret.ParamType {
+			ret.ParamType = string(CIMTypeString)
+		}
+	}
+	return e.EncodeElement(returnValue(ret), start)
+}
+
+// NewEmbeddedInstanceParamValue returns the parameter of a method that takes an embedded instance,
+// such as Source of CIM_SoftwareInstallationService.InstallFromSoftwareIdentity.
+func NewEmbeddedInstanceParamValue(name string, inst *Instance) *ParamValue {
+	return &ParamValue{Name: name, ParamType: string(CIMTypeString), EmbeddedObject: "instance", EmbeddedValue: &ValueObject{Instance: inst}}
+}
+
+// NewEmbeddedInstanceArrayParamValue returns the parameter of a method that takes an array of embedded
+// instances.
+func NewEmbeddedInstanceArrayParamValue(name string, insts []*Instance) *ParamValue {
+	objs := make([]ValueObject, 0, len(insts))
+	for _, inst := range insts {
+		objs = append(objs, ValueObject{Instance: inst})
+	}
+	return &ParamValue{Name: name, ParamType: string(CIMTypeString), EmbeddedObject: "instance", EmbeddedValues: objs}
+}
@@ -0,0 +1,250 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+var embeddedEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// Returns the CIM-XML text of a CIM_Error with the Message, and the inner properties.
+func errorText(msg string, inner ...string) string {
+	return `<INSTANCE CLASSNAME="CIM_Error"><PROPERTY NAME="Message" TYPE="string"><VALUE>` +
+		embeddedEscaper.Replace(msg) + `</VALUE></PROPERTY>` + strings.Join(inner, "") + `</INSTANCE>`
+}
+
+// Returns the escaped VALUE of an embedded object text.
+func embeddedValue(text string) string {
+	return "<VALUE>" + embeddedEscaper.Replace(text) + "</VALUE>"
+}
+
+// Returns the Message of an embedded CIM_Error.
+func embeddedMessage(obj *ValueObject) string {
+	if nil == obj || nil == obj.Instance {
+		return "<none>"
+	}
+	return propertyString(obj.Instance, "Message")
+}
+
+// Returns the property of an instance by name, nil if there is none.
+func testProperty(inst *Instance, name string) *Property {
+	for i := range inst.Property {
+		if name == inst.Property[i].Name {
+			return &inst.Property[i]
+		}
+	}
+	return nil
+}
+
+func TestEmbeddedDecode(t *testing.T) {
+	var prop Property
+	raw := `<PROPERTY NAME="Error" TYPE="string" EmbeddedObject="instance">` + embeddedValue(errorText("a < b")) + `</PROPERTY>`
+	if err := xml.Unmarshal([]byte(raw), &prop); nil != err {
+		t.Fatalf("PROPERTY: %v", err)
+	}
+	if msg := embeddedMessage(prop.EmbeddedValue); "a < b" != msg {
+		t.Errorf("PROPERTY: Message %q", msg)
+	}
+
+	var arry PropertyArray
+	raw = `<PROPERTY.ARRAY NAME="Errors" TYPE="string"><QUALIFIER NAME="EmbeddedInstance" TYPE="string"><VALUE>CIM_Error</VALUE></QUALIFIER>` +
+		`<VALUE.ARRAY>` + embeddedValue(errorText("a")) + embeddedValue(errorText("b")) + `</VALUE.ARRAY></PROPERTY.ARRAY>`
+	if err := xml.Unmarshal([]byte(raw), &arry); nil != err {
+		t.Fatalf("PROPERTY.ARRAY: %v", err)
+	}
+	if 2 != len(arry.EmbeddedValues) || "b" != embeddedMessage(&arry.EmbeddedValues[1]) {
+		t.Errorf("PROPERTY.ARRAY: %+v", arry.EmbeddedValues)
+	}
+
+	var param ParamValue
+	raw = `<PARAMVALUE NAME="Error" PARAMTYPE="string" EmbeddedObject="instance">` + embeddedValue(errorText("failed")) + `</PARAMVALUE>`
+	if err := xml.Unmarshal([]byte(raw), &param); nil != err {
+		t.Fatalf("PARAMVALUE: %v", err)
+	}
+	if msg := embeddedMessage(param.EmbeddedValue); "failed" != msg {
+		t.Errorf("PARAMVALUE: Message %q", msg)
+	}
+
+	var params ParamValue
+	raw = `<PARAMVALUE NAME="Errors" PARAMTYPE="string" EmbeddedObject="instance"><VALUE.ARRAY>` +
+		embeddedValue(errorText("x")) + `</VALUE.ARRAY></PARAMVALUE>`
+	if err := xml.Unmarshal([]byte(raw), &params); nil != err {
+		t.Fatalf("PARAMVALUE array: %v", err)
+	}
+	if 1 != len(params.EmbeddedValues) || "x" != embeddedMessage(&params.EmbeddedValues[0]) {
+		t.Errorf("PARAMVALUE array: %+v", params.EmbeddedValues)
+	}
+
+	var ret ReturnValue
+	raw = `<RETURNVALUE PARAMTYPE="string" EmbeddedObject="instance">` + embeddedValue(errorText("done")) + `</RETURNVALUE>`
+	if err := xml.Unmarshal([]byte(raw), &ret); nil != err {
+		t.Fatalf("RETURNVALUE: %v", err)
+	}
+	if msg := embeddedMessage(ret.EmbeddedValue); "done" != msg {
+		t.Errorf("RETURNVALUE: Message %q", msg)
+	}
+
+	// a text that is not an object is kept as text only
+	var text Property
+	raw = `<PROPERTY NAME="Error" TYPE="string" EmbeddedObject="instance"><VALUE>not xml</VALUE></PROPERTY>`
+	if err := xml.Unmarshal([]byte(raw), &text); nil != err {
+		t.Fatalf("text: %v", err)
+	}
+	if nil != text.EmbeddedValue || nil == text.Value || "not xml" != text.Value.Value {
+		t.Errorf("text: %+v", text)
+	}
+}
+
+func TestEmbeddedNestedDecode(t *testing.T) {
+	cause := `<PROPERTY NAME="Cause" TYPE="string" EmbeddedObject="instance">` + embeddedValue(errorText("inner")) + `</PROPERTY>`
+	raw := `<PROPERTY NAME="Error" TYPE="string" EmbeddedObject="instance">` + embeddedValue(errorText("outer", cause)) + `</PROPERTY>`
+	var prop Property
+	if err := xml.Unmarshal([]byte(raw), &prop); nil != err {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if msg := embeddedMessage(prop.EmbeddedValue); "outer" != msg {
+		t.Fatalf("outer Message %q", msg)
+	}
+	inner := testProperty(prop.EmbeddedValue.Instance, "Cause")
+	if nil == inner {
+		t.Fatalf("no Cause in %+v", prop.EmbeddedValue.Instance)
+	}
+	if msg := embeddedMessage(inner.EmbeddedValue); "inner" != msg {
+		t.Errorf("inner Message %q", msg)
+	}
+}
+
+func TestEmbeddedEncode(t *testing.T) {
+	obj := &ValueObject{Instance: &Instance{ClassName: "CIM_Error", Property: []Property{{Name: "Message", Type: "string", Value: &Value{"a < b"}}}}}
+
+	out, err := xml.Marshal(&Property{Name: "Error", EmbeddedValue: obj})
+	if nil != err {
+		t.Fatalf("PROPERTY: %v", err)
+	}
+	var prop Property
+	if err = xml.Unmarshal(out, &prop); nil != err {
+		t.Fatalf("PROPERTY: unmarshal %s: %v", out, err)
+	}
+	if "instance" != prop.EmbeddedObject || "string" != prop.Type || "a < b" != embeddedMessage(prop.EmbeddedValue) {
+		t.Errorf("PROPERTY: got %+v from %s", prop, out)
+	}
+
+	out, err = xml.Marshal(&PropertyArray{Name: "Errors", EmbeddedValues: []ValueObject{*obj, *obj}})
+	if nil != err {
+		t.Fatalf("PROPERTY.ARRAY: %v", err)
+	}
+	var arry PropertyArray
+	if err = xml.Unmarshal(out, &arry); nil != err {
+		t.Fatalf("PROPERTY.ARRAY: unmarshal %s: %v", out, err)
+	}
+	if "instance" != arry.EmbeddedObject || 2 != len(arry.EmbeddedValues) {
+		t.Errorf("PROPERTY.ARRAY: got %+v from %s", arry, out)
+	}
+
+	out, err = xml.Marshal(NewEmbeddedInstanceParamValue("Error", obj.Instance))
+	if nil != err {
+		t.Fatalf("PARAMVALUE: %v", err)
+	}
+	var param ParamValue
+	if err = xml.Unmarshal(out, &param); nil != err {
+		t.Fatalf("PARAMVALUE: unmarshal %s: %v", out, err)
+	}
+	if "instance" != param.EmbeddedObject || "string" != param.ParamType || "a < b" != embeddedMessage(param.EmbeddedValue) {
+		t.Errorf("PARAMVALUE: got %+v from %s", param, out)
+	}
+}
+
+func TestEmbeddedEdited(t *testing.T) {
+	raw := `<PROPERTY NAME="Error" TYPE="string" EmbeddedObject="instance">` + embeddedValue(errorText("old")) + `</PROPERTY>`
+	resend := func(prop *Property) string {
+		out, err := xml.Marshal(prop)
+		if nil != err {
+			t.Fatalf("marshal: %v", err)
+		}
+		var back Property
+		if err = xml.Unmarshal(out, &back); nil != err {
+			t.Fatalf("unmarshal %s: %v", out, err)
+		}
+		return embeddedMessage(back.EmbeddedValue)
+	}
+
+	// the edited text is sent, not the object decoded from the old one
+	var prop Property
+	if err := xml.Unmarshal([]byte(raw), &prop); nil != err {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	prop.Value.Value = errorText("new text")
+	if msg := resend(&prop); "new text" != msg {
+		t.Errorf("edited Value: sent Message %q", msg)
+	}
+
+	// with the text unchanged, the edited object is sent
+	prop = Property{}
+	if err := xml.Unmarshal([]byte(raw), &prop); nil != err {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	testProperty(prop.EmbeddedValue.Instance, "Message").Value.Value = "new object"
+	if msg := resend(&prop); "new object" != msg {
+		t.Errorf("edited EmbeddedValue: sent Message %q", msg)
+	}
+
+	// both set without a receive, the text is sent
+	obj := &ValueObject{Instance: &Instance{ClassName: "CIM_Error"}}
+	if msg := resend(&Property{Name: "Error", Type: "string", EmbeddedObject: "instance", Value: &Value{errorText("text")}, EmbeddedValue: obj}); "text" != msg {
+		t.Errorf("both set: sent Message %q", msg)
+	}
+
+	// an edited parameter array is sent as edited
+	var param ParamValue
+	raw = `<PARAMVALUE NAME="Errors" PARAMTYPE="string" EmbeddedObject="instance"><VALUE.ARRAY>` +
+		embeddedValue(errorText("old")) + `</VALUE.ARRAY></PARAMVALUE>`
+	if err := xml.Unmarshal([]byte(raw), &param); nil != err {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	param.ValueArray.Value = append(param.ValueArray.Value, Value{errorText("added")})
+	out, err := xml.Marshal(&param)
+	if nil != err {
+		t.Fatalf("marshal: %v", err)
+	}
+	var back ParamValue
+	if err = xml.Unmarshal(out, &back); nil != err {
+		t.Fatalf("unmarshal %s: %v", out, err)
+	}
+	if 2 != len(back.EmbeddedValues) || "added" != embeddedMessage(&back.EmbeddedValues[1]) {
+		t.Errorf("edited ValueArray: sent %+v", back.EmbeddedValues)
+	}
+
+	// UnmarshalInstance reads the edited text too
+	var job testJob
+	prop = Property{}
+	inst := &Instance{ClassName: "CIM_Job", Property: []Property{{Name: "InstanceID", Type: "string", Value: &Value{"job1"}}}}
+	if err = xml.Unmarshal([]byte(`<PROPERTY NAME="LastError" TYPE="string" EmbeddedObject="instance">`+embeddedValue(errorText("old"))+`</PROPERTY>`), &prop); nil != err {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	prop.Value.Value = errorText("new text")
+	inst.Property = append(inst.Property, prop)
+	if err = UnmarshalInstance(inst, &job); nil != err {
+		t.Fatalf("UnmarshalInstance: %v", err)
+	}
+	if nil == job.LastError || "new text" != propertyString(job.LastError, "Message") {
+		t.Errorf("UnmarshalInstance: LastError %+v", job.LastError)
+	}
+}
@@ -18,7 +18,8 @@
 package gowbem
 
 import (
-	"crypto/tls"
+	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -39,10 +40,17 @@ type WBEMConnection struct {
 	scheme    string
 	host      string
 	port      int
-	username  string
-	password  string
+	creds     CredentialsProvider
 	namespace string
 	httpc     *http.Client
+	pull      *pullSupport
+	tlsOpts   *TLSOptions
+	transport http.RoundTripper
+	proxy     func(*http.Request) (*url.URL, error)
+	userAgent string
+	retry     RetryPolicy
+	logger    Logger
+	auth      *authState
 }
 
 func defaultPortMap(scheme string) int {
@@ -55,46 +63,89 @@ func defaultPortMap(scheme string) int {
 	return DefaultPortHttp
 }
 
+// Escapes the zone of an IPv6 literal as RFC 6874 requires, so that the URL of a link-local
+// address can be written as https://[fe80::1%eth0]:5989 as well as https://[fe80::1%25eth0]:5989.
+func escapeZone(urlstr string) string {
+	start := strings.IndexByte(urlstr, '[')
+	if 0 > start {
+		return urlstr
+	}
+	start++
+	end := strings.IndexByte(urlstr[start:], ']')
+	if 0 > end {
+		return urlstr
+	}
+	end += start
+	zone := strings.IndexByte(urlstr[start:end], '%')
+	if 0 > zone || strings.HasPrefix(urlstr[start+zone:end], "%25") {
+		return urlstr
+	}
+	zone += start
+	return urlstr[:zone] + "%25" + urlstr[zone+1:]
+}
+
+// NewWBEMConn creates a connection to the WBEM server at urlstr:
+//      <scheme>://[<username>[:<password>]@]<host>[:<port>][/<namespace>]
+// <scheme> is http or https, <host> a name or an IP address, with IPv6 literals in brackets.
 func NewWBEMConn(urlstr string) (*WBEMConnection, error) {
-	res, err := url.Parse(urlstr)
+	return NewWBEMConnWithOptions(urlstr)
+}
+
+// NewWBEMConnWithOptions creates a connection to the WBEM server at urlstr, configured by opts:
+//      conn, err := NewWBEMConnWithOptions("https://10.0.0.1/root/interop",
+//           WithCredentials("admin", "secret"),
+//           WithTimeout(30*time.Second),
+//           WithTLSOptions(&TLSOptions{CAFile: "/etc/pki/bmc-ca.pem"}),
+//      )
+func NewWBEMConnWithOptions(urlstr string, opts ...Option) (*WBEMConnection, error) {
+	res, err := url.Parse(escapeZone(urlstr))
 	if nil != err {
 		return nil, err
 	}
 
 	var conn WBEMConnection
-	host := strings.Split(res.Host, ":")
-	for i, sub := range host {
-		if 0 == i {
-			conn.host = sub
-		} else if 1 == i {
-			conn.port, _ = strconv.Atoi(sub)
-		}
-	}
 	conn.scheme = strings.ToLower(res.Scheme)
-	if nil != res.User {
-		conn.username = res.User.Username()
-		conn.password, _ = res.User.Password()
-	}
-	if 0 != len(res.Path) {
-		conn.namespace = string([]byte(res.Path)[1:])
-	}
 	if SchemeHttp != conn.scheme && SchemeHttps != conn.scheme {
-		conn.scheme = SchemeHttp
+		return nil, fmt.Errorf("unsupported scheme %q in %s, expecting %s or %s", res.Scheme, urlstr, SchemeHttp, SchemeHttps)
 	}
-	if 0 == conn.port {
+	conn.host = res.Hostname()
+	if "" == conn.host {
+		return nil, fmt.Errorf("missing host in %s", urlstr)
+	}
+	if port := res.Port(); "" != port {
+		conn.port, err = strconv.Atoi(port)
+		if nil != err || 0 >= conn.port || 65535 < conn.port {
+			return nil, fmt.Errorf("invalid port %q in %s", port, urlstr)
+		}
+	} else {
 		conn.port = defaultPortMap(conn.scheme)
 	}
+	if nil != res.User {
+		password, _ := res.User.Password()
+		conn.creds = StaticCredentials{res.User.Username(), password}
+	} else {
+		conn.creds = StaticCredentials{}
+	}
+	conn.namespace = strings.Trim(res.Path, "/")
 	if "" == conn.namespace {
 		conn.namespace = DefaultNamespace
 	}
 	conn.httpc = &http.Client{
 		Timeout: DefaultTimeout,
 	}
-	if SchemeHttps == conn.scheme {
-		conn.httpc.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	conn.proxy = http.ProxyFromEnvironment
+	conn.auth = &authState{}
+	conn.pull = &pullSupport{modes: make(map[string]int32)}
+	for _, opt := range opts {
+		err = opt(&conn)
+		if nil != err {
+			return nil, err
 		}
 	}
+	err = conn.setupTransport()
+	if nil != err {
+		return nil, err
+	}
 	return &conn, nil
 }
 
@@ -110,12 +161,27 @@ func (conn *WBEMConnection) GetHostPort() int {
 	return conn.port
 }
 
+// Returns <host>:<port>, with IPv6 literals in brackets.
+func (conn *WBEMConnection) hostPort() string {
+	return net.JoinHostPort(conn.host, strconv.Itoa(conn.port))
+}
+
+// GetUserName returns the user name of the static credentials, or an empty string if the
+// credentials come from another CredentialsProvider.
 func (conn *WBEMConnection) GetUserName() string {
-	return conn.username
+	if creds, ok := conn.creds.(StaticCredentials); ok {
+		return creds.Username
+	}
+	return ""
 }
 
+// GetPassword returns the password of the static credentials, or an empty string if the
+// credentials come from another CredentialsProvider.
 func (conn *WBEMConnection) GetPassword() string {
-	return conn.password
+	if creds, ok := conn.creds.(StaticCredentials); ok {
+		return creds.Password
+	}
+	return ""
 }
 
 func (conn *WBEMConnection) GetNamespace() string {
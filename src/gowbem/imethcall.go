@@ -18,11 +18,8 @@
 package gowbem
 
 import (
-	"bytes"
+	"context"
 	"encoding/xml"
-	"fmt"
-	"io/ioutil"
-	"net/http"
 	"strconv"
 	"strings"
 )
@@ -51,6 +48,16 @@ func (iMethCall *IMethodCall) appendParamVal(paramName string, param interface{}
 				},
 			},
 		)
+	case uint32:
+		iMethCall.IParamValue = append(
+			iMethCall.IParamValue,
+			IParamValue{
+				Name: paramName,
+				Value: &Value{
+					strconv.FormatUint(uint64(param), 10),
+				},
+			},
+		)
 	case string:
 		iMethCall.IParamValue = append(
 			iMethCall.IParamValue,
@@ -120,32 +127,11 @@ func (iMethCall *IMethodCall) appendParamVal(paramName string, param interface{}
 	}
 }
 
-func (conn *WBEMConnection) doPostIMethodCall(method string, content []byte) ([]byte, error) {
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s://%s:%d/%s", conn.scheme, conn.host, conn.port, DefaultRequestURI), bytes.NewReader(content))
-	if nil != err {
-		return nil, err
-	}
-	req.SetBasicAuth(conn.username, conn.password)
-	req.Header.Add("Content-Type", "application/xml; charset=\"utf-8\"")
-	req.Header.Add("Host", fmt.Sprintf("%s:%d", conn.host, conn.port))
-	req.Header.Add("Accept-Encoding", "identity")
-	req.Header["TE"] = append(req.Header["TE"], "trailers")
-	req.Header[HttpHdrOperation] = append(req.Header[HttpHdrOperation], "MethodCall")
-	req.Header[HttpHdrMethod] = append(req.Header[HttpHdrMethod], method)
-	req.Header[HttpHdrObject] = append(req.Header[HttpHdrObject], conn.namespace)
-	res, err := conn.httpc.Do(req)
-	if nil != err {
-		return nil, err
-	}
-	defer res.Body.Close()
-	if 200 != res.StatusCode {
-		err = fmt.Errorf("HTTP_ERR - %d - %s", res.StatusCode, res.Status)
-		return nil, err
-	}
-	return ioutil.ReadAll(res.Body)
+func (conn *WBEMConnection) doPostIMethodCall(ctx context.Context, method string, content []byte) ([]byte, error) {
+	return conn.doPost(ctx, method, conn.namespace, content, idempotentMethods[method])
 }
 
-func (conn *WBEMConnection) iMethodCall(call *IMethodCall) (*IMethodResponse, error) {
+func (conn *WBEMConnection) iMethodCall(ctx context.Context, call *IMethodCall) (*IMethodResponse, error) {
 	if nil == call {
 		return nil, conn.oops(ErrFailed, "")
 	}
@@ -165,14 +151,14 @@ func (conn *WBEMConnection) iMethodCall(call *IMethodCall) (*IMethodResponse, er
 		return nil, err
 	}
 	raw = append([]byte(xml.Header), raw...)
-	raw, err = conn.doPostIMethodCall(call.Name, raw)
+	raw, err = conn.doPostIMethodCall(ctx, call.Name, raw)
 	if nil != err {
 		return nil, err
 	}
 	cim = CIM{}
 	err = xml.Unmarshal(raw, &cim)
 	if nil != err {
-		loggerPrint(string(raw))
+		conn.logf("%s", raw)
 		return nil, err
 	}
 	if nil == cim.Message || nil == cim.Message.SimpleRsp || nil == cim.Message.SimpleRsp.IMethodResponse {
@@ -0,0 +1,261 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Canonical returns a copy of the instance name in a canonical form, so that names of the same
+// instance compare equal whatever the server sent:
+//      class names, key names, hosts and namespaces are lower-cased, as CIM names are case-insensitive
+//      key bindings are sorted by name
+//      boolean and numeric key values are formatted the same way, e.g. TRUE as true, 0x1F as 31
+//      the TYPE of key values is dropped, as it is optional
+//      reference keys are canonicalized recursively
+// String values keep their case.
+func (name *InstanceName) Canonical() *InstanceName {
+	if nil == name {
+		return nil
+	}
+	canon := &InstanceName{ClassName: strings.ToLower(name.ClassName)}
+	if nil != name.KeyValue {
+		canon.KeyValue = name.KeyValue.canonical()
+	}
+	if nil != name.ValueReference {
+		canon.ValueReference = name.ValueReference.canonical()
+	}
+	for _, key := range name.KeyBinding {
+		binding := KeyBinding{Name: strings.ToLower(key.Name)}
+		if nil != key.KeyValue {
+			binding.KeyValue = key.KeyValue.canonical()
+		}
+		if nil != key.ValueReference {
+			binding.ValueReference = key.ValueReference.canonical()
+		}
+		canon.KeyBinding = append(canon.KeyBinding, binding)
+	}
+	sort.SliceStable(canon.KeyBinding, func(i, j int) bool {
+		return canon.KeyBinding[i].Name < canon.KeyBinding[j].Name
+	})
+	return canon
+}
+
+// Key returns the WBEM URI of the canonical form, which is stable and fit for a map key.
+func (name *InstanceName) Key() string {
+	return name.Canonical().String()
+}
+
+// Equal reports whether the two names refer to the same instance, comparing their canonical forms.
+func (name *InstanceName) Equal(other *InstanceName) bool {
+	if nil == name || nil == other {
+		return name == other
+	}
+	return name.Key() == other.Key()
+}
+
+func (kv *KeyValue) canonical() *KeyValue {
+	canon := &KeyValue{ValueType: kv.ValueType, KeyValue: kv.KeyValue}
+	if "" == canon.ValueType {
+		canon.ValueType = "string"
+	}
+	if typ := CIMType(kv.Type); typ.IsValid() && CIMTypeReference != typ {
+		// the TYPE is more precise than the VALUETYPE
+		switch {
+		case CIMTypeBoolean == typ:
+			canon.ValueType = "boolean"
+		case typ.IsInteger(), CIMTypeReal32 == typ, CIMTypeReal64 == typ:
+			canon.ValueType = "numeric"
+		}
+		if v, err := ParseCIMValue(typ, kv.KeyValue); nil == err {
+			if s, err := FormatCIMValue(typ, v); nil == err {
+				canon.KeyValue = s
+			}
+		}
+		return canon
+	}
+	value := strings.TrimSpace(kv.KeyValue)
+	switch canon.ValueType {
+	case "boolean":
+		canon.KeyValue = strings.ToLower(value)
+	case "numeric":
+		digits, base := integerDigits(value)
+		if i, err := strconv.ParseInt(digits, base, 64); nil == err {
+			canon.KeyValue = strconv.FormatInt(i, 10)
+		} else if u, err := strconv.ParseUint(strings.TrimPrefix(digits, "+"), base, 64); nil == err {
+			canon.KeyValue = strconv.FormatUint(u, 10)
+		} else if f, err := strconv.ParseFloat(value, 64); nil == err {
+			canon.KeyValue = formatReal(f, 64)
+		}
+	}
+	return canon
+}
+
+func (ns *LocalNamespacePath) canonical() *LocalNamespacePath {
+	if nil == ns {
+		return nil
+	}
+	return NewLocalNamespacePath(strings.ToLower(ns.String()))
+}
+
+func (path *NamespacePath) canonical() *NamespacePath {
+	if nil == path {
+		return nil
+	}
+	canon := &NamespacePath{LocalNamespacePath: path.LocalNamespacePath.canonical()}
+	if nil != path.Host {
+		canon.Host = &Host{strings.ToLower(path.Host.Host)}
+	}
+	return canon
+}
+
+func (name *ClassName) canonical() *ClassName {
+	if nil == name {
+		return nil
+	}
+	return &ClassName{strings.ToLower(name.Name)}
+}
+
+func (ref *ValueReference) canonical() *ValueReference {
+	canon := &ValueReference{
+		ClassName:    ref.ClassName.canonical(),
+		InstanceName: ref.InstanceName.Canonical(),
+	}
+	if nil != ref.InstancePath {
+		canon.InstancePath = &InstancePath{ref.InstancePath.NamespacePath.canonical(), ref.InstancePath.InstanceName.Canonical()}
+	}
+	if nil != ref.LocalInstancePath {
+		canon.LocalInstancePath = &LocalInstancePath{ref.LocalInstancePath.LocalNamespacePath.canonical(), ref.LocalInstancePath.InstanceName.Canonical()}
+	}
+	if nil != ref.ClassPath {
+		canon.ClassPath = &ClassPath{ref.ClassPath.NamespacePath.canonical(), ref.ClassPath.ClassName.canonical()}
+	}
+	if nil != ref.LocalClassPath {
+		canon.LocalClassPath = &LocalClassPath{ref.LocalClassPath.LocalNamespacePath.canonical(), ref.LocalClassPath.ClassName.canonical()}
+	}
+	return canon
+}
+
+// InstanceNameSet is a set of instance names, where names of the same instance count once.
+// The zero value is an empty set.
+type InstanceNameSet struct {
+	names map[string]*InstanceName
+}
+
+// NewInstanceNameSet returns a set of the names.
+func NewInstanceNameSet(names ...*InstanceName) *InstanceNameSet {
+	set := &InstanceNameSet{}
+	for _, name := range names {
+		set.Add(name)
+	}
+	return set
+}
+
+// Add adds the name, returning false when the set already has it.
+func (set *InstanceNameSet) Add(name *InstanceName) bool {
+	if nil == set.names {
+		set.names = make(map[string]*InstanceName)
+	}
+	key := name.Key()
+	if _, ok := set.names[key]; ok {
+		return false
+	}
+	set.names[key] = name
+	return true
+}
+
+// Contains reports whether the set has the name.
+func (set *InstanceNameSet) Contains(name *InstanceName) bool {
+	_, ok := set.names[name.Key()]
+	return ok
+}
+
+// Remove removes the name.
+func (set *InstanceNameSet) Remove(name *InstanceName) {
+	delete(set.names, name.Key())
+}
+
+// Len returns the number of names in the set.
+func (set *InstanceNameSet) Len() int {
+	return len(set.names)
+}
+
+// Names returns the first name added for each instance, sorted by their keys.
+func (set *InstanceNameSet) Names() []*InstanceName {
+	keys := make([]string, 0, len(set.names))
+	for key := range set.names {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	names := make([]*InstanceName, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, set.names[key])
+	}
+	return names
+}
+
+// InstanceNameMap maps instance names to values, where names of the same instance are the same key.
+// The zero value is an empty map.
+type InstanceNameMap struct {
+	entries map[string]instanceNameEntry
+}
+
+type instanceNameEntry struct {
+	name  *InstanceName
+	value interface{}
+}
+
+// Set maps the name to value.
+func (m *InstanceNameMap) Set(name *InstanceName, value interface{}) {
+	if nil == m.entries {
+		m.entries = make(map[string]instanceNameEntry)
+	}
+	m.entries[name.Key()] = instanceNameEntry{name, value}
+}
+
+// Get returns the value of the name, and whether there is one.
+func (m *InstanceNameMap) Get(name *InstanceName) (interface{}, bool) {
+	entry, ok := m.entries[name.Key()]
+	return entry.value, ok
+}
+
+// Delete removes the name.
+func (m *InstanceNameMap) Delete(name *InstanceName) {
+	delete(m.entries, name.Key())
+}
+
+// Len returns the number of names in the map.
+func (m *InstanceNameMap) Len() int {
+	return len(m.entries)
+}
+
+// Range calls f for each name and value, sorted by the keys of the names, until f returns false.
+func (m *InstanceNameMap) Range(f func(name *InstanceName, value interface{}) bool) {
+	keys := make([]string, 0, len(m.entries))
+	for key := range m.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if false == f(m.entries[key].name, m.entries[key].value) {
+			return
+		}
+	}
+}
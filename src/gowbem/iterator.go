@@ -0,0 +1,386 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxObjectCount uint32 = 100
+
+	// Time allowed for the CloseEnumeration sent when the context of an iterator is done.
+	DefaultCloseTimeout time.Duration = 10 * time.Second
+)
+
+const (
+	pullModeUnknown int32 = iota
+	pullModeSupported
+	pullModeUnsupported
+)
+
+// Whether the WBEM server supports the pull operations, by open method, as a server may support
+// OpenEnumerateInstances but not OpenQueryInstances.
+type pullSupport struct {
+	mu    sync.Mutex
+	modes map[string]int32
+}
+
+func (pull *pullSupport) mode(method string) int32 {
+	pull.mu.Lock()
+	defer pull.mu.Unlock()
+	return pull.modes[method]
+}
+
+func (pull *pullSupport) setMode(method string, mode int32) {
+	pull.mu.Lock()
+	defer pull.mu.Unlock()
+	pull.modes[method] = mode
+}
+
+// Options of the pull operations used by the instance iterators. They are ignored when the
+// iterator falls back to the traditional operations.
+type IterOptions struct {
+	MaxObjectCount   uint32
+	OperationTimeout *uint32
+	ContinueOnError  bool
+}
+
+type pullOpenFunc func(ctx context.Context, maxObjectCount uint32) ([]ValueInstanceWithPath, *EnumerationContext, error)
+type pullNextFunc func(ctx context.Context, enumCtx *EnumerationContext, maxObjectCount uint32) ([]ValueInstanceWithPath, *EnumerationContext, error)
+type enumerateFunc func(ctx context.Context) ([]ValueInstanceWithPath, error)
+
+// InstanceIterator is a cursor over the instances returned by an enumeration. It uses the pull
+// operations when the WBEM server supports them, and falls back to the traditional operation
+// when the server answers CIM_ERR_NOT_SUPPORTED. The result is remembered per connection and open operation.
+// When the context of the iterator is done, the open enumeration session is closed on the server.
+//      it := conn.IterEnumerateInstances(&ClassName{Name: "CIM_LogEntry"}, true, false, nil, nil)
+//      defer it.Close()
+//      for it.Next() {
+//           inst := it.Instance()
+//      }
+//      if nil != it.Err() {
+//      }
+type InstanceIterator struct {
+	ctx            context.Context
+	conn           *WBEMConnection
+	method         string
+	open           pullOpenFunc
+	pull           pullNextFunc
+	enumerate      enumerateFunc
+	maxObjectCount uint32
+	enumCtx        *EnumerationContext
+	buffer         []ValueInstanceWithPath
+	current        *ValueInstanceWithPath
+	started        bool
+	done           bool
+	err            error
+}
+
+func (conn *WBEMConnection) newInstanceIterator(ctx context.Context, opts *IterOptions, method string, open pullOpenFunc, pull pullNextFunc, enumerate enumerateFunc) *InstanceIterator {
+	it := &InstanceIterator{
+		ctx:            ctx,
+		conn:           conn,
+		method:         method,
+		open:           open,
+		pull:           pull,
+		enumerate:      enumerate,
+		maxObjectCount: DefaultMaxObjectCount,
+	}
+	if nil != opts && 0 != opts.MaxObjectCount {
+		it.maxObjectCount = opts.MaxObjectCount
+	}
+	return it
+}
+
+func (it *InstanceIterator) start() {
+	it.started = true
+	if pullModeUnsupported != it.conn.pull.mode(it.method) {
+		instances, enumCtx, err := it.open(it.ctx, it.maxObjectCount)
+		if nil == err {
+			it.conn.pull.setMode(it.method, pullModeSupported)
+			it.buffer = instances
+			it.enumCtx = enumCtx
+			return
+		}
+		if false == errors.Is(err, ErrNotSupported) {
+			it.err = err
+			it.done = true
+			return
+		}
+		it.conn.logf("%s not supported by %s, falling back", it.method, it.conn.host)
+		it.conn.pull.setMode(it.method, pullModeUnsupported)
+	}
+	it.buffer, it.err = it.enumerate(it.ctx)
+	it.done = true
+}
+
+// Next advances the iterator to the next instance. It returns false when the enumeration is
+// exhausted or an error occurred, which is then available from Err.
+func (it *InstanceIterator) Next() bool {
+	if nil == it.err && nil != it.ctx.Err() {
+		it.err = it.ctx.Err()
+		it.Close()
+		return false
+	}
+	if false == it.started {
+		it.start()
+	}
+	for 0 == len(it.buffer) {
+		if true == it.done || nil == it.enumCtx || true == it.enumCtx.EndOfSequence {
+			it.done = true
+			it.current = nil
+			return false
+		}
+		instances, enumCtx, err := it.pull(it.ctx, it.enumCtx, it.maxObjectCount)
+		if nil != err {
+			it.err = err
+			if nil != it.ctx.Err() {
+				it.Close()
+			}
+			it.done = true
+			it.current = nil
+			return false
+		}
+		it.buffer = instances
+		it.enumCtx = enumCtx
+	}
+	it.current = &it.buffer[0]
+	it.buffer = it.buffer[1:]
+	return true
+}
+
+// Instance returns the instance at the current position of the iterator. The InstancePath is nil
+// for query results, which carry no instance paths.
+func (it *InstanceIterator) Instance() *ValueInstanceWithPath {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *InstanceIterator) Err() error {
+	return it.err
+}
+
+// Close releases the enumeration session on the WBEM server if it is still open. It is safe to
+// call Close more than once and after the enumeration is exhausted. If the context of the
+// iterator is already done, CloseEnumeration is sent with DefaultCloseTimeout instead.
+func (it *InstanceIterator) Close() error {
+	it.done = true
+	it.buffer = nil
+	it.current = nil
+	if nil == it.enumCtx || true == it.enumCtx.EndOfSequence {
+		return nil
+	}
+	enumCtx := it.enumCtx
+	it.enumCtx = nil
+	ctx := it.ctx
+	if nil != ctx.Err() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), DefaultCloseTimeout)
+		defer cancel()
+	}
+	return it.conn.CloseEnumerationContext(ctx, enumCtx)
+}
+
+func (conn *WBEMConnection) namespacePath() *NamespacePath {
+	var ns []Namespace = []Namespace{}
+	for _, sub := range strings.Split(conn.namespace, "/") {
+		ns = append(ns, Namespace{Name: sub})
+	}
+	return &NamespacePath{
+		Host:               &Host{conn.hostPort()},
+		LocalNamespacePath: &LocalNamespacePath{ns},
+	}
+}
+
+func objectsWithPathToInstances(objects []ValueObjectWithPath) []ValueInstanceWithPath {
+	var instances []ValueInstanceWithPath
+	for _, obj := range objects {
+		if nil != obj.Instance {
+			instances = append(instances, ValueInstanceWithPath{
+				InstancePath: obj.InstancePath,
+				Instance:     obj.Instance,
+			})
+		}
+	}
+	return instances
+}
+
+func instancesToInstancesWithPath(instances []Instance) []ValueInstanceWithPath {
+	var res []ValueInstanceWithPath
+	for i := range instances {
+		res = append(res, ValueInstanceWithPath{Instance: &instances[i]})
+	}
+	return res
+}
+
+// IterEnumerateInstances iterates the instances of a CIM class in the target namespace, using
+// OpenEnumerateInstances/PullInstancesWithPath or EnumerateInstances.
+func (conn *WBEMConnection) IterEnumerateInstances(className *ClassName, deepInheritance bool, includeClassOrigin bool, propertyList []string, opts *IterOptions) *InstanceIterator {
+	return conn.IterEnumerateInstancesContext(context.Background(), className, deepInheritance, includeClassOrigin, propertyList, opts)
+}
+
+// IterEnumerateInstancesContext is the same as IterEnumerateInstances, with ctx carried to every operation of the iteration.
+func (conn *WBEMConnection) IterEnumerateInstancesContext(ctx context.Context, className *ClassName, deepInheritance bool, includeClassOrigin bool, propertyList []string, opts *IterOptions) *InstanceIterator {
+	if nil == opts {
+		opts = &IterOptions{}
+	}
+	return conn.newInstanceIterator(
+		ctx,
+		opts,
+		"OpenEnumerateInstances",
+		func(ctx context.Context, maxObjectCount uint32) ([]ValueInstanceWithPath, *EnumerationContext, error) {
+			return conn.OpenEnumerateInstancesContext(ctx, className, deepInheritance, includeClassOrigin, propertyList, nil, nil, opts.OperationTimeout, opts.ContinueOnError, maxObjectCount)
+		},
+		conn.PullInstancesWithPathContext,
+		func(ctx context.Context) ([]ValueInstanceWithPath, error) {
+			namedInstances, err := conn.EnumerateInstancesContext(ctx, className, deepInheritance, includeClassOrigin, propertyList)
+			if nil != err {
+				return nil, err
+			}
+			var instances []ValueInstanceWithPath
+			for _, namedInstance := range namedInstances {
+				instances = append(instances, ValueInstanceWithPath{
+					InstancePath: &InstancePath{
+						NamespacePath: conn.namespacePath(),
+						InstanceName:  namedInstance.InstanceName,
+					},
+					Instance: namedInstance.Instance,
+				})
+			}
+			return instances, nil
+		},
+	)
+}
+
+// IterAssociators iterates the instances associated with a source instance, using
+// OpenAssociatorInstances/PullInstancesWithPath or Associators.
+func (conn *WBEMConnection) IterAssociators(instanceName *InstanceName, assocClass *ClassName, resultClass *ClassName, role, resultRole *string, includeClassOrigin bool, propertyList []string, opts *IterOptions) *InstanceIterator {
+	return conn.IterAssociatorsContext(context.Background(), instanceName, assocClass, resultClass, role, resultRole, includeClassOrigin, propertyList, opts)
+}
+
+// IterAssociatorsContext is the same as IterAssociators, with ctx carried to every operation of the iteration.
+func (conn *WBEMConnection) IterAssociatorsContext(ctx context.Context, instanceName *InstanceName, assocClass *ClassName, resultClass *ClassName, role, resultRole *string, includeClassOrigin bool, propertyList []string, opts *IterOptions) *InstanceIterator {
+	if nil == opts {
+		opts = &IterOptions{}
+	}
+	return conn.newInstanceIterator(
+		ctx,
+		opts,
+		"OpenAssociatorInstances",
+		func(ctx context.Context, maxObjectCount uint32) ([]ValueInstanceWithPath, *EnumerationContext, error) {
+			return conn.OpenAssociatorInstancesContext(ctx, instanceName, assocClass, resultClass, role, resultRole, includeClassOrigin, propertyList, nil, nil, opts.OperationTimeout, opts.ContinueOnError, maxObjectCount)
+		},
+		conn.PullInstancesWithPathContext,
+		func(ctx context.Context) ([]ValueInstanceWithPath, error) {
+			objects, err := conn.AssociatorsContext(ctx, &ObjectName{InstanceName: instanceName}, assocClass, resultClass, role, resultRole, includeClassOrigin, propertyList)
+			if nil != err {
+				return nil, err
+			}
+			return objectsWithPathToInstances(objects), nil
+		},
+	)
+}
+
+// IterReferences iterates the association instances that refer to a source instance, using
+// OpenReferenceInstances/PullInstancesWithPath or References.
+func (conn *WBEMConnection) IterReferences(instanceName *InstanceName, resultClass *ClassName, role *string, includeClassOrigin bool, propertyList []string, opts *IterOptions) *InstanceIterator {
+	return conn.IterReferencesContext(context.Background(), instanceName, resultClass, role, includeClassOrigin, propertyList, opts)
+}
+
+// IterReferencesContext is the same as IterReferences, with ctx carried to every operation of the iteration.
+func (conn *WBEMConnection) IterReferencesContext(ctx context.Context, instanceName *InstanceName, resultClass *ClassName, role *string, includeClassOrigin bool, propertyList []string, opts *IterOptions) *InstanceIterator {
+	if nil == opts {
+		opts = &IterOptions{}
+	}
+	return conn.newInstanceIterator(
+		ctx,
+		opts,
+		"OpenReferenceInstances",
+		func(ctx context.Context, maxObjectCount uint32) ([]ValueInstanceWithPath, *EnumerationContext, error) {
+			return conn.OpenReferenceInstancesContext(ctx, instanceName, resultClass, role, includeClassOrigin, propertyList, nil, nil, opts.OperationTimeout, opts.ContinueOnError, maxObjectCount)
+		},
+		conn.PullInstancesWithPathContext,
+		func(ctx context.Context) ([]ValueInstanceWithPath, error) {
+			objects, err := conn.ReferencesContext(ctx, &ObjectName{InstanceName: instanceName}, resultClass, role, includeClassOrigin, propertyList)
+			if nil != err {
+				return nil, err
+			}
+			return objectsWithPathToInstances(objects), nil
+		},
+	)
+}
+
+// IterQuery iterates the instances matching a query, using OpenQueryInstances/PullInstances or
+// ExecQuery.
+func (conn *WBEMConnection) IterQuery(queryLanguage string, query string, opts *IterOptions) *InstanceIterator {
+	return conn.IterQueryContext(context.Background(), queryLanguage, query, opts)
+}
+
+// IterQueryContext is the same as IterQuery, with ctx carried to every operation of the iteration.
+func (conn *WBEMConnection) IterQueryContext(ctx context.Context, queryLanguage string, query string, opts *IterOptions) *InstanceIterator {
+	if nil == opts {
+		opts = &IterOptions{}
+	}
+	return conn.newInstanceIterator(
+		ctx,
+		opts,
+		"OpenQueryInstances",
+		func(ctx context.Context, maxObjectCount uint32) ([]ValueInstanceWithPath, *EnumerationContext, error) {
+			instances, enumCtx, err := conn.OpenQueryInstancesContext(ctx, queryLanguage, query, opts.OperationTimeout, opts.ContinueOnError, maxObjectCount)
+			return instancesToInstancesWithPath(instances), enumCtx, err
+		},
+		func(ctx context.Context, enumCtx *EnumerationContext, maxObjectCount uint32) ([]ValueInstanceWithPath, *EnumerationContext, error) {
+			instances, enumCtx, err := conn.PullInstancesContext(ctx, enumCtx, maxObjectCount)
+			return instancesToInstancesWithPath(instances), enumCtx, err
+		},
+		func(ctx context.Context) ([]ValueInstanceWithPath, error) {
+			obj, err := conn.ExecQueryContext(ctx, queryLanguage, query)
+			if nil != err || nil == obj {
+				return nil, err
+			}
+			var instances []ValueInstanceWithPath
+			for _, valObj := range obj.ValueObject {
+				if nil != valObj.Instance {
+					instances = append(instances, ValueInstanceWithPath{Instance: valObj.Instance})
+				}
+			}
+			for _, valObj := range obj.ValueObjectWithLocalPath {
+				if nil != valObj.Instance {
+					var instPath *InstancePath
+					if nil != valObj.LocalInstancePath {
+						instPath = &InstancePath{
+							NamespacePath: &NamespacePath{
+								Host:               conn.namespacePath().Host,
+								LocalNamespacePath: valObj.LocalInstancePath.LocalNamespacePath,
+							},
+							InstanceName: valObj.LocalInstancePath.InstanceName,
+						}
+					}
+					instances = append(instances, ValueInstanceWithPath{InstancePath: instPath, Instance: valObj.Instance})
+				}
+			}
+			instances = append(instances, objectsWithPathToInstances(obj.ValueObjectWithPath)...)
+			return instances, nil
+		},
+	)
+}
@@ -0,0 +1,199 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultKeepAliveInterval is how often KeepAlive checks a subscription when no interval is given.
+const DefaultKeepAliveInterval time.Duration = time.Minute
+
+// KeepAliveEventKind is what KeepAlive did to keep a subscription.
+type KeepAliveEventKind int
+
+const (
+	// A filter, destination or subscription was missing, and was created again.
+	KeepAliveRecreated KeepAliveEventKind = 1 + iota
+
+	// The SubscriptionDuration of the subscription was extended before it expired.
+	KeepAliveRenewed
+
+	// A check failed, and is tried again at the next interval.
+	KeepAliveFailed
+)
+
+func (kind KeepAliveEventKind) String() string {
+	switch kind {
+	case KeepAliveRecreated:
+		return "recreated"
+	case KeepAliveRenewed:
+		return "renewed"
+	case KeepAliveFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("KeepAliveEventKind(%d)", int(kind))
+}
+
+// KeepAliveEvent reports a recovery or a failure of KeepAlive.
+type KeepAliveEvent struct {
+	Kind KeepAliveEventKind
+	// the instance recreated or renewed
+	InstanceName *InstanceName
+	// the error of a failed check
+	Err error
+}
+
+func (event *KeepAliveEvent) String() string {
+	if KeepAliveFailed == event.Kind {
+		return fmt.Sprintf("%s: %v", event.Kind, event.Err)
+	}
+	return fmt.Sprintf("%s %s", event.Kind, event.InstanceName.String())
+}
+
+// Returns the non-NULL unsigned value of the named property, false if it has none.
+func uintProperty(inst *Instance, name string) (uint64, bool) {
+	i, err := strconv.ParseUint(strings.TrimSpace(propertyString(inst, name)), 10, 64)
+	return i, nil == err
+}
+
+// KeepAlive subscribes as Subscribe does, then checks every interval, DefaultKeepAliveInterval
+// if 0, with GetInstance that the filter, destination and subscription still exist, which a WBEM
+// server may lose when it restarts:
+//      what is missing is created again
+//      with a Duration, the SubscriptionDuration is extended by Duration once the SubscriptionTimeRemaining
+//      is less than 2 intervals, so that the subscription does not expire
+// Each recovery, and each check that fails, is reported to events, if not nil. KeepAlive returns
+// the error of the first Subscribe, or ctx.Err() once ctx is done.
+//      ctx, cancel := context.WithCancel(context.Background())
+//      go m.KeepAlive(ctx, "alerts", filter, l.DestinationURL("10.0.0.2"), time.Minute, func(event *KeepAliveEvent) {
+//           log.Println(event)
+//      })
+//      ...
+//      cancel()
+func (m *SubscriptionManager) KeepAlive(ctx context.Context, name string, filter *IndicationFilter, destination string, interval time.Duration, events func(*KeepAliveEvent)) error {
+	if 0 >= interval {
+		interval = DefaultKeepAliveInterval
+	}
+	sub, err := m.SubscribeContext(ctx, name, filter, destination)
+	if nil != err {
+		return err
+	}
+	report := func(event KeepAliveEvent) {
+		if nil != events {
+			events(&event)
+		}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		if err := m.keepAlive(ctx, sub, name, filter, destination, interval, report); nil != err && nil == ctx.Err() {
+			report(KeepAliveEvent{Kind: KeepAliveFailed, Err: err})
+		}
+	}
+}
+
+// Checks a subscription once, recreating what is missing, and renewing it when it expires soon.
+func (m *SubscriptionManager) keepAlive(ctx context.Context, sub *Subscription, name string, filter *IndicationFilter, destination string, interval time.Duration, report func(KeepAliveEvent)) error {
+	conn := m.interop()
+	exists := func(instName *InstanceName) (*Instance, error) {
+		found, err := conn.GetInstanceContext(ctx, instName, false, nil)
+		if errors.Is(err, ErrNotFound) || nil == err && 0 == len(found) {
+			return nil, nil
+		}
+		if nil != err {
+			return nil, err
+		}
+		return &found[0], nil
+	}
+
+	inst, err := exists(sub.Filter)
+	if nil != err {
+		return err
+	}
+	recreated := false
+	if nil == inst {
+		if sub.Filter, err = m.CreateFilterContext(ctx, name, filter); nil != err {
+			return err
+		}
+		recreated = true
+		report(KeepAliveEvent{Kind: KeepAliveRecreated, InstanceName: sub.Filter})
+	}
+	if inst, err = exists(sub.Destination); nil != err {
+		return err
+	}
+	if nil == inst {
+		if sub.Destination, err = m.CreateDestinationContext(ctx, name, destination); nil != err {
+			return err
+		}
+		recreated = true
+		report(KeepAliveEvent{Kind: KeepAliveRecreated, InstanceName: sub.Destination})
+	}
+	if inst, err = exists(sub.Subscription); nil != err {
+		return err
+	}
+	if nil == inst || recreated {
+		// a subscription does not outlive its filter and destination on every server
+		instName, err := m.CreateSubscriptionContext(ctx, sub.Filter, sub.Destination)
+		if nil != err {
+			return err
+		}
+		if nil == inst || false == instName.Equal(sub.Subscription) {
+			sub.Subscription = instName
+			report(KeepAliveEvent{Kind: KeepAliveRecreated, InstanceName: sub.Subscription})
+			return nil
+		}
+	}
+
+	if 0 >= m.Duration {
+		return nil
+	}
+	duration, ok := uintProperty(inst, "SubscriptionDuration")
+	remaining, known := uintProperty(inst, "SubscriptionTimeRemaining")
+	if false == ok || false == known || time.Duration(remaining)*time.Second >= 2*interval {
+		return nil
+	}
+	// the duration counts from the start of the subscription
+	var elapsed uint64
+	if duration > remaining {
+		elapsed = duration - remaining
+	}
+	val, err := NewCIMValue(CIMTypeUint64, elapsed+uint64(m.Duration/time.Second))
+	if nil != err {
+		return err
+	}
+	if err = inst.SetValue("SubscriptionDuration", val); nil != err {
+		return err
+	}
+	err = conn.ModifyInstanceContext(ctx, &ValueNamedInstance{InstanceName: sub.Subscription, Instance: inst}, []string{"SubscriptionDuration"})
+	if nil != err {
+		return err
+	}
+	report(KeepAliveEvent{Kind: KeepAliveRenewed, InstanceName: sub.Subscription})
+	return nil
+}
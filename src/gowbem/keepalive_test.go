@@ -0,0 +1,173 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Checks a subscription once, returning the events reported, as "kind class".
+func checkKeepAlive(t *testing.T, m *SubscriptionManager, sub *Subscription, interval time.Duration) []string {
+	events := []string{}
+	report := func(event KeepAliveEvent) {
+		events = append(events, event.Kind.String()+" "+event.InstanceName.ClassName)
+	}
+	if err := m.keepAlive(context.Background(), sub, "alerts", testFilter, "https://10.0.0.2:5990", interval, report); nil != err {
+		t.Fatalf("keepAlive: %v", err)
+	}
+	return events
+}
+
+func TestKeepAliveRecreated(t *testing.T) {
+	srv, conn, done := newInteropTest(t)
+	defer done()
+	m := newTestManager(conn, "a")
+	sub, err := m.Subscribe("alerts", testFilter, "https://10.0.0.2:5990")
+	if nil != err {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	if events := checkKeepAlive(t, m, sub, time.Minute); 0 != len(events) {
+		t.Errorf("nothing lost: events %q", events)
+	}
+
+	srv.remove(sub.Subscription)
+	events := checkKeepAlive(t, m, sub, time.Minute)
+	if 1 != len(events) || "recreated "+ClassIndicationSubscription != events[0] {
+		t.Errorf("subscription lost: events %q", events)
+	}
+	if got := srv.keys(ClassIndicationSubscription); 1 != len(got) || "GoWbem:a:alerts -> GoWbem:a:alerts" != got[0] {
+		t.Errorf("subscription lost: subscriptions %q", got)
+	}
+
+	srv.remove(sub.Filter)
+	srv.remove(sub.Destination)
+	srv.remove(sub.Subscription)
+	events = checkKeepAlive(t, m, sub, time.Minute)
+	want := []string{"recreated " + ClassIndicationFilter, "recreated " + ClassListenerDestination, "recreated " + ClassIndicationSubscription}
+	if len(want) != len(events) {
+		t.Fatalf("all lost: events %q, want %q", events, want)
+	}
+	for i := range want {
+		if want[i] != events[i] {
+			t.Errorf("all lost: events %q, want %q", events, want)
+			break
+		}
+	}
+	if "GoWbem:a:alerts" != srv.property(sub.Filter, "Name") || "GoWbem:a:alerts" != srv.property(sub.Destination, "Name") {
+		t.Errorf("all lost: recreated %v and %v", sub.Filter, sub.Destination)
+	}
+}
+
+func TestKeepAliveRenewed(t *testing.T) {
+	srv, conn, done := newInteropTest(t)
+	defer done()
+	m := newTestManager(conn, "a")
+	m.Duration = time.Hour
+	sub, err := m.Subscribe("alerts", testFilter, "https://10.0.0.2:5990")
+	if nil != err {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if "3600" != srv.property(sub.Subscription, "SubscriptionDuration") {
+		t.Fatalf("SubscriptionDuration %q", srv.property(sub.Subscription, "SubscriptionDuration"))
+	}
+
+	// 2 intervals or more remaining
+	srv.set(sub.Subscription, "SubscriptionTimeRemaining", "120")
+	if events := checkKeepAlive(t, m, sub, time.Minute); 0 != len(events) || 0 != srv.count("ModifyInstance") {
+		t.Errorf("120s remaining: events %q, %d ModifyInstance", events, srv.count("ModifyInstance"))
+	}
+
+	// renewed for Duration from now: 3500s elapsed + 3600s
+	srv.set(sub.Subscription, "SubscriptionTimeRemaining", "100")
+	events := checkKeepAlive(t, m, sub, time.Minute)
+	if 1 != len(events) || "renewed "+ClassIndicationSubscription != events[0] {
+		t.Errorf("100s remaining: events %q", events)
+	}
+	if got := srv.property(sub.Subscription, "SubscriptionDuration"); "7100" != got {
+		t.Errorf("100s remaining: SubscriptionDuration %q, want 7100", got)
+	}
+
+	// without a Duration, nothing is renewed
+	m.Duration = 0
+	srv.set(sub.Subscription, "SubscriptionTimeRemaining", "10")
+	if events = checkKeepAlive(t, m, sub, time.Minute); 0 != len(events) {
+		t.Errorf("no Duration: events %q", events)
+	}
+}
+
+func TestKeepAliveCancel(t *testing.T) {
+	srv, conn, done := newInteropTest(t)
+	defer done()
+	m := newTestManager(conn, "a")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan KeepAliveEvent, 16)
+	result := make(chan error, 1)
+	go func() {
+		result <- m.KeepAlive(ctx, "alerts", testFilter, "https://10.0.0.2:5990", 10*time.Millisecond, func(event *KeepAliveEvent) {
+			select {
+			case events <- *event:
+			default:
+			}
+		})
+	}()
+
+	// wait for the subscription, then lose it
+	deadline := time.After(5 * time.Second)
+	for 0 == len(srv.keys(ClassIndicationSubscription)) {
+		select {
+		case err := <-result:
+			t.Fatalf("KeepAlive returned %v", err)
+		case <-deadline:
+			t.Fatal("no subscription")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	srv.mu.Lock()
+	var lost InstanceName
+	for i := range srv.names {
+		if ClassIndicationSubscription == srv.names[i].ClassName {
+			lost = srv.names[i]
+		}
+	}
+	srv.mu.Unlock()
+	srv.remove(&lost)
+	select {
+	case event := <-events:
+		if KeepAliveRecreated != event.Kind || ClassIndicationSubscription != event.InstanceName.ClassName {
+			t.Errorf("event %v", &event)
+		}
+	case <-deadline:
+		t.Fatal("subscription not recreated")
+	}
+
+	cancel()
+	select {
+	case err := <-result:
+		if false == errors.Is(err, context.Canceled) {
+			t.Errorf("KeepAlive returned %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("KeepAlive did not return on cancel")
+	}
+}
@@ -0,0 +1,392 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultListenerAddr is the address an IndicationListener listens on when none is given.
+	DefaultListenerAddr string = ":59988"
+
+	// ExportIndication is the export method that delivers an indication to a WBEM listener.
+	ExportIndication string = "ExportIndication"
+
+	// The largest export request an IndicationListener reads.
+	maxExportRequestSize int64 = 16 << 20
+)
+
+// Indication is an indication received by an IndicationListener.
+type Indication struct {
+	// the NewIndication instance, with its embedded objects decoded
+	Instance *Instance
+	// the address of the WBEM server that exported it, <host>:<port>
+	Source string
+	// the ID of the MESSAGE of the export request
+	MessageID string
+	// the CORRELATORs of the export request, if any
+	Correlators []Correlator
+	// when the listener received it
+	Received time.Time
+}
+
+// IndicationListener is a WBEM listener, DSP0200 CIM export over HTTP: it receives the ExportIndication
+// requests of WBEM servers, answers them, and passes each indication to Handler, or else sends it
+// to Indications. The export request is answered once Handler returns, or once Indications takes
+// the indication; a listener with neither drops the indications.
+//      l := NewIndicationListener(":5990")
+//      l.Handler = func(ind *Indication) { fmt.Println(ind.Source, ind.Instance.ClassName) }
+//      err := l.Start()
+//      ...
+//      l.Stop()
+// With TLS set, the listener serves https, and may require client certificates of the WBEM servers.
+// With Credentials set, it requires Basic credentials on the export requests, the ones Credentials
+// returns for the host of the WBEM server.
+// With Sequence set, the indications go through it, which reports the indications lost or repeated,
+// and may hold them back to pass them on in order; the listener passes on the ones its MaxHold releases,
+// unless Sequence.Released is set, and the ones still held when it stops.
+// IndicationListener is a http.Handler as well, to be served on a http.Server of the caller.
+type IndicationListener struct {
+	Handler     func(*Indication)
+	Indications chan *Indication
+	TLS         *ListenerTLSOptions
+	Credentials CredentialsProvider
+	Sequence    *SequenceTracker
+	// the debug messages, if not nil
+	Logger Logger
+
+	addr     string
+	mu       sync.Mutex
+	server   *http.Server
+	listener net.Listener
+	done     chan struct{}
+}
+
+// NewIndicationListener returns a listener on addr, DefaultListenerAddr if empty, that sends the
+// indications to its buffered Indications channel unless a Handler is set.
+func NewIndicationListener(addr string) *IndicationListener {
+	if "" == addr {
+		addr = DefaultListenerAddr
+	}
+	return &IndicationListener{
+		addr:        addr,
+		Indications: make(chan *Indication, 64),
+	}
+}
+
+func (l *IndicationListener) logf(format string, args ...interface{}) {
+	if nil != l.Logger {
+		l.Logger.Printf(format, args...)
+	} else {
+		loggerPrint(format, args...)
+	}
+}
+
+// Start listens on the address of the listener and serves the export requests in the background.
+func (l *IndicationListener) Start() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if nil != l.server {
+		return errors.New("indication listener already started")
+	}
+	var cfg *tls.Config
+	if nil != l.TLS {
+		var err error
+		cfg, err = l.TLS.tlsConfig()
+		if nil != err {
+			return err
+		}
+	}
+	ln, err := net.Listen("tcp", l.addr)
+	if nil != err {
+		return err
+	}
+	if nil != cfg {
+		ln = tls.NewListener(ln, cfg)
+	}
+	if nil != l.Sequence && nil == l.Sequence.Released {
+		l.Sequence.Released = l.deliverReleased
+	}
+	l.listener = ln
+	l.server = &http.Server{Handler: l}
+	l.done = make(chan struct{})
+	go func(server *http.Server, done chan struct{}) {
+		defer close(done)
+		if err := server.Serve(ln); nil != err && http.ErrServerClosed != err {
+			l.logf("indication listener on %s stopped: %v", ln.Addr(), err)
+		}
+	}(l.server, l.done)
+	return nil
+}
+
+// Addr returns the address the listener listens on once started, which tells the port chosen
+// for an address such as ":0"; nil before Start.
+func (l *IndicationListener) Addr() net.Addr {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if nil == l.listener {
+		return nil
+	}
+	return l.listener.Addr()
+}
+
+// DestinationURL returns the Destination of a CIM_ListenerDestinationCIMXML that has the WBEM servers
+// export to the listener at host, a name or an IP address:
+//      https://10.0.0.2:59988
+// The scheme is https when the listener serves TLS, and the port is the one it listens on.
+func (l *IndicationListener) DestinationURL(host string) string {
+	scheme := SchemeHttp
+	if nil != l.TLS {
+		scheme = SchemeHttps
+	}
+	port := 0
+	if addr, ok := l.Addr().(*net.TCPAddr); ok {
+		port = addr.Port
+	} else if _, p, err := net.SplitHostPort(l.addr); nil == err {
+		port, _ = strconv.Atoi(p)
+	}
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+// Stop closes the listener, waits up to 5 seconds for the export requests in progress,
+// and passes on the indications Sequence still holds.
+func (l *IndicationListener) Stop() error {
+	l.mu.Lock()
+	server, done := l.server, l.done
+	l.server, l.listener, l.done = nil, nil, nil
+	l.mu.Unlock()
+	if nil == server {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	if nil != err {
+		err = server.Close()
+	}
+	<-done
+	if nil != l.Sequence {
+		l.deliverReleased(l.Sequence.Flush())
+	}
+	return err
+}
+
+// Returns a CIM extension header of the request, from its namespace prefix in a M-POST request:
+//      Man: http://www.dmtf.org/cim/mapping/http/v1.0 ; ns=73
+//      73-CIMExport: MethodRequest
+func exportHeader(req *http.Request, name string) string {
+	if "M-POST" == req.Method {
+		for _, man := range req.Header["Man"] {
+			i := strings.Index(man, "ns=")
+			if 0 > i {
+				continue
+			}
+			if v := req.Header.Get(strings.TrimSpace(man[i+3:]) + "-" + name); "" != v {
+				return strings.TrimSpace(v)
+			}
+		}
+	}
+	return strings.TrimSpace(req.Header.Get(name))
+}
+
+// Answers a request that is not a CIM export message with a HTTP error and the CIMError header.
+func refuseExport(writer http.ResponseWriter, status int, cimError HttpCIMError) {
+	writer.Header().Set(HttpHdrError, string(cimError))
+	http.Error(writer, http.StatusText(status), status)
+}
+
+// Returns whether the request has the Basic credentials expected from its WBEM server.
+func (l *IndicationListener) authorized(req *http.Request) bool {
+	username, password, ok := req.BasicAuth()
+	if false == ok {
+		return false
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if nil != err {
+		host = req.RemoteAddr
+	}
+	creds, err := l.Credentials.GetCredentials(req.Context(), host)
+	if nil != err || nil == creds {
+		l.logf("no credentials for export requests from %s: %v", host, err)
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(username), []byte(creds.Username))
+	passOK := subtle.ConstantTimeCompare([]byte(password), []byte(creds.Password))
+	return 1 == userOK&passOK
+}
+
+func (l *IndicationListener) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	if "POST" != req.Method && "M-POST" != req.Method {
+		writer.Header().Set("Allow", "POST, M-POST")
+		http.Error(writer, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if nil != l.Credentials && false == l.authorized(req) {
+		l.logf("export request from %s not authorized", req.RemoteAddr)
+		writer.Header().Set("WWW-Authenticate", `Basic realm="CIMListener"`)
+		http.Error(writer, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	if "MethodRequest" != exportHeader(req, HttpHdrExport) {
+		l.logf("export request from %s without %s: MethodRequest", req.RemoteAddr, HttpHdrExport)
+		refuseExport(writer, http.StatusBadRequest, HttpErrUnsupportedOperation)
+		return
+	}
+	if "" != exportHeader(req, HttpHdrExportBatch) {
+		refuseExport(writer, http.StatusNotImplemented, HttpErrMultipleRequestsUnsupported)
+		return
+	}
+	if v := exportHeader(req, HttpHdrProtocolVersion); "" != v && false == strings.HasPrefix(v, "1.") {
+		refuseExport(writer, http.StatusNotImplemented, HttpErrUnsupportedProtocolVersion)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(writer, req.Body, maxExportRequestSize))
+	req.Body.Close()
+	if nil != err {
+		l.logf("export request from %s: %v", req.RemoteAddr, err)
+		refuseExport(writer, http.StatusBadRequest, HttpErrRequestNotValid)
+		return
+	}
+	cim := CIM{}
+	if err = xml.Unmarshal(body, &cim); nil != err {
+		l.logf("export request from %s: %v", req.RemoteAddr, err)
+		refuseExport(writer, http.StatusBadRequest, HttpErrRequestNotWellFormed)
+		return
+	}
+	switch {
+	case false == strings.HasPrefix(cim.CIMVersion, "2."):
+		refuseExport(writer, http.StatusNotImplemented, HttpErrUnsupportedCIMVersion)
+		return
+	case false == strings.HasPrefix(cim.DTDVersion, "2."):
+		refuseExport(writer, http.StatusNotImplemented, HttpErrUnsupportedDTDVersion)
+		return
+	case nil == cim.Message:
+		refuseExport(writer, http.StatusBadRequest, HttpErrRequestNotValid)
+		return
+	case nil != cim.Message.MultiExpReq:
+		refuseExport(writer, http.StatusNotImplemented, HttpErrMultipleRequestsUnsupported)
+		return
+	case nil == cim.Message.SimpleExpReq || nil == cim.Message.SimpleExpReq.ExpMethodCall:
+		refuseExport(writer, http.StatusBadRequest, HttpErrRequestNotValid)
+		return
+	}
+	call := cim.Message.SimpleExpReq.ExpMethodCall
+	if false == strings.EqualFold(call.Name, exportHeader(req, HttpHdrExportMethod)) {
+		refuseExport(writer, http.StatusBadRequest, HttpErrHeaderMismatch)
+		return
+	}
+
+	rsp := &ExpMethodResponse{Name: call.Name}
+	ind, cimErr := exportedIndication(call)
+	if nil != cimErr {
+		l.logf("export request from %s: %v", req.RemoteAddr, cimErr)
+		rsp.Error = &Error{Code: fmt.Sprint(int(cimErr.ErrCode)), Description: cimErr.ErrDesc}
+	} else {
+		ind.Source = req.RemoteAddr
+		ind.MessageID = cim.Message.ID
+		ind.Correlators = cim.Message.SimpleExpReq.Correlator
+		ind.Received = time.Now()
+		inds := []*Indication{ind}
+		if nil != l.Sequence {
+			inds = l.Sequence.Track(ind)
+		}
+		rsp.IReturnValue = &IReturnValue{}
+		for _, ind := range inds {
+			if false == l.deliver(req.Context(), ind) {
+				rsp.IReturnValue = nil
+				rsp.Error = &Error{Code: fmt.Sprint(int(ErrFailed)), Description: "indication not delivered"}
+			}
+		}
+	}
+
+	raw, err := xml.Marshal(&CIM{
+		CIMVersion: "2.0",
+		DTDVersion: "2.0",
+		Message: &Message{
+			ID:              cim.Message.ID,
+			ProtocolVersion: "1.0",
+			SimpleExpRsp:    &SimpleExpRsp{ExpMethodResponse: rsp},
+		},
+	})
+	if nil != err {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/xml; charset=\"utf-8\"")
+	writer.Header().Set(HttpHdrExport, "MethodResponse")
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(append([]byte(xml.Header), raw...))
+}
+
+// Returns the indication of an ExportIndication call, or the error to answer it with.
+func exportedIndication(call *ExpMethodCall) (*Indication, *CIMErr) {
+	if false == strings.EqualFold(ExportIndication, call.Name) {
+		err := newCIMErr(ErrNotSupported, fmt.Sprintf("export method %s not supported", call.Name), nil)
+		return nil, &err
+	}
+	for _, param := range call.ExpParamValue {
+		if strings.EqualFold("NewIndication", param.Name) && nil != param.Instance {
+			return &Indication{Instance: param.Instance}, nil
+		}
+	}
+	err := newCIMErr(ErrInvalidParameter, "missing NewIndication instance", nil)
+	return nil, &err
+}
+
+// Passes on the indications a SequenceTracker releases outside of an export request,
+// waiting up to 5 seconds for the channel.
+func (l *IndicationListener) deliverReleased(inds []*Indication) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, ind := range inds {
+		if false == l.deliver(ctx, ind) {
+			l.logf("indication from %s dropped", ind.Source)
+		}
+	}
+}
+
+// Passes an indication to the handler or the channel, false when the request ends first.
+func (l *IndicationListener) deliver(ctx context.Context, ind *Indication) bool {
+	if nil != l.Handler {
+		l.Handler(ind)
+		return true
+	}
+	if nil == l.Indications {
+		l.logf("indication from %s dropped", ind.Source)
+		return true
+	}
+	select {
+	case l.Indications <- ind:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,282 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testExportRequest = `<?xml version="1.0" encoding="utf-8"?>
+<CIM CIMVERSION="2.0" DTDVERSION="2.0"><MESSAGE ID="1001" PROTOCOLVERSION="1.0"><SIMPLEEXPREQ>` +
+	`<EXPMETHODCALL NAME="ExportIndication"><EXPPARAMVALUE NAME="NewIndication">` +
+	`<INSTANCE CLASSNAME="CIM_AlertIndication"><PROPERTY NAME="Message" TYPE="string"><VALUE>disk failed</VALUE></PROPERTY></INSTANCE>` +
+	`</EXPPARAMVALUE></EXPMETHODCALL></SIMPLEEXPREQ></MESSAGE></CIM>`
+
+// A certificate generated for a test, with its parsed form to sign others.
+type testCert struct {
+	tls  tls.Certificate
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// Returns a certificate for 127.0.0.1 and localhost, signed by parent, or self-signed if parent is nil.
+func newTestCert(t *testing.T, name string, isCA bool, usage x509.ExtKeyUsage, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatalf("generate key: %v", err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if isCA {
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+	}
+	signer, signerKey := template, key
+	if nil != parent {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if nil != err {
+		t.Fatalf("create certificate %s: %v", name, err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if nil != err {
+		t.Fatalf("parse certificate %s: %v", name, err)
+	}
+	return &testCert{
+		tls:  tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert},
+		cert: cert,
+		key:  key,
+	}
+}
+
+func (c *testCert) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(c.cert)
+	return pool
+}
+
+func (c *testCert) fingerprint() string {
+	sum := sha256.Sum256(c.cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Starts a listener on a free port of 127.0.0.1, counting the indications it receives.
+func startTestListener(t *testing.T, opts *ListenerTLSOptions, creds CredentialsProvider) (*IndicationListener, *int, func()) {
+	var mu sync.Mutex
+	received := 0
+	l := NewIndicationListener("127.0.0.1:0")
+	l.TLS = opts
+	l.Credentials = creds
+	l.Logger = testLogger{t}
+	l.Handler = func(ind *Indication) {
+		mu.Lock()
+		defer mu.Unlock()
+		if "CIM_AlertIndication" == ind.Instance.ClassName {
+			received++
+		}
+	}
+	if err := l.Start(); nil != err {
+		t.Fatalf("Start: %v", err)
+	}
+	return l, &received, func() { l.Stop() }
+}
+
+type testLogger struct {
+	t *testing.T
+}
+
+func (logger testLogger) Printf(format string, v ...interface{}) {
+	logger.t.Logf(format, v...)
+}
+
+// Sends the export request to the listener, and returns the response, with the body read.
+func export(l *IndicationListener, roots *x509.CertPool, clientCert *testCert, username, password string) (*http.Response, error) {
+	cfg := &tls.Config{RootCAs: roots}
+	if nil != clientCert {
+		cfg.Certificates = []tls.Certificate{clientCert.tls}
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}, Timeout: 10 * time.Second}
+	req, err := http.NewRequest("POST", l.DestinationURL("127.0.0.1"), strings.NewReader(testExportRequest))
+	if nil != err {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/xml; charset=\"utf-8\"")
+	req.Header.Set(HttpHdrExport, "MethodRequest")
+	req.Header.Set(HttpHdrExportMethod, ExportIndication)
+	if "" != username {
+		req.SetBasicAuth(username, password)
+	}
+	res, err := client.Do(req)
+	if nil != err {
+		return nil, err
+	}
+	defer res.Body.Close()
+	_, err = io.Copy(ioutil.Discard, res.Body)
+	return res, err
+}
+
+func TestListenerServerCertificate(t *testing.T) {
+	ca := newTestCert(t, "test CA", true, 0, nil)
+	server := newTestCert(t, "listener", false, x509.ExtKeyUsageServerAuth, ca)
+	l, received, stop := startTestListener(t, &ListenerTLSOptions{Certificates: []tls.Certificate{server.tls}}, nil)
+	defer stop()
+
+	if false == strings.HasPrefix(l.DestinationURL("127.0.0.1"), "https://") {
+		t.Errorf("destination %s is not https", l.DestinationURL("127.0.0.1"))
+	}
+	res, err := export(l, ca.pool(), nil, "", "")
+	if nil != err {
+		t.Fatalf("export: %v", err)
+	}
+	if http.StatusOK != res.StatusCode || 1 != *received {
+		t.Errorf("status %d, %d indications received, want 200 and 1", res.StatusCode, *received)
+	}
+	if _, err = export(l, x509.NewCertPool(), nil, "", ""); nil == err {
+		t.Errorf("export accepted a listener certificate of an unknown CA")
+	}
+}
+
+func TestListenerClientCertificateCA(t *testing.T) {
+	ca := newTestCert(t, "test CA", true, 0, nil)
+	server := newTestCert(t, "listener", false, x509.ExtKeyUsageServerAuth, ca)
+	client := newTestCert(t, "wbem server", false, x509.ExtKeyUsageClientAuth, ca)
+	other := newTestCert(t, "other", false, x509.ExtKeyUsageClientAuth, nil)
+	opts := &ListenerTLSOptions{Certificates: []tls.Certificate{server.tls}, ClientCAs: ca.pool()}
+	cfg, err := opts.tlsConfig()
+	if nil != err {
+		t.Fatalf("tlsConfig: %v", err)
+	}
+	if tls.RequireAndVerifyClientCert != cfg.ClientAuth {
+		t.Errorf("ClientAuth %v, want RequireAndVerifyClientCert", cfg.ClientAuth)
+	}
+	l, received, stop := startTestListener(t, opts, nil)
+	defer stop()
+
+	res, err := export(l, ca.pool(), client, "", "")
+	if nil != err {
+		t.Fatalf("export with a client certificate of the CA: %v", err)
+	}
+	if http.StatusOK != res.StatusCode || 1 != *received {
+		t.Errorf("status %d, %d indications received, want 200 and 1", res.StatusCode, *received)
+	}
+	if _, err = export(l, ca.pool(), nil, "", ""); nil == err {
+		t.Errorf("export accepted without a client certificate")
+	}
+	if _, err = export(l, ca.pool(), other, "", ""); nil == err {
+		t.Errorf("export accepted with a client certificate of another CA")
+	}
+	if 1 != *received {
+		t.Errorf("%d indications received, want 1", *received)
+	}
+}
+
+func TestListenerClientCertificatePinned(t *testing.T) {
+	ca := newTestCert(t, "test CA", true, 0, nil)
+	server := newTestCert(t, "listener", false, x509.ExtKeyUsageServerAuth, ca)
+	signed := newTestCert(t, "wbem server", false, x509.ExtKeyUsageClientAuth, ca)
+	pinned := newTestCert(t, "self-signed bmc", false, x509.ExtKeyUsageClientAuth, nil)
+	unpinned := newTestCert(t, "other bmc", false, x509.ExtKeyUsageClientAuth, nil)
+	opts := &ListenerTLSOptions{
+		Certificates:       []tls.Certificate{server.tls},
+		ClientCAs:          ca.pool(),
+		ClientPinnedSHA256: []string{pinned.fingerprint()},
+	}
+	l, received, stop := startTestListener(t, opts, nil)
+	defer stop()
+
+	for _, cert := range []*testCert{pinned, signed} {
+		res, err := export(l, ca.pool(), cert, "", "")
+		if nil != err {
+			t.Fatalf("export with %s: %v", cert.cert.Subject.CommonName, err)
+		}
+		if http.StatusOK != res.StatusCode {
+			t.Errorf("export with %s: status %d", cert.cert.Subject.CommonName, res.StatusCode)
+		}
+	}
+	if _, err := export(l, ca.pool(), unpinned, "", ""); nil == err {
+		t.Errorf("export accepted with an unpinned self-signed client certificate")
+	}
+	if _, err := export(l, ca.pool(), nil, "", ""); nil == err {
+		t.Errorf("export accepted without a client certificate")
+	}
+	if 2 != *received {
+		t.Errorf("%d indications received, want 2", *received)
+	}
+}
+
+func TestListenerBasicAuth(t *testing.T) {
+	ca := newTestCert(t, "test CA", true, 0, nil)
+	server := newTestCert(t, "listener", false, x509.ExtKeyUsageServerAuth, ca)
+	l, received, stop := startTestListener(t, &ListenerTLSOptions{Certificates: []tls.Certificate{server.tls}}, StaticCredentials{"exporter", "secret"})
+	defer stop()
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		status   int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"wrong password", "exporter", "wrong", http.StatusUnauthorized},
+		{"wrong username", "other", "secret", http.StatusUnauthorized},
+		{"authorized", "exporter", "secret", http.StatusOK},
+	}
+	for _, test := range tests {
+		res, err := export(l, ca.pool(), nil, test.username, test.password)
+		if nil != err {
+			t.Fatalf("%s: export: %v", test.name, err)
+		}
+		if test.status != res.StatusCode {
+			t.Errorf("%s: status %d, want %d", test.name, res.StatusCode, test.status)
+		}
+		if http.StatusUnauthorized == res.StatusCode && false == strings.HasPrefix(res.Header.Get("WWW-Authenticate"), "Basic ") {
+			t.Errorf("%s: WWW-Authenticate %q, want a Basic challenge", test.name, res.Header.Get("WWW-Authenticate"))
+		}
+	}
+	if 1 != *received {
+		t.Errorf("%d indications received, want 1", *received)
+	}
+}
@@ -0,0 +1,754 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Instances are mapped to structs by the cim tag of the fields:
+//      type ComputerSystem struct {
+//           Class        string    `cim:",classname"`
+//           Name         string    `cim:"Name,key"`
+//           ElementName  *string   `cim:"ElementName,omitempty"`
+//           EnabledState uint16    `cim:"EnabledState"`
+//           Dedicated    []uint16  `cim:"Dedicated"`
+//           Owner        *gowbem.InstanceName `cim:"Owner"`
+//      }
+// The tag holds the property name, which defaults to the field name, followed by options:
+//      key          the property is a key, used by MarshalInstanceName and filled from the instance name by UnmarshalNamedInstance
+//      omitempty    MarshalInstance leaves the property out when the field is the zero value
+//      type=<type>  the CIM type of the property, when it is not the one of the Go type, e.g. type=char16 for a rune
+//      classname    the string field holds the class name of the instance instead of a property
+// A field tagged "-" and unexported fields are ignored, and embedded structs without a tag are flattened.
+// Go types map to CIM types as follows, with slices for arrays and pointers for values that may be NULL:
+//      uint8 .. uint64, int8 .. int64     uint8 .. uint64, sint8 .. sint64
+//      float32, float64                   real32, real64
+//      bool, string                       boolean, string
+//      CIMDateTime, time.Time             datetime
+//      time.Duration                      datetime, as an interval
+//      *ValueReference, *InstanceName     reference
+//      *Instance                          string, as an embedded instance
+// When unmarshaling, numbers are converted between Go kinds as long as they fit, datetime and char16
+// values may be read into strings, and a reference may be read into an *InstanceName, which takes the instance
+// name part of the reference. A NULL value sets the field to nil or its zero value.
+
+type cimField struct {
+	index     []int
+	name      string
+	key       bool
+	omitEmpty bool
+	className bool
+	embedded  bool
+	typ       CIMType
+}
+
+var (
+	cimDateTimeType     = reflect.TypeOf(CIMDateTime{})
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	valueRefPtrType     = reflect.TypeOf(&ValueReference{})
+	instanceNamePtrType = reflect.TypeOf(&InstanceName{})
+	instancePtrType     = reflect.TypeOf(&Instance{})
+)
+
+// Returns the fields of a struct type that map to properties.
+func cimFields(t reflect.Type, index []int) ([]cimField, error) {
+	var fields []cimField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, tagged := sf.Tag.Lookup("cim")
+		if "-" == tag {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		if sf.Anonymous && false == tagged && reflect.Struct == sf.Type.Kind() {
+			embedded, err := cimFields(sf.Type, fieldIndex)
+			if nil != err {
+				return nil, err
+			}
+			fields = append(fields, embedded...)
+			continue
+		}
+		if "" != sf.PkgPath {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		field := cimField{index: fieldIndex, name: opts[0]}
+		if "" == field.name {
+			field.name = sf.Name
+		}
+		for _, opt := range opts[1:] {
+			switch {
+			case "key" == opt:
+				field.key = true
+			case "omitempty" == opt:
+				field.omitEmpty = true
+			case "classname" == opt:
+				if reflect.String != sf.Type.Kind() {
+					return nil, fmt.Errorf("classname field %s of %v is not a string", sf.Name, t)
+				}
+				field.className = true
+			case strings.HasPrefix(opt, "type="):
+				field.typ = CIMType(strings.TrimPrefix(opt, "type="))
+				if false == field.typ.IsValid() {
+					return nil, fmt.Errorf("unknown CIM type %q of field %s of %v", field.typ, sf.Name, t)
+				}
+			default:
+				return nil, fmt.Errorf("unknown option %q of field %s of %v", opt, sf.Name, t)
+			}
+		}
+		if instancePtrType == sf.Type || reflect.Slice == sf.Type.Kind() && instancePtrType == sf.Type.Elem() {
+			field.embedded = true
+			field.typ = CIMTypeString
+		}
+		if "" == field.typ && false == field.className {
+			typ, ok := cimTypeOf(sf.Type)
+			if false == ok {
+				return nil, fmt.Errorf("field %s of %v has no CIM type for %v", sf.Name, t, sf.Type)
+			}
+			field.typ = typ
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// Infers the CIM type of a Go type, looking through pointers and slices.
+func cimTypeOf(t reflect.Type) (CIMType, bool) {
+	switch t {
+	case cimDateTimeType, timeType, durationType:
+		return CIMTypeDateTime, true
+	case valueRefPtrType, instanceNamePtrType:
+		return CIMTypeReference, true
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return cimTypeOf(t.Elem())
+	case reflect.Uint8:
+		return CIMTypeUint8, true
+	case reflect.Uint16:
+		return CIMTypeUint16, true
+	case reflect.Uint32:
+		return CIMTypeUint32, true
+	case reflect.Uint64, reflect.Uint:
+		return CIMTypeUint64, true
+	case reflect.Int8:
+		return CIMTypeSint8, true
+	case reflect.Int16:
+		return CIMTypeSint16, true
+	case reflect.Int32:
+		return CIMTypeSint32, true
+	case reflect.Int64, reflect.Int:
+		return CIMTypeSint64, true
+	case reflect.Float32:
+		return CIMTypeReal32, true
+	case reflect.Float64:
+		return CIMTypeReal64, true
+	case reflect.Bool:
+		return CIMTypeBoolean, true
+	case reflect.String:
+		return CIMTypeString, true
+	case reflect.Interface:
+		return CIMTypeString, true
+	}
+	return "", false
+}
+
+// Returns the struct v points to.
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if reflect.Ptr == rv.Kind() && false == rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if reflect.Struct != rv.Kind() {
+		return reflect.Value{}, fmt.Errorf("%T is not a struct or a pointer to a struct", v)
+	}
+	return rv, nil
+}
+
+// UnmarshalInstance stores the properties of the instance in the struct v points to.
+// Fields without a matching property are left unchanged.
+func UnmarshalInstance(inst *Instance, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if reflect.Ptr != rv.Kind() || rv.IsNil() {
+		return fmt.Errorf("UnmarshalInstance needs a non-nil pointer, not %T", v)
+	}
+	sv, err := structValue(v)
+	if nil != err {
+		return err
+	}
+	fields, err := cimFields(sv.Type(), nil)
+	if nil != err {
+		return err
+	}
+	for _, field := range fields {
+		fv := sv.FieldByIndex(field.index)
+		if field.className {
+			fv.SetString(inst.ClassName)
+			continue
+		}
+		if field.embedded {
+			if err := inst.embeddedField(field.name, fv); nil != err {
+				return fmt.Errorf("property %s: %w", field.name, err)
+			}
+			continue
+		}
+		val, err := inst.GetValue(field.name)
+		if errors.Is(err, ErrNoSuchProperty) {
+			continue
+		}
+		if nil != err {
+			return fmt.Errorf("property %s: %w", field.name, err)
+		}
+		if err := setField(fv, val); nil != err {
+			return fmt.Errorf("property %s: %w", field.name, err)
+		}
+	}
+	return nil
+}
+
+// UnmarshalNamedInstance stores the properties of the named instance in the struct v points to,
+// filling the key fields from the instance name when the instance does not carry the key properties.
+func UnmarshalNamedInstance(named *ValueNamedInstance, v interface{}) error {
+	inst := Instance{}
+	if nil != named.Instance {
+		// SetValue rewrites the property slices in place, so copy them to leave the caller's instance alone
+		inst = *named.Instance
+		inst.Property = append([]Property(nil), inst.Property...)
+		inst.PropertyArray = append([]PropertyArray(nil), inst.PropertyArray...)
+		inst.PropertyReference = append([]PropertyReference(nil), inst.PropertyReference...)
+	}
+	if nil != named.InstanceName {
+		if "" == inst.ClassName {
+			inst.ClassName = named.InstanceName.ClassName
+		}
+		for _, key := range named.InstanceName.KeyBinding {
+			if val, err := inst.GetValue(key.Name); nil == err && false == val.IsNull() {
+				continue
+			}
+			var val *CIMValue
+			if nil != key.ValueReference {
+				val = parseValueReference(key.ValueReference)
+			} else if nil != key.KeyValue {
+				var err error
+				if val, err = key.KeyValue.CIMValue(); nil != err {
+					return fmt.Errorf("key %s: %w", key.Name, err)
+				}
+			} else {
+				continue
+			}
+			if err := inst.SetValue(key.Name, val); nil != err {
+				return fmt.Errorf("key %s: %w", key.Name, err)
+			}
+		}
+	}
+	return UnmarshalInstance(&inst, v)
+}
+
+// Stores the embedded instances of the named property in a *Instance or []*Instance field.
+func (inst *Instance) embeddedField(name string, fv reflect.Value) error {
+	for i := range inst.Property {
+		if prop := &inst.Property[i]; strings.EqualFold(name, prop.Name) {
+			return setEmbeddedField(fv, prop.embedded(), nil, prop.Value, nil)
+		}
+	}
+	for i := range inst.PropertyArray {
+		if prop := &inst.PropertyArray[i]; strings.EqualFold(name, prop.Name) {
+			return setEmbeddedField(fv, nil, prop.embedded(), nil, prop.ValueArray)
+		}
+	}
+	return nil
+}
+
+// Stores embedded instances in a *Instance or []*Instance field, decoding the text of the
+// VALUE or VALUE.ARRAY when it was not decoded on receive.
+func setEmbeddedField(fv reflect.Value, obj *ValueObject, objs []ValueObject, val *Value, arry *ValueArray) error {
+	if reflect.Slice == fv.Kind() {
+		if nil == objs && nil != arry && 0 != len(arry.Value) {
+			if objs = decodeEmbeddedArray(arry); nil == objs {
+				return fmt.Errorf("cannot store %v in %v", arry.Value, fv.Type())
+			}
+		}
+		if nil == objs {
+			fv.Set(reflect.Zero(fv.Type()))
+			return nil
+		}
+		insts := make([]*Instance, 0, len(objs))
+		for i := range objs {
+			if nil == objs[i].Instance {
+				return fmt.Errorf("embedded object is a class, not an instance")
+			}
+			insts = append(insts, objs[i].Instance)
+		}
+		fv.Set(reflect.ValueOf(insts))
+		return nil
+	}
+	if nil == obj && nil != val {
+		var err error
+		if obj, err = decodeEmbedded(val.Value); nil != err {
+			return err
+		}
+	}
+	if nil == obj {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+	if nil == obj.Instance {
+		return fmt.Errorf("embedded object is a class, not an instance")
+	}
+	fv.Set(reflect.ValueOf(obj.Instance))
+	return nil
+}
+
+// Returns the embedded instances of a *Instance or []*Instance field as the objects of a value.
+func embeddedFieldValue(fv reflect.Value) (obj *ValueObject, objs []ValueObject, isArray bool) {
+	if reflect.Slice == fv.Kind() {
+		if fv.IsNil() {
+			return nil, nil, true
+		}
+		objs = make([]ValueObject, 0, fv.Len())
+		for i := 0; i < fv.Len(); i++ {
+			objs = append(objs, ValueObject{Instance: fv.Index(i).Interface().(*Instance)})
+		}
+		return nil, objs, true
+	}
+	if fv.IsNil() {
+		return nil, nil, false
+	}
+	return &ValueObject{Instance: fv.Interface().(*Instance)}, nil, false
+}
+
+// UnmarshalValue stores a CIM value, such as the one InvokeMethodValue returns, in the variable
+// v points to, with the conversions of UnmarshalInstance.
+func UnmarshalValue(val *CIMValue, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if reflect.Ptr != rv.Kind() || rv.IsNil() {
+		return fmt.Errorf("UnmarshalValue needs a non-nil pointer, not %T", v)
+	}
+	if nil == val {
+		val = &CIMValue{}
+	}
+	return setField(rv.Elem(), val)
+}
+
+// Stores a CIM value in a field.
+func setField(fv reflect.Value, val *CIMValue) error {
+	if val.IsNull() {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+	if reflect.Ptr == fv.Kind() && valueRefPtrType != fv.Type() && instanceNamePtrType != fv.Type() {
+		elem := reflect.New(fv.Type().Elem())
+		if err := setField(elem.Elem(), val); nil != err {
+			return err
+		}
+		fv.Set(elem)
+		return nil
+	}
+	if val.IsArray {
+		if reflect.Slice != fv.Kind() {
+			return fmt.Errorf("cannot store %s in %v", val.describe(), fv.Type())
+		}
+		rv := reflect.ValueOf(val.Value)
+		arry := reflect.MakeSlice(fv.Type(), rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			if err := setScalar(arry.Index(i), val.Type, rv.Index(i).Interface()); nil != err {
+				return err
+			}
+		}
+		fv.Set(arry)
+		return nil
+	}
+	return setScalar(fv, val.Type, val.Value)
+}
+
+func setScalar(fv reflect.Value, typ CIMType, v interface{}) error {
+	mismatch := fmt.Errorf("cannot store %s value %v in %v", typ, v, fv.Type())
+	if reflect.Interface == fv.Kind() {
+		fv.Set(reflect.ValueOf(v))
+		return nil
+	}
+	switch v := v.(type) {
+	case *ValueReference:
+		switch fv.Type() {
+		case valueRefPtrType:
+			fv.Set(reflect.ValueOf(v))
+		case instanceNamePtrType:
+			name := referenceInstanceName(v)
+			if nil == name {
+				return mismatch
+			}
+			fv.Set(reflect.ValueOf(name))
+		case reflect.TypeOf(""):
+			fv.SetString(v.String())
+		default:
+			return mismatch
+		}
+		return nil
+	case CIMDateTime:
+		switch fv.Type() {
+		case cimDateTimeType:
+			fv.Set(reflect.ValueOf(v))
+			return nil
+		case timeType:
+			t, err := v.Time()
+			if nil != err {
+				return err
+			}
+			fv.Set(reflect.ValueOf(t))
+			return nil
+		case durationType:
+			d, err := v.Duration()
+			if nil != err {
+				return err
+			}
+			fv.SetInt(int64(d))
+			return nil
+		}
+		if reflect.String == fv.Kind() {
+			fv.SetString(v.String())
+			return nil
+		}
+		return mismatch
+	}
+	rv := reflect.ValueOf(v)
+	if CIMTypeChar16 == typ && reflect.String == fv.Kind() {
+		fv.SetString(string(v.(rune)))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i = rv.Int()
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if 1<<63-1 < rv.Uint() {
+				return mismatch
+			}
+			i = int64(rv.Uint())
+		default:
+			return mismatch
+		}
+		if fv.OverflowInt(i) {
+			return mismatch
+		}
+		fv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		switch rv.Kind() {
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			u = rv.Uint()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if 0 > rv.Int() {
+				return mismatch
+			}
+			u = uint64(rv.Int())
+		default:
+			return mismatch
+		}
+		if fv.OverflowUint(u) {
+			return mismatch
+		}
+		fv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		switch rv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			f = rv.Float()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = float64(rv.Int())
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f = float64(rv.Uint())
+		default:
+			return mismatch
+		}
+		fv.SetFloat(f)
+	case reflect.Bool, reflect.String:
+		if rv.Kind() != fv.Kind() {
+			return mismatch
+		}
+		fv.Set(rv.Convert(fv.Type()))
+	default:
+		return mismatch
+	}
+	return nil
+}
+
+// Returns the instance name a reference points to, or nil when it points to a class.
+func referenceInstanceName(ref *ValueReference) *InstanceName {
+	switch {
+	case nil != ref.InstanceName:
+		return ref.InstanceName
+	case nil != ref.InstancePath:
+		return ref.InstancePath.InstanceName
+	case nil != ref.LocalInstancePath:
+		return ref.LocalInstancePath.InstanceName
+	}
+	return nil
+}
+
+// Returns the CIM value of a field.
+func fieldValue(fv reflect.Value, field cimField) (*CIMValue, error) {
+	for reflect.Ptr == fv.Kind() && valueRefPtrType != fv.Type() && instanceNamePtrType != fv.Type() {
+		if fv.IsNil() {
+			return &CIMValue{Type: field.typ}, nil
+		}
+		fv = fv.Elem()
+	}
+	if reflect.Interface == fv.Kind() {
+		if fv.IsNil() {
+			return &CIMValue{Type: field.typ}, nil
+		}
+		fv = fv.Elem()
+	}
+	if CIMTypeReference == field.typ {
+		return referenceValue(fv)
+	}
+	if reflect.Slice == fv.Kind() && fv.IsNil() {
+		return &CIMValue{Type: field.typ, IsArray: true}, nil
+	}
+	return NewCIMValue(field.typ, fv.Interface())
+}
+
+func referenceValue(fv reflect.Value) (*CIMValue, error) {
+	toRef := func(v reflect.Value) (*ValueReference, error) {
+		switch v.Type() {
+		case valueRefPtrType:
+			return v.Interface().(*ValueReference), nil
+		case instanceNamePtrType:
+			return &ValueReference{InstanceName: v.Interface().(*InstanceName)}, nil
+		}
+		return nil, fmt.Errorf("cannot store %v in a reference", v.Type())
+	}
+	if reflect.Slice == fv.Kind() {
+		if fv.IsNil() {
+			return &CIMValue{Type: CIMTypeReference, IsArray: true}, nil
+		}
+		refs := make([]*ValueReference, 0, fv.Len())
+		for i := 0; i < fv.Len(); i++ {
+			ref, err := toRef(fv.Index(i))
+			if nil != err {
+				return nil, err
+			}
+			refs = append(refs, ref)
+		}
+		return &CIMValue{Type: CIMTypeReference, IsArray: true, Value: refs}, nil
+	}
+	if fv.IsNil() {
+		return &CIMValue{Type: CIMTypeReference}, nil
+	}
+	ref, err := toRef(fv)
+	if nil != err {
+		return nil, err
+	}
+	return &CIMValue{Type: CIMTypeReference, Value: ref}, nil
+}
+
+// CIMClassNamer is implemented by structs that know the CIM class they map to.
+type CIMClassNamer interface {
+	CIMClassName() string
+}
+
+// Returns the CIMClassNamer of v, looking at v itself, then the struct, then its address,
+// so that a CIMClassName method on either a value or a pointer receiver is found.
+func classNamer(v interface{}, sv reflect.Value) (CIMClassNamer, bool) {
+	if namer, ok := v.(CIMClassNamer); ok {
+		return namer, true
+	}
+	if namer, ok := sv.Interface().(CIMClassNamer); ok {
+		return namer, true
+	}
+	if sv.CanAddr() {
+		namer, ok := sv.Addr().Interface().(CIMClassNamer)
+		return namer, ok
+	}
+	return nil, false
+}
+
+// MarshalInstance returns the instance of the struct v is or points to. The class name comes from
+// the classname field when it is set, then from the CIMClassName method, and from the name of the Go type otherwise.
+func MarshalInstance(v interface{}) (*Instance, error) {
+	sv, err := structValue(v)
+	if nil != err {
+		return nil, err
+	}
+	fields, err := cimFields(sv.Type(), nil)
+	if nil != err {
+		return nil, err
+	}
+	inst := &Instance{ClassName: sv.Type().Name()}
+	if namer, ok := classNamer(v, sv); ok {
+		inst.ClassName = namer.CIMClassName()
+	}
+	for _, field := range fields {
+		fv := sv.FieldByIndex(field.index)
+		if field.className {
+			if "" != fv.String() {
+				inst.ClassName = fv.String()
+			}
+			continue
+		}
+		if field.omitEmpty && fv.IsZero() {
+			continue
+		}
+		if field.embedded {
+			obj, objs, isArray := embeddedFieldValue(fv)
+			inst.removeProperty(field.name)
+			if isArray {
+				inst.PropertyArray = append(inst.PropertyArray, PropertyArray{Name: field.name, Type: string(CIMTypeString), EmbeddedObject: "instance", EmbeddedValues: objs})
+			} else {
+				inst.Property = append(inst.Property, Property{Name: field.name, Type: string(CIMTypeString), EmbeddedObject: "instance", EmbeddedValue: obj})
+			}
+			continue
+		}
+		val, err := fieldValue(fv, field)
+		if nil != err {
+			return nil, fmt.Errorf("property %s: %w", field.name, err)
+		}
+		if err := inst.SetValue(field.name, val); nil != err {
+			return nil, fmt.Errorf("property %s: %w", field.name, err)
+		}
+	}
+	return inst, nil
+}
+
+// MarshalInstanceName returns the instance name of the struct v is or points to, made of its key fields.
+func MarshalInstanceName(v interface{}) (*InstanceName, error) {
+	inst, err := MarshalInstance(v)
+	if nil != err {
+		return nil, err
+	}
+	sv, _ := structValue(v)
+	fields, _ := cimFields(sv.Type(), nil)
+	name := &InstanceName{ClassName: inst.ClassName}
+	for _, field := range fields {
+		if false == field.key {
+			continue
+		}
+		val, err := inst.GetValue(field.name)
+		if nil != err {
+			return nil, fmt.Errorf("key %s: %w", field.name, err)
+		}
+		if CIMTypeReference == val.Type && false == val.IsNull() && false == val.IsArray {
+			name.KeyBinding = append(name.KeyBinding, KeyBinding{Name: field.name, ValueReference: val.Value.(*ValueReference)})
+			continue
+		}
+		kv, err := NewKeyValue(val)
+		if nil != err {
+			return nil, fmt.Errorf("key %s: %w", field.name, err)
+		}
+		name.KeyBinding = append(name.KeyBinding, KeyBinding{Name: field.name, KeyValue: kv})
+	}
+	return name, nil
+}
+
+// MarshalParamValues returns the parameters of InvokeMethod held by the struct v is or points to.
+// Fields that are nil pointers or nil slices are left out, so that the method takes their default.
+func MarshalParamValues(v interface{}) ([]ParamValue, error) {
+	sv, err := structValue(v)
+	if nil != err {
+		return nil, err
+	}
+	fields, err := cimFields(sv.Type(), nil)
+	if nil != err {
+		return nil, err
+	}
+	var params []ParamValue
+	for _, field := range fields {
+		fv := sv.FieldByIndex(field.index)
+		if field.className || field.omitEmpty && fv.IsZero() {
+			continue
+		}
+		if field.embedded {
+			switch insts := fv.Interface().(type) {
+			case *Instance:
+				if nil != insts {
+					params = append(params, *NewEmbeddedInstanceParamValue(field.name, insts))
+				}
+			case []*Instance:
+				if nil != insts {
+					params = append(params, *NewEmbeddedInstanceArrayParamValue(field.name, insts))
+				}
+			}
+			continue
+		}
+		val, err := fieldValue(fv, field)
+		if nil != err {
+			return nil, fmt.Errorf("parameter %s: %w", field.name, err)
+		}
+		if val.IsNull() {
+			continue
+		}
+		param, err := NewParamValue(field.name, val)
+		if nil != err {
+			return nil, fmt.Errorf("parameter %s: %w", field.name, err)
+		}
+		params = append(params, *param)
+	}
+	return params, nil
+}
+
+// UnmarshalParamValues stores the output parameters of InvokeMethod in the struct v points to.
+// A parameter without PARAMTYPE is taken as the CIM type of its field.
+func UnmarshalParamValues(params []ParamValue, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if reflect.Ptr != rv.Kind() || rv.IsNil() {
+		return fmt.Errorf("UnmarshalParamValues needs a non-nil pointer, not %T", v)
+	}
+	sv, err := structValue(v)
+	if nil != err {
+		return err
+	}
+	fields, err := cimFields(sv.Type(), nil)
+	if nil != err {
+		return err
+	}
+	for _, field := range fields {
+		if field.className {
+			continue
+		}
+		for i := range params {
+			if false == strings.EqualFold(field.name, params[i].Name) {
+				continue
+			}
+			if field.embedded {
+				param := &params[i]
+				obj, objs := param.embedded()
+				if err := setEmbeddedField(sv.FieldByIndex(field.index), obj, objs, param.Value, param.ValueArray); nil != err {
+					return fmt.Errorf("parameter %s: %w", field.name, err)
+				}
+				break
+			}
+			val, err := params[i].cimValueAs(field.typ)
+			if nil != err {
+				return fmt.Errorf("parameter %s: %w", field.name, err)
+			}
+			if err := setField(sv.FieldByIndex(field.index), val); nil != err {
+				return fmt.Errorf("parameter %s: %w", field.name, err)
+			}
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testDisk struct {
+	Name      string  `cim:"Name,key"`
+	DeviceID  string  `cim:"DeviceID,key"`
+	Caption   *string `cim:"Caption"`
+	BlockSize uint64  `cim:"BlockSize"`
+}
+
+type testPtrNamer struct {
+	Name string `cim:"Name,key"`
+}
+
+func (*testPtrNamer) CIMClassName() string {
+	return "Test_PtrNamer"
+}
+
+type testValueNamer struct {
+	Name string `cim:"Name,key"`
+}
+
+func (testValueNamer) CIMClassName() string {
+	return "Test_ValueNamer"
+}
+
+func TestUnmarshalNamedInstanceLeavesInputUnchanged(t *testing.T) {
+	named := &ValueNamedInstance{
+		InstanceName: &InstanceName{
+			ClassName: "Test_Disk",
+			KeyBinding: []KeyBinding{
+				{Name: "Name", KeyValue: &KeyValue{ValueType: "string", KeyValue: "disk0"}},
+				{Name: "DeviceID", KeyValue: &KeyValue{ValueType: "string", KeyValue: "0:0"}},
+			},
+		},
+		Instance: &Instance{
+			ClassName: "Test_Disk",
+			Property: []Property{
+				{Name: "Name", Type: "string"},
+				{Name: "Caption", Type: "string", Value: &Value{"first disk"}},
+				{Name: "BlockSize", Type: "uint64", Value: &Value{"512"}},
+			},
+		},
+	}
+	orig := &Instance{
+		ClassName:     named.Instance.ClassName,
+		Property:      append([]Property(nil), named.Instance.Property...),
+		PropertyArray: append([]PropertyArray(nil), named.Instance.PropertyArray...),
+	}
+
+	var disk testDisk
+	if err := UnmarshalNamedInstance(named, &disk); nil != err {
+		t.Fatalf("UnmarshalNamedInstance: %v", err)
+	}
+	if "disk0" != disk.Name || "0:0" != disk.DeviceID || nil == disk.Caption || "first disk" != *disk.Caption || 512 != disk.BlockSize {
+		t.Errorf("unmarshaled %+v", disk)
+	}
+	if false == reflect.DeepEqual(orig, named.Instance) {
+		t.Errorf("input instance changed:\n got %+v\nwant %+v", named.Instance, orig)
+	}
+}
+
+func TestMarshalInstanceClassName(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want string
+	}{
+		{&testPtrNamer{Name: "a"}, "Test_PtrNamer"},
+		{testValueNamer{Name: "a"}, "Test_ValueNamer"},
+		{&testValueNamer{Name: "a"}, "Test_ValueNamer"},
+		{testDisk{Name: "a"}, "testDisk"},
+	}
+	for _, test := range tests {
+		inst, err := MarshalInstance(test.v)
+		if nil != err {
+			t.Fatalf("MarshalInstance(%T): %v", test.v, err)
+		}
+		if test.want != inst.ClassName {
+			t.Errorf("MarshalInstance(%T) class %q, want %q", test.v, inst.ClassName, test.want)
+		}
+	}
+}
+
+type testJob struct {
+	InstanceID string      `cim:"InstanceID,key"`
+	LastError  *Instance   `cim:"LastError,omitempty"`
+	Errors     []*Instance `cim:"Errors,omitempty"`
+}
+
+func TestMarshalEmbeddedInstance(t *testing.T) {
+	newError := func(msg string) *Instance {
+		return &Instance{ClassName: "CIM_Error", Property: []Property{{Name: "Message", Type: "string", Value: &Value{msg}}}}
+	}
+	job := &testJob{InstanceID: "job1", LastError: newError("disk < 5%"), Errors: []*Instance{newError("a"), newError("b")}}
+	inst, err := MarshalInstance(job)
+	if nil != err {
+		t.Fatalf("MarshalInstance: %v", err)
+	}
+	var back testJob
+	if err = UnmarshalInstance(inst, &back); nil != err {
+		t.Fatalf("UnmarshalInstance: %v", err)
+	}
+	if nil == back.LastError || "disk < 5%" != propertyString(back.LastError, "Message") {
+		t.Errorf("LastError %+v", back.LastError)
+	}
+	if 2 != len(back.Errors) || "b" != propertyString(back.Errors[1], "Message") {
+		t.Errorf("Errors %+v", back.Errors)
+	}
+
+	var out struct {
+		Error *Instance `cim:"Error"`
+	}
+	params := []ParamValue{*NewEmbeddedInstanceParamValue("Error", newError("failed"))}
+	if err = UnmarshalParamValues(params, &out); nil != err {
+		t.Fatalf("UnmarshalParamValues: %v", err)
+	}
+	if nil == out.Error || "failed" != propertyString(out.Error, "Message") {
+		t.Errorf("Error %+v", out.Error)
+	}
+}
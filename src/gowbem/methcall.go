@@ -18,11 +18,8 @@
 package gowbem
 
 import (
-	"bytes"
+	"context"
 	"encoding/xml"
-	"fmt"
-	"io/ioutil"
-	"net/http"
 	"strings"
 )
 
@@ -52,67 +49,19 @@ func (methCall *MethodCall) appendLocalInstancePath(namespace string, instanceNa
 	}
 }
 
+// Returns the value of the CIMObject header, the WBEM URI of the target without the leading slash.
 func (methCall *MethodCall) getObjectPathString() string {
-	obj := ""
-	if nil != methCall.LocalClassPath &&
-		nil != methCall.LocalClassPath.ClassName &&
-		nil != methCall.LocalClassPath.LocalNamespacePath {
-		for i, ns := range methCall.LocalClassPath.LocalNamespacePath.Namespace {
-			if 0 == i {
-				obj += fmt.Sprintf("%s", ns.Name)
-			} else {
-				obj += fmt.Sprintf("/%s", ns.Name)
-			}
-		}
-		obj += fmt.Sprintf(":%s", methCall.LocalClassPath.ClassName.Name)
-	} else if nil != methCall.LocalInstancePath &&
-		nil != methCall.LocalInstancePath.InstanceName &&
-		nil != methCall.LocalInstancePath.LocalNamespacePath {
-		for i, ns := range methCall.LocalInstancePath.LocalNamespacePath.Namespace {
-			if 0 == i {
-				obj += fmt.Sprintf("%s", ns.Name)
-			} else {
-				obj += fmt.Sprintf("/%s", ns.Name)
-			}
-		}
-		obj += fmt.Sprintf(":%s", methCall.LocalInstancePath.InstanceName.ClassName)
-		for i, key := range methCall.LocalInstancePath.InstanceName.KeyBinding {
-			if 0 == i {
-				obj += fmt.Sprintf(".%s=\"%s\"", key.Name, key.KeyValue.KeyValue)
-			} else {
-				obj += fmt.Sprintf(",%s=\"%s\"", key.Name, key.KeyValue.KeyValue)
-			}
-		}
+	if nil != methCall.LocalClassPath {
+		return strings.TrimPrefix(methCall.LocalClassPath.String(), "/")
 	}
-	return obj
+	return strings.TrimPrefix(methCall.LocalInstancePath.String(), "/")
 }
 
-func (conn *WBEMConnection) doPostMethodCall(method string, object string, content []byte) ([]byte, error) {
-	req, err := http.NewRequest("POST", fmt.Sprintf("%s://%s:%d/%s", conn.scheme, conn.host, conn.port, DefaultRequestURI), bytes.NewReader(content))
-	if nil != err {
-		return nil, err
-	}
-	req.SetBasicAuth(conn.username, conn.password)
-	req.Header.Add("Content-Type", "application/xml; charset=\"utf-8\"")
-	req.Header.Add("Host", fmt.Sprintf("%s:%d", conn.host, conn.port))
-	req.Header.Add("Accept-Encoding", "identity")
-	req.Header["TE"] = append(req.Header["TE"], "trailers")
-	req.Header[HttpHdrOperation] = append(req.Header[HttpHdrOperation], "MethodCall")
-	req.Header[HttpHdrMethod] = append(req.Header[HttpHdrMethod], method)
-	req.Header[HttpHdrObject] = append(req.Header[HttpHdrObject], object)
-	res, err := conn.httpc.Do(req)
-	if nil != err {
-		return nil, err
-	}
-	defer res.Body.Close()
-	if 200 != res.StatusCode {
-		err = fmt.Errorf("HTTP_ERR - %d - %s", res.StatusCode, res.Status)
-		return nil, err
-	}
-	return ioutil.ReadAll(res.Body)
+func (conn *WBEMConnection) doPostMethodCall(ctx context.Context, method string, object string, content []byte) ([]byte, error) {
+	return conn.doPost(ctx, method, object, content, false)
 }
 
-func (conn *WBEMConnection) methodCall(call *MethodCall) (*MethodResponse, error) {
+func (conn *WBEMConnection) methodCall(ctx context.Context, call *MethodCall) (*MethodResponse, error) {
 	if nil == call {
 		return nil, conn.oops(ErrFailed, "")
 	}
@@ -132,7 +81,7 @@ func (conn *WBEMConnection) methodCall(call *MethodCall) (*MethodResponse, error
 		return nil, err
 	}
 	raw = append([]byte(xml.Header), raw...)
-	raw, err = conn.doPostMethodCall(call.Name, call.getObjectPathString(), raw)
+	raw, err = conn.doPostMethodCall(ctx, call.Name, call.getObjectPathString(), raw)
 	if nil != err {
 		return nil, err
 	}
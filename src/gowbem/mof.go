@@ -0,0 +1,1483 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The MOF compiler reads the Managed Object Format of DSP0221 into classes, instances and qualifier declarations:
+//      #pragma include ("file.mof")
+//      #pragma namespace ("root/cimv2")
+//      Qualifier Key : boolean = false, Scope(property, reference), Flavor(DisableOverride, ToSubclass);
+//      [Abstract, Description ("A managed element.")]
+//      class CIM_ManagedElement {
+//           [Key, MaxLen (256)] string InstanceID;
+//           uint16 Dedicated[];
+//           CIM_ManagedElement REF Parent;
+//           uint32 RequestStateChange ([In] uint16 RequestedState, [In (false), Out] CIM_Job REF Job);
+//      };
+//      instance of CIM_ManagedElement as $Root { InstanceID = "root"; Dedicated = {1, 2}; };
+//      instance of CIM_ManagedElement { InstanceID = "child"; Parent = $Root; };
+// Both the 2.x syntax and the association, indication, alias and embedded instance forms of 3.0 are
+// accepted. Structures and enumerations of 3.0 are not supported, nor are NULL elements of arrays.
+// An embedded instance value becomes the string of its CIM-XML, as in an EmbeddedInstance property of CIM-XML.
+//
+// Qualifier values are typed by the qualifier declarations of the MOF, or else by the standard qualifiers
+// of DSP0004. Property values of instances are typed by the classes of the MOF, or else by the classes
+// MOFCompiler.GetClass returns; the values of unknown classes are typed by their literals.
+
+// MOFDeclaration is a production of a MOF, with the namespace of the #pragma namespace in effect,
+// "" when there is none. One of QualifierDeclaration, Class and Instance is set.
+type MOFDeclaration struct {
+	Namespace            string
+	QualifierDeclaration *QualifierDeclaration
+	Class                *Class
+	Instance             *Instance
+	// the name of the instance, when the keys of its class are known
+	InstanceName *InstanceName
+	// the alias of the instance, without the $
+	Alias string
+}
+
+// MOFCompiler compiles MOF files, keeping the declarations so that later files may use the classes,
+// qualifiers and aliases of the former ones.
+type MOFCompiler struct {
+	// Include opens the file of a #pragma include. By default the file is opened relative to
+	// the directory of the including file.
+	Include func(file string) (io.ReadCloser, error)
+
+	// GetClass returns the class of an instance when the MOF does not declare it, typically
+	// from a WBEM server. It returns nil when there is no such class.
+	GetClass func(namespace, className string) (*Class, error)
+
+	decls      []MOFDeclaration
+	namespace  string
+	qualifiers map[string]*QualifierDeclaration
+	classes    map[string]*Class
+	aliases    map[string]mofAliasEntry
+	// the files being compiled, the including ones and the one they include
+	compiling map[string]bool
+}
+
+type mofAliasEntry struct {
+	name *InstanceName
+	err  error
+}
+
+func NewMOFCompiler() *MOFCompiler {
+	return &MOFCompiler{
+		qualifiers: make(map[string]*QualifierDeclaration),
+		classes:    make(map[string]*Class),
+		aliases:    make(map[string]mofAliasEntry),
+		compiling:  make(map[string]bool),
+	}
+}
+
+// Declarations returns the declarations compiled so far, in the order of the MOF.
+func (c *MOFCompiler) Declarations() []MOFDeclaration {
+	return c.decls
+}
+
+// CompileFile compiles the MOF file.
+func (c *MOFCompiler) CompileFile(file string) error {
+	f, err := os.Open(file)
+	if nil != err {
+		return err
+	}
+	defer f.Close()
+	return c.Compile(f, file)
+}
+
+// Compile compiles the MOF read from r, where name is the file name used in errors and to resolve includes.
+func (c *MOFCompiler) Compile(r io.Reader, name string) error {
+	src, err := ioutil.ReadAll(r)
+	if nil != err {
+		return err
+	}
+	toks, err := lexMOF(name, string(src))
+	if nil != err {
+		return err
+	}
+	c.compiling[filepath.Clean(name)] = true
+	defer delete(c.compiling, filepath.Clean(name))
+	p := &mofParser{c: c, file: name, toks: toks}
+	return p.parse()
+}
+
+// ParseMOF compiles the MOF read from r, where name is the file name used in errors and to resolve includes.
+func ParseMOF(r io.Reader, name string) ([]MOFDeclaration, error) {
+	c := NewMOFCompiler()
+	if err := c.Compile(r, name); nil != err {
+		return nil, err
+	}
+	return c.Declarations(), nil
+}
+
+// ParseMOFFile compiles the MOF file, with the files it includes.
+func ParseMOFFile(file string) ([]MOFDeclaration, error) {
+	c := NewMOFCompiler()
+	if err := c.CompileFile(file); nil != err {
+		return nil, err
+	}
+	return c.Declarations(), nil
+}
+
+func mofErr(file string, line int, format string, args ...interface{}) error {
+	return newCIMErr(ErrInvalidParameter, fmt.Sprintf("%s:%d: %s", file, line, fmt.Sprintf(format, args...)), nil)
+}
+
+const (
+	mofEOF = iota
+	mofIdent
+	mofString
+	mofChar
+	mofNumber
+	mofAlias
+	mofPunct
+	mofPragma
+)
+
+type mofToken struct {
+	kind int
+	// the unescaped text of strings and chars, the name of aliases without the $
+	text string
+	line int
+}
+
+func isMOFIdentStart(c rune) bool {
+	return '_' == c || unicode.IsLetter(c)
+}
+
+func isMOFIdentChar(c rune) bool {
+	return '_' == c || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// Splits a MOF into tokens, dropping the comments.
+func lexMOF(file string, src string) ([]mofToken, error) {
+	var toks []mofToken
+	line := 1
+	for i := 0; i < len(src); {
+		c, size := utf8.DecodeRuneInString(src[i:])
+		switch {
+		case '\n' == c:
+			line++
+			i++
+		case unicode.IsSpace(c) || '\uFEFF' == c:
+			i += size
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && '\n' != src[i] {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if 0 > end {
+				return nil, mofErr(file, line, "unterminated comment")
+			}
+			line += strings.Count(src[i:i+2+end], "\n")
+			i += end + 4
+		case '#' == c:
+			start := i
+			for i++; i < len(src) && isMOFIdentChar(rune(src[i])); i++ {
+			}
+			if false == strings.EqualFold("#pragma", src[start:i]) {
+				return nil, mofErr(file, line, "unknown directive %s", src[start:i])
+			}
+			toks = append(toks, mofToken{mofPragma, src[start:i], line})
+		case '"' == c || '\'' == c:
+			text, n, err := unquoteMOF(src[i:])
+			if nil != err {
+				return nil, mofErr(file, line, "%s", err.Error())
+			}
+			kind := mofString
+			if '\'' == c {
+				kind = mofChar
+			}
+			toks = append(toks, mofToken{kind, text, line})
+			i += n
+		case '$' == c:
+			start := i
+			for i++; i < len(src); {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if false == isMOFIdentChar(r) {
+					break
+				}
+				i += size
+			}
+			if start+1 == i {
+				return nil, mofErr(file, line, "missing alias name after $")
+			}
+			toks = append(toks, mofToken{mofAlias, src[start+1 : i], line})
+		case isMOFIdentStart(c):
+			start := i
+			for i < len(src) {
+				r, size := utf8.DecodeRuneInString(src[i:])
+				if false == isMOFIdentChar(r) {
+					break
+				}
+				i += size
+			}
+			toks = append(toks, mofToken{mofIdent, src[start:i], line})
+		case unicode.IsDigit(c) || (('.' == c || '+' == c || '-' == c) && i+1 < len(src) && (unicode.IsDigit(rune(src[i+1])) || '.' == src[i+1])):
+			start := i
+			for i++; i < len(src); i++ {
+				b := src[i]
+				if '.' == b || isMOFIdentChar(rune(b)) {
+					continue
+				}
+				// the sign of an exponent, but not after the digits of a hexadecimal value
+				if ('+' == b || '-' == b) && ('e' == src[i-1] || 'E' == src[i-1]) &&
+					false == strings.HasPrefix(strings.ToLower(strings.TrimLeft(src[start:i], "+-")), "0x") {
+					continue
+				}
+				break
+			}
+			toks = append(toks, mofToken{mofNumber, src[start:i], line})
+		case strings.ContainsRune("[]{}(),;:=", c):
+			toks = append(toks, mofToken{mofPunct, string(c), line})
+			i++
+		default:
+			return nil, mofErr(file, line, "unexpected character %q", c)
+		}
+	}
+	return append(toks, mofToken{mofEOF, "", line}), nil
+}
+
+// Returns the text of the string or char literal at the start of s, and the length of the literal.
+func unquoteMOF(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); {
+		c := s[i]
+		switch {
+		case quote == c:
+			return b.String(), i + 1, nil
+		case '\n' == c:
+			return "", 0, errors.New("newline in a literal")
+		case '\\' == c && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'b':
+				b.WriteByte('\b')
+			case 't':
+				b.WriteByte('\t')
+			case 'n':
+				b.WriteByte('\n')
+			case 'f':
+				b.WriteByte('\f')
+			case 'r':
+				b.WriteByte('\r')
+			case '"', '\'', '\\':
+				b.WriteByte(s[i])
+			case 'x', 'X':
+				j := i + 1
+				for j < len(s) && j < i+5 && strings.IndexByte("0123456789abcdefABCDEF", s[j]) >= 0 {
+					j++
+				}
+				if j == i+1 {
+					return "", 0, errors.New("missing hex digits in a \\x escape")
+				}
+				u, _ := strconv.ParseUint(s[i+1:j], 16, 32)
+				b.WriteRune(rune(u))
+				i = j
+				continue
+			default:
+				return "", 0, fmt.Errorf("unknown escape \\%c", s[i])
+			}
+			i++
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return "", 0, errors.New("unterminated literal")
+}
+
+// A value literal, before it is typed.
+type mofLiteral struct {
+	kind  int // mofString, mofChar, mofNumber, mofAlias, or mofIdent for true, false and null, or mofPunct for arrays
+	text  string
+	elems []mofLiteral
+	line  int
+}
+
+func (lit *mofLiteral) isNull() bool {
+	return mofIdent == lit.kind && strings.EqualFold("null", lit.text)
+}
+
+func (lit *mofLiteral) isArray() bool {
+	return mofPunct == lit.kind
+}
+
+// A qualifier of an element, before it is typed.
+type mofQualifier struct {
+	name    string
+	value   *mofLiteral
+	flavors []string
+	line    int
+}
+
+type mofParser struct {
+	c    *MOFCompiler
+	file string
+	toks []mofToken
+	pos  int
+}
+
+func (p *mofParser) peek() mofToken {
+	return p.toks[p.pos]
+}
+
+func (p *mofParser) next() mofToken {
+	tok := p.toks[p.pos]
+	if mofEOF != tok.kind {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *mofParser) errorf(format string, args ...interface{}) error {
+	return mofErr(p.file, p.peek().line, format, args...)
+}
+
+func (p *mofParser) isPunct(s string) bool {
+	tok := p.peek()
+	return mofPunct == tok.kind && s == tok.text
+}
+
+func (p *mofParser) isKeyword(s string) bool {
+	tok := p.peek()
+	return mofIdent == tok.kind && strings.EqualFold(s, tok.text)
+}
+
+func (p *mofParser) expect(s string) error {
+	if false == p.isPunct(s) {
+		return p.errorf("expecting %q, found %s", s, p.describe())
+	}
+	p.next()
+	return nil
+}
+
+func (p *mofParser) ident() (string, error) {
+	if mofIdent != p.peek().kind {
+		return "", p.errorf("expecting a name, found %s", p.describe())
+	}
+	return p.next().text, nil
+}
+
+func (p *mofParser) describe() string {
+	tok := p.peek()
+	switch tok.kind {
+	case mofEOF:
+		return "the end of the file"
+	case mofString:
+		return strconv.Quote(tok.text)
+	case mofAlias:
+		return "$" + tok.text
+	}
+	return strconv.Quote(tok.text)
+}
+
+func (p *mofParser) parse() error {
+	for mofEOF != p.peek().kind {
+		if mofPragma == p.peek().kind {
+			if err := p.parsePragma(); nil != err {
+				return err
+			}
+			continue
+		}
+		quals, err := p.parseQualifierList()
+		if nil != err {
+			return err
+		}
+		line := p.peek().line
+		kw, err := p.ident()
+		if nil != err {
+			return err
+		}
+		switch strings.ToLower(kw) {
+		case "qualifier":
+			if 0 != len(quals) {
+				return mofErr(p.file, line, "a qualifier declaration has no qualifiers")
+			}
+			err = p.parseQualifierDeclaration()
+		case "class", "association", "indication":
+			err = p.parseClass(quals, strings.ToLower(kw))
+		case "instance":
+			err = p.parseInstance(quals)
+		case "structure", "enumeration", "value":
+			err = mofErr(p.file, line, "%s declarations are not supported", kw)
+		default:
+			err = mofErr(p.file, line, "expecting a declaration, found %q", kw)
+		}
+		if nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// #pragma <name> ("<value>")
+func (p *mofParser) parsePragma() error {
+	p.next()
+	line := p.peek().line
+	name, err := p.ident()
+	if nil != err {
+		return err
+	}
+	if err = p.expect("("); nil != err {
+		return err
+	}
+	if mofString != p.peek().kind {
+		return p.errorf("expecting the string parameter of #pragma %s", name)
+	}
+	value := p.next().text
+	if err = p.expect(")"); nil != err {
+		return err
+	}
+	switch strings.ToLower(name) {
+	case "include":
+		return p.include(value, line)
+	case "namespace":
+		p.c.namespace = strings.Trim(value, "/")
+	}
+	// other pragmas such as locale have no effect on the declarations
+	return nil
+}
+
+func (p *mofParser) include(file string, line int) error {
+	if nil == p.c.Include && false == filepath.IsAbs(file) {
+		file = filepath.Join(filepath.Dir(p.file), file)
+	}
+	if p.c.compiling[filepath.Clean(file)] {
+		return mofErr(p.file, line, "cannot include %s: it includes itself", file)
+	}
+	var r io.ReadCloser
+	var err error
+	if nil != p.c.Include {
+		r, err = p.c.Include(file)
+	} else {
+		r, err = os.Open(file)
+	}
+	if nil != err {
+		return mofErr(p.file, line, "cannot include %s: %s", file, err.Error())
+	}
+	defer r.Close()
+	return p.c.Compile(r, file)
+}
+
+// [<qualifier>{, <qualifier>}], where a qualifier is <name>[(<value>) | {<value>, ...}][: <flavor> ...]
+func (p *mofParser) parseQualifierList() ([]mofQualifier, error) {
+	if false == p.isPunct("[") {
+		return nil, nil
+	}
+	p.next()
+	var quals []mofQualifier
+	for {
+		q := mofQualifier{line: p.peek().line}
+		var err error
+		if q.name, err = p.ident(); nil != err {
+			return nil, err
+		}
+		if p.isPunct("(") {
+			p.next()
+			if q.value, err = p.parseValue(); nil != err {
+				return nil, err
+			}
+			if err = p.expect(")"); nil != err {
+				return nil, err
+			}
+		} else if p.isPunct("{") {
+			if q.value, err = p.parseValue(); nil != err {
+				return nil, err
+			}
+		}
+		if p.isPunct(":") {
+			p.next()
+			for mofIdent == p.peek().kind {
+				q.flavors = append(q.flavors, p.next().text)
+			}
+		}
+		quals = append(quals, q)
+		if p.isPunct("]") {
+			p.next()
+			return quals, nil
+		}
+		if err = p.expect(","); nil != err {
+			return nil, err
+		}
+	}
+}
+
+// Parses a literal, an alias or an array of them. Adjacent strings are concatenated.
+func (p *mofParser) parseValue() (*mofLiteral, error) {
+	tok := p.peek()
+	lit := &mofLiteral{kind: tok.kind, text: tok.text, line: tok.line}
+	switch tok.kind {
+	case mofString:
+		var b strings.Builder
+		for mofString == p.peek().kind {
+			b.WriteString(p.next().text)
+		}
+		lit.text = b.String()
+	case mofChar, mofNumber, mofAlias:
+		p.next()
+	case mofIdent:
+		switch strings.ToLower(tok.text) {
+		case "true", "false", "null":
+			p.next()
+		case "instance":
+			return p.parseEmbeddedInstance()
+		default:
+			return nil, p.errorf("expecting a value, found %s", p.describe())
+		}
+	case mofPunct:
+		if "{" != tok.text {
+			return nil, p.errorf("expecting a value, found %s", p.describe())
+		}
+		p.next()
+		lit.elems = []mofLiteral{}
+		for false == p.isPunct("}") {
+			elem, err := p.parseValue()
+			if nil != err {
+				return nil, err
+			}
+			if elem.isArray() {
+				return nil, mofErr(p.file, elem.line, "arrays of arrays are not allowed")
+			}
+			lit.elems = append(lit.elems, *elem)
+			if false == p.isPunct("}") {
+				if err = p.expect(","); nil != err {
+					return nil, err
+				}
+			}
+		}
+		p.next()
+	default:
+		return nil, p.errorf("expecting a value, found %s", p.describe())
+	}
+	return lit, nil
+}
+
+// [<size>] or [] after the name of an array; the size is "" when the array is unbounded.
+func (p *mofParser) parseArraySpec() (bool, string, error) {
+	if false == p.isPunct("[") {
+		return false, "", nil
+	}
+	p.next()
+	size := ""
+	if mofNumber == p.peek().kind {
+		size = p.next().text
+		if n, err := strconv.ParseUint(size, 10, 32); nil != err || 0 == n {
+			return false, "", p.errorf("invalid array size %s", size)
+		}
+	}
+	return true, size, p.expect("]")
+}
+
+// Qualifier <name> : <type>[<array>] [= <value>] [,] [Scope(<scope>, ...)] [,] [Flavor(<flavor>, ...) | Policy(<policy>)];
+func (p *mofParser) parseQualifierDeclaration() error {
+	line := p.peek().line
+	name, err := p.ident()
+	if nil != err {
+		return err
+	}
+	if err = p.expect(":"); nil != err {
+		return err
+	}
+	typName, err := p.ident()
+	if nil != err {
+		return err
+	}
+	typ := CIMType(strings.ToLower(typName))
+	if false == typ.IsValid() || CIMTypeReference == typ {
+		return mofErr(p.file, line, "invalid type %s of qualifier %s", typName, name)
+	}
+	decl := &QualifierDeclaration{Name: name, Type: string(typ)}
+	isArray, size, err := p.parseArraySpec()
+	if nil != err {
+		return err
+	}
+	if isArray {
+		decl.IsArray, decl.ArraySize = "true", size
+	}
+	if p.isPunct("=") {
+		p.next()
+		lit, err := p.parseValue()
+		if nil != err {
+			return err
+		}
+		if decl.Value, decl.ValueArray, err = p.values(typ, isArray, lit); nil != err {
+			return err
+		}
+	}
+	for false == p.isPunct(";") {
+		if p.isPunct(",") {
+			p.next()
+			continue
+		}
+		kw, err := p.ident()
+		if nil != err {
+			return err
+		}
+		list, err := p.parseNameList()
+		if nil != err {
+			return err
+		}
+		switch strings.ToLower(kw) {
+		case "scope":
+			if decl.Scope, err = p.scope(list); nil != err {
+				return err
+			}
+		case "flavor", "policy":
+			for _, flavor := range list {
+				setFlavor(&decl.Overridable, &decl.ToSubClass, &decl.ToInstance, &decl.Translatable, flavor)
+			}
+		default:
+			return mofErr(p.file, line, "unexpected %s in the declaration of qualifier %s", kw, name)
+		}
+	}
+	p.next()
+	p.c.qualifiers[strings.ToLower(name)] = decl
+	p.c.decls = append(p.c.decls, MOFDeclaration{Namespace: p.c.namespace, QualifierDeclaration: decl})
+	return nil
+}
+
+// (<name>, ...)
+func (p *mofParser) parseNameList() ([]string, error) {
+	if err := p.expect("("); nil != err {
+		return nil, err
+	}
+	var names []string
+	for {
+		name, err := p.ident()
+		if nil != err {
+			return nil, err
+		}
+		names = append(names, name)
+		if p.isPunct(")") {
+			p.next()
+			return names, nil
+		}
+		if err = p.expect(","); nil != err {
+			return nil, err
+		}
+	}
+}
+
+func (p *mofParser) scope(names []string) (*Scope, error) {
+	scope := &Scope{}
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case "class":
+			scope.Class = "true"
+		case "association":
+			scope.Association = "true"
+		case "reference":
+			scope.Reference = "true"
+		case "property":
+			scope.Property = "true"
+		case "method":
+			scope.Method = "true"
+		case "parameter":
+			scope.Parameter = "true"
+		case "indication":
+			scope.Indication = "true"
+		case "any":
+			*scope = Scope{"true", "true", "true", "true", "true", "true", "true"}
+		case "structure", "enumeration", "qualifier", "enumerationvalue":
+			// the elements of 3.0 that CIM-XML has no scope for
+		default:
+			return nil, p.errorf("unknown scope %s", name)
+		}
+	}
+	return scope, nil
+}
+
+// Sets the flavor attributes of a qualifier or a qualifier declaration.
+func setFlavor(overridable, toSubClass, toInstance, translatable *string, flavor string) {
+	switch strings.ToLower(flavor) {
+	case "enableoverride":
+		*overridable = "true"
+	case "disableoverride":
+		*overridable = "false"
+	case "tosubclass":
+		*toSubClass = "true"
+	case "restricted":
+		*toSubClass = "false"
+	case "toinstance":
+		*toInstance = "true"
+	case "translatable":
+		*translatable = "true"
+	}
+}
+
+// The standard qualifiers of DSP0004, which MOF files use without declaring them.
+var standardQualifiers = map[string]struct {
+	typ     CIMType
+	isArray bool
+}{
+	"abstract":            {CIMTypeBoolean, false},
+	"aggregate":           {CIMTypeBoolean, false},
+	"aggregation":         {CIMTypeBoolean, false},
+	"arraytype":           {CIMTypeString, false},
+	"association":         {CIMTypeBoolean, false},
+	"bitmap":              {CIMTypeString, true},
+	"bitvalues":           {CIMTypeString, true},
+	"classconstraint":     {CIMTypeString, true},
+	"composition":         {CIMTypeBoolean, false},
+	"correlatable":        {CIMTypeString, true},
+	"counter":             {CIMTypeBoolean, false},
+	"deprecated":          {CIMTypeString, true},
+	"description":         {CIMTypeString, false},
+	"displayname":         {CIMTypeString, false},
+	"dn":                  {CIMTypeBoolean, false},
+	"embeddedinstance":    {CIMTypeString, false},
+	"embeddedobject":      {CIMTypeBoolean, false},
+	"exception":           {CIMTypeBoolean, false},
+	"experimental":        {CIMTypeBoolean, false},
+	"gauge":               {CIMTypeBoolean, false},
+	"in":                  {CIMTypeBoolean, false},
+	"indication":          {CIMTypeBoolean, false},
+	"ispunit":             {CIMTypeBoolean, false},
+	"key":                 {CIMTypeBoolean, false},
+	"mappingstrings":      {CIMTypeString, true},
+	"max":                 {CIMTypeUint32, false},
+	"maxlen":              {CIMTypeUint32, false},
+	"maxvalue":            {CIMTypeSint64, false},
+	"methodconstraint":    {CIMTypeString, true},
+	"min":                 {CIMTypeUint32, false},
+	"minlen":              {CIMTypeUint32, false},
+	"minvalue":            {CIMTypeSint64, false},
+	"modelcorrespondence": {CIMTypeString, true},
+	"nullvalue":           {CIMTypeString, false},
+	"octetstring":         {CIMTypeBoolean, false},
+	"ordered":             {CIMTypeBoolean, false},
+	"out":                 {CIMTypeBoolean, false},
+	"override":            {CIMTypeString, false},
+	"propertyconstraint":  {CIMTypeString, true},
+	"punit":               {CIMTypeString, false},
+	"read":                {CIMTypeBoolean, false},
+	"required":            {CIMTypeBoolean, false},
+	"revision":            {CIMTypeString, false},
+	"schema":              {CIMTypeString, false},
+	"source":              {CIMTypeString, false},
+	"sourcetype":          {CIMTypeString, false},
+	"static":              {CIMTypeBoolean, false},
+	"terminal":            {CIMTypeBoolean, false},
+	"umlpackagepath":      {CIMTypeString, false},
+	"units":               {CIMTypeString, false},
+	"valuemap":            {CIMTypeString, true},
+	"values":              {CIMTypeString, true},
+	"version":             {CIMTypeString, false},
+	"weak":                {CIMTypeBoolean, false},
+	"write":               {CIMTypeBoolean, false},
+}
+
+// Types a qualifier by its declaration, by the standard qualifiers, or else by its value.
+func (p *mofParser) qualifier(mq *mofQualifier) (Qualifier, error) {
+	q := Qualifier{Name: mq.name}
+	var typ CIMType
+	var isArray bool
+	if decl, ok := p.c.qualifiers[strings.ToLower(mq.name)]; ok {
+		typ, isArray = CIMType(decl.Type), "true" == decl.IsArray
+		q.Name = decl.Name
+	} else if std, ok := standardQualifiers[strings.ToLower(mq.name)]; ok {
+		typ, isArray = std.typ, std.isArray
+	} else if nil == mq.value {
+		typ = CIMTypeBoolean
+	} else {
+		typ, isArray = inferCIMType(mq.value), mq.value.isArray()
+		if CIMTypeReference == typ {
+			return q, mofErr(p.file, mq.line, "qualifier %s cannot be a reference", mq.name)
+		}
+	}
+	q.Type = string(typ)
+	for _, flavor := range mq.flavors {
+		setFlavor(&q.Overridable, &q.ToSubClass, &q.ToInstance, &q.Translatable, flavor)
+	}
+	if nil == mq.value {
+		if CIMTypeBoolean != typ || isArray {
+			return q, mofErr(p.file, mq.line, "qualifier %s needs a value", mq.name)
+		}
+		q.Value = &Value{"true"}
+		return q, nil
+	}
+	var err error
+	q.Value, q.ValueArray, err = p.values(typ, isArray, mq.value)
+	return q, err
+}
+
+func (p *mofParser) qualifiers(mqs []mofQualifier) ([]Qualifier, error) {
+	var quals []Qualifier
+	for i := range mqs {
+		q, err := p.qualifier(&mqs[i])
+		if nil != err {
+			return nil, err
+		}
+		quals = append(quals, q)
+	}
+	return quals, nil
+}
+
+// Returns the type a literal has when nothing else types it.
+func inferCIMType(lit *mofLiteral) CIMType {
+	if lit.isArray() {
+		for i := range lit.elems {
+			if false == lit.elems[i].isNull() {
+				return inferCIMType(&lit.elems[i])
+			}
+		}
+		return CIMTypeString
+	}
+	switch lit.kind {
+	case mofChar:
+		return CIMTypeChar16
+	case mofNumber:
+		s, err := mofDecimal(lit.text)
+		if nil != err || strings.ContainsAny(s, ".eE") && false == strings.HasPrefix(strings.ToLower(strings.TrimLeft(s, "+-")), "0x") {
+			return CIMTypeReal64
+		}
+		if _, err := strconv.ParseInt(s, 0, 64); nil != err {
+			return CIMTypeUint64
+		}
+		return CIMTypeSint64
+	case mofAlias:
+		return CIMTypeReference
+	case mofIdent:
+		if false == lit.isNull() {
+			return CIMTypeBoolean
+		}
+	}
+	return CIMTypeString
+}
+
+// Converts the binary (1010b) and octal (017) integers of MOF to decimal, leaving the others as they are.
+func mofDecimal(s string) (string, error) {
+	sign, digits := "", s
+	if strings.HasPrefix(digits, "+") || strings.HasPrefix(digits, "-") {
+		sign, digits = digits[:1], digits[1:]
+	}
+	lower := strings.ToLower(digits)
+	base := 0
+	switch {
+	case strings.HasSuffix(lower, "b") && "" == strings.Trim(lower[:len(lower)-1], "01") && 1 < len(lower):
+		base, digits = 2, digits[:len(digits)-1]
+	case strings.HasPrefix(lower, "0x"):
+		return s, nil
+	case 1 < len(lower) && '0' == lower[0] && "" == strings.Trim(lower, "01234567"):
+		base, digits = 8, digits[1:]
+	}
+	if 0 == base {
+		return s, nil
+	}
+	u, err := strconv.ParseUint(digits, base, 64)
+	if nil != err {
+		return "", err
+	}
+	return sign + strconv.FormatUint(u, 10), nil
+}
+
+// Returns the CIM-XML text of a non-NULL scalar literal of type typ.
+func (p *mofParser) scalar(typ CIMType, lit *mofLiteral) (string, error) {
+	fail := func() (string, error) {
+		return "", mofErr(p.file, lit.line, "invalid %s value %q", typ, lit.text)
+	}
+	switch {
+	case lit.isArray():
+		return "", mofErr(p.file, lit.line, "an array is not a %s value", typ)
+	case CIMTypeString == typ || CIMTypeDateTime == typ:
+		if mofString != lit.kind {
+			return fail()
+		}
+	case CIMTypeChar16 == typ:
+		if mofChar != lit.kind && mofString != lit.kind {
+			return fail()
+		}
+	case CIMTypeBoolean == typ:
+		if mofIdent != lit.kind {
+			return fail()
+		}
+	default:
+		if mofNumber != lit.kind {
+			return fail()
+		}
+	}
+	text := lit.text
+	if mofNumber == lit.kind {
+		var err error
+		if text, err = mofDecimal(text); nil != err {
+			return fail()
+		}
+	}
+	v, err := ParseCIMValue(typ, text)
+	if nil != err {
+		return fail()
+	}
+	if CIMTypeString == typ {
+		return text, nil
+	}
+	return FormatCIMValue(typ, v)
+}
+
+// Returns the VALUE or VALUE.ARRAY of a literal of type typ; both are nil for NULL.
+func (p *mofParser) values(typ CIMType, isArray bool, lit *mofLiteral) (*Value, *ValueArray, error) {
+	if lit.isNull() {
+		return nil, nil, nil
+	}
+	if false == isArray {
+		s, err := p.scalar(typ, lit)
+		if nil != err {
+			return nil, nil, err
+		}
+		return &Value{s}, nil, nil
+	}
+	elems := lit.elems
+	if false == lit.isArray() {
+		// a scalar is accepted for an array of one element
+		elems = []mofLiteral{*lit}
+	}
+	arry := &ValueArray{}
+	for i := range elems {
+		if elems[i].isNull() {
+			// VALUE.ARRAY keeps the NULL elements apart from the others, which would lose their positions
+			return nil, nil, mofErr(p.file, elems[i].line, "NULL elements of arrays are not supported")
+		}
+		s, err := p.scalar(typ, &elems[i])
+		if nil != err {
+			return nil, nil, err
+		}
+		arry.Value = append(arry.Value, Value{s})
+	}
+	return nil, arry, nil
+}
+
+// Returns the reference an alias or an object path names; nil for NULL.
+func (p *mofParser) reference(lit *mofLiteral) (*ValueReference, error) {
+	switch {
+	case lit.isNull():
+		return nil, nil
+	case mofAlias == lit.kind:
+		alias, ok := p.c.aliases[strings.ToLower(lit.text)]
+		if false == ok {
+			return nil, mofErr(p.file, lit.line, "undefined alias $%s", lit.text)
+		}
+		if nil != alias.err {
+			return nil, mofErr(p.file, lit.line, "cannot name the instance of $%s: %s", lit.text, alias.err.Error())
+		}
+		return &ValueReference{InstanceName: alias.name}, nil
+	case mofString == lit.kind:
+		if ref := parseReference(lit.text); nil != ref {
+			return ref, nil
+		}
+		path, err := ParseObjectPath(lit.text)
+		if nil != err {
+			return nil, mofErr(p.file, lit.line, "%s", err.Error())
+		}
+		ns := path.ClassPath.NamespacePath
+		switch {
+		case nil == ns:
+			return &ValueReference{ClassName: path.ClassPath.ClassName}, nil
+		case nil == ns.Host:
+			return &ValueReference{LocalClassPath: &LocalClassPath{ns.LocalNamespacePath, path.ClassPath.ClassName}}, nil
+		}
+		return &ValueReference{ClassPath: path.ClassPath}, nil
+	}
+	return nil, mofErr(p.file, lit.line, "expecting an alias or an object path, found %q", lit.text)
+}
+
+// class <name> [: <superclass>] { <feature> ... };
+func (p *mofParser) parseClass(mqs []mofQualifier, kw string) error {
+	line := p.peek().line
+	name, err := p.ident()
+	if nil != err {
+		return err
+	}
+	cls := &Class{Name: name}
+	if p.isPunct(":") {
+		p.next()
+		if cls.SuperClass, err = p.ident(); nil != err {
+			return err
+		}
+	}
+	if "class" != kw {
+		// the association and indication keywords of 3.0 stand for the qualifiers of 2.x
+		found := false
+		for _, q := range mqs {
+			found = found || strings.EqualFold(kw, q.name)
+		}
+		if false == found {
+			mqs = append(mqs, mofQualifier{name: strings.ToUpper(kw[:1]) + kw[1:], line: line})
+		}
+	}
+	if cls.Qualifier, err = p.qualifiers(mqs); nil != err {
+		return err
+	}
+	if err = p.expect("{"); nil != err {
+		return err
+	}
+	for false == p.isPunct("}") {
+		if err = p.parseFeature(cls); nil != err {
+			return err
+		}
+	}
+	p.next()
+	if err = p.expect(";"); nil != err {
+		return err
+	}
+	p.c.classes[p.c.classKey(p.c.namespace, name)] = cls
+	p.c.decls = append(p.c.decls, MOFDeclaration{Namespace: p.c.namespace, Class: cls})
+	return nil
+}
+
+// Returns the EmbeddedObject attribute the qualifiers of a property call for.
+func embeddedObject(quals []Qualifier) string {
+	for _, q := range quals {
+		switch {
+		case strings.EqualFold("EmbeddedInstance", q.Name):
+			return "instance"
+		case strings.EqualFold("EmbeddedObject", q.Name) && nil != q.Value && "true" == q.Value.Value:
+			return "object"
+		}
+	}
+	return ""
+}
+
+// [<qualifiers>] <type> <name>[<array>] [= <value>];
+// [<qualifiers>] <class> REF <name> [= <value>];
+// [<qualifiers>] <type> <name>(<parameter>, ...);
+func (p *mofParser) parseFeature(cls *Class) error {
+	mqs, err := p.parseQualifierList()
+	if nil != err {
+		return err
+	}
+	quals, err := p.qualifiers(mqs)
+	if nil != err {
+		return err
+	}
+	line := p.peek().line
+	typName, err := p.ident()
+	if nil != err {
+		return err
+	}
+	typ := CIMType(strings.ToLower(typName))
+	if (false == typ.IsValid() || CIMTypeReference == typ) && p.isKeyword("ref") {
+		p.next()
+		name, err := p.ident()
+		if nil != err {
+			return err
+		}
+		if p.isPunct("(") || p.isPunct("[") {
+			return mofErr(p.file, line, "%s of %s: methods and arrays of references are not supported", name, cls.Name)
+		}
+		prop := PropertyReference{Name: name, ReferenceClass: typName, Qualifier: quals}
+		if p.isPunct("=") {
+			p.next()
+			lit, err := p.parseValue()
+			if nil != err {
+				return err
+			}
+			if prop.ValueReference, err = p.reference(lit); nil != err {
+				return err
+			}
+		}
+		cls.PropertyReference = append(cls.PropertyReference, prop)
+		return p.expect(";")
+	}
+	isVoid := strings.EqualFold("void", typName)
+	if (false == typ.IsValid() || CIMTypeReference == typ) && false == isVoid {
+		return mofErr(p.file, line, "unknown type %s", typName)
+	}
+	name, err := p.ident()
+	if nil != err {
+		return err
+	}
+	if p.isPunct("(") {
+		meth := Method{Name: name, Qualifier: quals}
+		if false == isVoid {
+			meth.Type = string(typ)
+		}
+		if err = p.parseParameters(&meth); nil != err {
+			return err
+		}
+		cls.Method = append(cls.Method, meth)
+		return p.expect(";")
+	}
+	if isVoid {
+		return mofErr(p.file, line, "property %s cannot be void", name)
+	}
+	isArray, size, err := p.parseArraySpec()
+	if nil != err {
+		return err
+	}
+	var lit *mofLiteral
+	if p.isPunct("=") {
+		p.next()
+		if lit, err = p.parseValue(); nil != err {
+			return err
+		}
+	}
+	if isArray {
+		prop := PropertyArray{Name: name, Type: string(typ), ArraySize: size, EmbeddedObject: embeddedObject(quals), Qualifier: quals}
+		if nil != lit {
+			if _, prop.ValueArray, err = p.values(typ, true, lit); nil != err {
+				return err
+			}
+		}
+		cls.PropertyArray = append(cls.PropertyArray, prop)
+	} else {
+		prop := Property{Name: name, Type: string(typ), EmbeddedObject: embeddedObject(quals), Qualifier: quals}
+		if nil != lit {
+			if prop.Value, _, err = p.values(typ, false, lit); nil != err {
+				return err
+			}
+		}
+		cls.Property = append(cls.Property, prop)
+	}
+	return p.expect(";")
+}
+
+// (<qualifiers> <type> <name>[<array>] [= <default>], ...); defaults are parsed and ignored, as CIM-XML has none.
+func (p *mofParser) parseParameters(meth *Method) error {
+	p.next()
+	for false == p.isPunct(")") {
+		mqs, err := p.parseQualifierList()
+		if nil != err {
+			return err
+		}
+		quals, err := p.qualifiers(mqs)
+		if nil != err {
+			return err
+		}
+		line := p.peek().line
+		typName, err := p.ident()
+		if nil != err {
+			return err
+		}
+		typ := CIMType(strings.ToLower(typName))
+		isRef := false
+		if (false == typ.IsValid() || CIMTypeReference == typ) && p.isKeyword("ref") {
+			p.next()
+			isRef = true
+		} else if false == typ.IsValid() || CIMTypeReference == typ {
+			return mofErr(p.file, line, "unknown type %s", typName)
+		}
+		name, err := p.ident()
+		if nil != err {
+			return err
+		}
+		isArray, size, err := p.parseArraySpec()
+		if nil != err {
+			return err
+		}
+		if p.isPunct("=") {
+			p.next()
+			if _, err = p.parseValue(); nil != err {
+				return err
+			}
+		}
+		switch {
+		case isRef && isArray:
+			meth.ParameterRefArray = append(meth.ParameterRefArray, ParameterRefArray{Name: name, ReferenceClass: typName, ArraySize: size, Qualifier: quals})
+		case isRef:
+			meth.ParameterReference = append(meth.ParameterReference, ParameterReference{Name: name, ReferenceClass: typName, Qualifier: quals})
+		case isArray:
+			meth.ParameterArray = append(meth.ParameterArray, ParameterArray{Name: name, Type: string(typ), ArraySize: size, Qualifier: quals})
+		default:
+			meth.Parameter = append(meth.Parameter, Parameter{Name: name, Type: string(typ), Qualifier: quals})
+		}
+		if false == p.isPunct(")") {
+			if err = p.expect(","); nil != err {
+				return err
+			}
+		}
+	}
+	return p.expect(")")
+}
+
+// instance of <class> [as $<alias> | $<alias>] { [<qualifiers>] <property> = <value>; ... };
+func (p *mofParser) parseInstance(mqs []mofQualifier) error {
+	line := p.peek().line
+	if false == p.isKeyword("of") {
+		return p.errorf("expecting \"of\", found %s", p.describe())
+	}
+	p.next()
+	className, err := p.ident()
+	if nil != err {
+		return err
+	}
+	alias := ""
+	if p.isKeyword("as") {
+		p.next()
+		if mofAlias != p.peek().kind {
+			return p.errorf("expecting an alias, found %s", p.describe())
+		}
+	}
+	if mofAlias == p.peek().kind {
+		alias = p.next().text
+		if _, ok := p.c.aliases[strings.ToLower(alias)]; ok {
+			return mofErr(p.file, line, "alias $%s is already defined", alias)
+		}
+	}
+	quals, err := p.qualifiers(mqs)
+	if nil != err {
+		return err
+	}
+	inst, props, err := p.parseInstanceBody(className, line)
+	if nil != err {
+		return err
+	}
+	inst.Qualifier = quals
+	if err = p.expect(";"); nil != err {
+		return err
+	}
+	decl := MOFDeclaration{Namespace: p.c.namespace, Instance: inst, Alias: alias}
+	var nameErr error
+	if nil == props {
+		nameErr = fmt.Errorf("class %s is unknown", className)
+	} else {
+		decl.InstanceName, nameErr = instanceName(inst, props)
+	}
+	if "" != alias {
+		p.c.aliases[strings.ToLower(alias)] = mofAliasEntry{decl.InstanceName, nameErr}
+	}
+	p.c.decls = append(p.c.decls, decl)
+	return nil
+}
+
+// { [<qualifiers>] <property> = <value>; ... }, returning the instance with the properties of its class.
+func (p *mofParser) parseInstanceBody(className string, line int) (*Instance, map[string]*mofProperty, error) {
+	inst := &Instance{ClassName: className}
+	props, err := p.c.classProperties(p.c.namespace, className)
+	if nil != err {
+		return nil, nil, mofErr(p.file, line, "class %s: %s", className, err.Error())
+	}
+	if err = p.expect("{"); nil != err {
+		return nil, nil, err
+	}
+	for false == p.isPunct("}") {
+		if err = p.parsePropertyValue(inst, props); nil != err {
+			return nil, nil, err
+		}
+	}
+	p.next()
+	return inst, props, nil
+}
+
+// instance of <class> { ... } as a value, which is the string of its CIM-XML as embedded instances are.
+func (p *mofParser) parseEmbeddedInstance() (*mofLiteral, error) {
+	line := p.next().line
+	if false == p.isKeyword("of") {
+		return nil, p.errorf("expecting \"of\", found %s", p.describe())
+	}
+	p.next()
+	className, err := p.ident()
+	if nil != err {
+		return nil, err
+	}
+	inst, _, err := p.parseInstanceBody(className, line)
+	if nil != err {
+		return nil, err
+	}
+	var text strings.Builder
+	if err = xml.NewEncoder(&text).EncodeElement(inst, xml.StartElement{Name: xml.Name{Local: "INSTANCE"}}); nil != err {
+		return nil, mofErr(p.file, line, "%s", err.Error())
+	}
+	return &mofLiteral{kind: mofString, text: text.String(), line: line}, nil
+}
+
+func (p *mofParser) parsePropertyValue(inst *Instance, props map[string]*mofProperty) error {
+	mqs, err := p.parseQualifierList()
+	if nil != err {
+		return err
+	}
+	quals, err := p.qualifiers(mqs)
+	if nil != err {
+		return err
+	}
+	line := p.peek().line
+	name, err := p.ident()
+	if nil != err {
+		return err
+	}
+	if err = p.expect("="); nil != err {
+		return err
+	}
+	lit, err := p.parseValue()
+	if nil != err {
+		return err
+	}
+	if err = p.expect(";"); nil != err {
+		return err
+	}
+	prop := &mofProperty{name: name, typ: inferCIMType(lit), isArray: lit.isArray()}
+	if nil != props {
+		if prop = props[strings.ToLower(name)]; nil == prop {
+			return newCIMErr(ErrNoSuchProperty, fmt.Sprintf("%s:%d: no property %s in class %s", p.file, line, name, inst.ClassName), nil)
+		}
+	}
+	if CIMTypeReference == prop.typ {
+		if prop.isArray {
+			return mofErr(p.file, line, "arrays of references are not supported")
+		}
+		ref, err := p.reference(lit)
+		if nil != err {
+			return err
+		}
+		inst.PropertyReference = append(inst.PropertyReference, PropertyReference{Name: prop.name, ReferenceClass: prop.refClass, Qualifier: quals, ValueReference: ref})
+		return nil
+	}
+	value, arry, err := p.values(prop.typ, prop.isArray, lit)
+	if nil != err {
+		return err
+	}
+	if prop.isArray {
+		inst.PropertyArray = append(inst.PropertyArray, PropertyArray{Name: prop.name, Type: string(prop.typ), EmbeddedObject: prop.embedded, Qualifier: quals, ValueArray: arry})
+	} else {
+		inst.Property = append(inst.Property, Property{Name: prop.name, Type: string(prop.typ), EmbeddedObject: prop.embedded, Qualifier: quals, Value: value})
+	}
+	return nil
+}
+
+// A property of a class, with the ones it inherits.
+type mofProperty struct {
+	name     string
+	typ      CIMType
+	isArray  bool
+	refClass string
+	embedded string
+	key      bool
+}
+
+func (c *MOFCompiler) classKey(namespace, className string) string {
+	return strings.ToLower(namespace) + ":" + strings.ToLower(className)
+}
+
+// Returns the class the MOF declares, or else the one GetClass returns; nil when it is unknown.
+func (c *MOFCompiler) class(namespace, className string) (*Class, error) {
+	key := c.classKey(namespace, className)
+	if cls, ok := c.classes[key]; ok {
+		return cls, nil
+	}
+	if nil == c.GetClass {
+		return nil, nil
+	}
+	cls, err := c.GetClass(namespace, className)
+	if nil != err {
+		return nil, err
+	}
+	// an unknown class is remembered as well, so that it is asked for once
+	c.classes[key] = cls
+	return cls, nil
+}
+
+// Returns the properties of a class by their lower-cased names, nil when the class is unknown.
+func (c *MOFCompiler) classProperties(namespace, className string) (map[string]*mofProperty, error) {
+	props := make(map[string]*mofProperty)
+	seen := make(map[string]bool)
+	var chain []*Class
+	for name := className; "" != name && false == seen[strings.ToLower(name)]; {
+		seen[strings.ToLower(name)] = true
+		cls, err := c.class(namespace, name)
+		if nil != err {
+			return nil, err
+		}
+		if nil == cls {
+			break
+		}
+		chain = append(chain, cls)
+		name = cls.SuperClass
+	}
+	if 0 == len(chain) {
+		return nil, nil
+	}
+	// from the root class down, so that subclasses override their superclasses
+	for i := len(chain) - 1; 0 <= i; i-- {
+		cls := chain[i]
+		add := func(prop *mofProperty, quals []Qualifier) {
+			key := strings.ToLower(prop.name)
+			prop.key = qualifierIsTrue(quals, "Key") || (nil != props[key] && props[key].key)
+			props[key] = prop
+		}
+		for _, prop := range cls.Property {
+			add(&mofProperty{name: prop.Name, typ: CIMType(prop.Type), embedded: prop.EmbeddedObject}, prop.Qualifier)
+		}
+		for _, prop := range cls.PropertyArray {
+			add(&mofProperty{name: prop.Name, typ: CIMType(prop.Type), isArray: true, embedded: prop.EmbeddedObject}, prop.Qualifier)
+		}
+		for _, prop := range cls.PropertyReference {
+			add(&mofProperty{name: prop.Name, typ: CIMTypeReference, refClass: prop.ReferenceClass}, prop.Qualifier)
+		}
+	}
+	return props, nil
+}
+
+func qualifierIsTrue(quals []Qualifier, name string) bool {
+	for _, q := range quals {
+		if strings.EqualFold(name, q.Name) {
+			return nil != q.Value && "true" == strings.ToLower(strings.TrimSpace(q.Value.Value))
+		}
+	}
+	return false
+}
+
+// Returns the name of an instance from the values of the keys of its class.
+func instanceName(inst *Instance, props map[string]*mofProperty) (*InstanceName, error) {
+	var keys []string
+	for key, prop := range props {
+		if prop.key {
+			keys = append(keys, key)
+		}
+	}
+	if 0 == len(keys) {
+		return nil, fmt.Errorf("class %s has no keys", inst.ClassName)
+	}
+	sort.Strings(keys)
+	name := &InstanceName{ClassName: inst.ClassName}
+	for _, key := range keys {
+		prop := props[key]
+		val, err := inst.GetValue(prop.name)
+		if nil == err && val.IsNull() {
+			err = ErrNullValue
+		}
+		if nil != err {
+			return nil, fmt.Errorf("key %s: %w", prop.name, err)
+		}
+		if CIMTypeReference == val.Type {
+			name.KeyBinding = append(name.KeyBinding, KeyBinding{Name: prop.name, ValueReference: val.Value.(*ValueReference)})
+			continue
+		}
+		kv, err := NewKeyValue(val)
+		if nil != err {
+			return nil, fmt.Errorf("key %s: %w", prop.name, err)
+		}
+		name.KeyBinding = append(name.KeyBinding, KeyBinding{Name: prop.name, KeyValue: kv})
+	}
+	return name, nil
+}
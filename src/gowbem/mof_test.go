@@ -0,0 +1,224 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parseTestMOF(t *testing.T, src string) []MOFDeclaration {
+	decls, err := ParseMOF(strings.NewReader(src), "test.mof")
+	if nil != err {
+		t.Fatalf("ParseMOF: %v", err)
+	}
+	return decls
+}
+
+func TestMOFLiterals(t *testing.T) {
+	tests := []struct {
+		name  string
+		typ   string
+		value string
+		want  string
+	}{
+		{"escapes", "string", `"tab\t nl\n cr\r quote\" apos\' backslash\\"`, "tab\t nl\n cr\r quote\" apos' backslash\\"},
+		{"hex escapes", "string", `"\x41\X263a"`, "A☺"},
+		{"concatenated", "string", `"con" "cat" "enated"`, "concatenated"},
+		{"char16", "char16", `'x'`, "x"},
+		{"char16 escape", "char16", `'\n'`, "\n"},
+		{"binary", "uint8", "1010b", "10"},
+		{"binary upper case", "uint8", "11B", "3"},
+		{"octal", "sint8", "-017", "-15"},
+		{"hex", "uint32", "0x1F", "31"},
+		{"negative hex", "sint32", "-0x10", "-16"},
+		{"decimal", "uint64", "18446744073709551615", "18446744073709551615"},
+		{"zero", "uint16", "0", "0"},
+		{"real exponent", "real64", "1.5e3", "1500.0"},
+		{"real without integer part", "real32", ".5", "0.5"},
+		{"boolean", "boolean", "TRUE", "true"},
+		{"datetime", "datetime", `"20200102030405.000000+000"`, "20200102030405.000000+000"},
+	}
+	for _, test := range tests {
+		decls := parseTestMOF(t, "class Test_Literal { [Key] string ID; "+test.typ+" P; };\n"+
+			"instance of Test_Literal { ID = \"a\"; P = "+test.value+"; };")
+		if got := propertyString(decls[1].Instance, "P"); test.want != got {
+			t.Errorf("%s: %s got %q, want %q", test.name, test.value, got, test.want)
+		}
+	}
+}
+
+func TestMOFArrays(t *testing.T) {
+	decls := parseTestMOF(t, `class Test_Array { [Key] string ID; uint16 Codes[]; string Names[]; };
+instance of Test_Array { ID = "a"; Codes = {1, 0x10, 11b}; Names = "one"; };`)
+	if got := propertyStrings(decls[1].Instance, "Codes"); false == reflect.DeepEqual([]string{"1", "16", "3"}, got) {
+		t.Errorf("Codes %q", got)
+	}
+	if got := propertyStrings(decls[1].Instance, "Names"); false == reflect.DeepEqual([]string{"one"}, got) {
+		t.Errorf("Names %q", got)
+	}
+}
+
+func TestMOFAliases(t *testing.T) {
+	decls := parseTestMOF(t, `class Test_Node { [Key] string ID; Test_Node REF Parent; };
+instance of Test_Node as $Root { ID = "root"; };
+instance of Test_Node as $child { ID = "child"; Parent = $Root; };
+instance of Test_Node { ID = "grandchild"; Parent = $CHILD; };`)
+	if "Root" != decls[1].Alias || "child" != decls[2].Alias || "" != decls[3].Alias {
+		t.Errorf("aliases %q, %q, %q", decls[1].Alias, decls[2].Alias, decls[3].Alias)
+	}
+	for i, want := range []string{"Test_Node.ID=\"root\"", "Test_Node.ID=\"child\""} {
+		refs := decls[i+2].Instance.PropertyReference
+		if 1 != len(refs) || nil == refs[0].ValueReference || nil == refs[0].ValueReference.InstanceName {
+			t.Fatalf("instance %d: references %+v", i+2, refs)
+		}
+		if got := refs[0].ValueReference.InstanceName.String(); want != got {
+			t.Errorf("instance %d: Parent %s, want %s", i+2, got, want)
+		}
+	}
+}
+
+func TestMOFInstanceName(t *testing.T) {
+	decls := parseTestMOF(t, `class Test_Base { [Key] string CreationClassName; [Key] string Name; string Caption; };
+class Test_Disk : Test_Base { [Key] uint32 Slot; Test_Base REF Controller; };
+class Test_Keyless { string Caption; };
+instance of Test_Disk { CreationClassName = "Test_Disk"; Name = "disk \"0\""; Slot = 0x2; Caption = "first"; };
+instance of Test_Keyless { Caption = "none"; };
+instance of Unknown_Class { Name = "x"; };`)
+	name := decls[3].InstanceName
+	if nil == name {
+		t.Fatalf("no instance name")
+	}
+	if want := `Test_Disk.CreationClassName="Test_Disk",Name="disk \"0\"",Slot=2`; want != name.String() {
+		t.Errorf("instance name %s, want %s", name.String(), want)
+	}
+	if nil != decls[4].InstanceName || nil != decls[5].InstanceName {
+		t.Errorf("instance names %v, %v of a keyless and an unknown class", decls[4].InstanceName, decls[5].InstanceName)
+	}
+	// the values of an unknown class are typed by their literals
+	if got := decls[5].Instance.Property[0].Type; "string" != got {
+		t.Errorf("type %q of an unknown class property", got)
+	}
+}
+
+func TestMOFOrder(t *testing.T) {
+	decls := parseTestMOF(t, `class Test_Disk : Test_Device { Test_Controller REF Controller; };
+class Test_Controller : Test_Device { };
+class Test_Device { [Key] string ID; };
+class Test_Other { };
+instance of Test_Disk { ID = "disk"; Controller = "Test_Controller.ID=\"ctrl\""; };
+instance of Test_Controller { ID = "ctrl"; };
+instance of Test_Device { ID = "device"; };`)
+	var classes []string
+	for _, decl := range orderClasses(decls) {
+		classes = append(classes, decl.Class.Name)
+	}
+	if want := []string{"Test_Device", "Test_Controller", "Test_Disk", "Test_Other"}; false == reflect.DeepEqual(want, classes) {
+		t.Errorf("classes %v, want %v", classes, want)
+	}
+	var instances []string
+	for _, decl := range orderInstances(decls) {
+		instances = append(instances, decl.InstanceName.String())
+	}
+	want := []string{`Test_Controller.ID="ctrl"`, `Test_Disk.ID="disk"`, `Test_Device.ID="device"`}
+	if false == reflect.DeepEqual(want, instances) {
+		t.Errorf("instances %v, want %v", instances, want)
+	}
+}
+
+func TestMOFErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"unterminated string", "class Test_A {\n string P = \"abc\n};", "test.mof:2: newline in a literal"},
+		{"unknown escape", "class Test_A {\n\n string P = \"\\q\";\n};", "test.mof:3: unknown escape \\q"},
+		{"unterminated comment", "// ok\n/* never ends", "test.mof:2: unterminated comment"},
+		{"invalid value", "class Test_A {\n\tuint8 P = 256;\n};", `test.mof:2: invalid uint8 value "256"`},
+		{"invalid binary", "class Test_A {\n\tuint8 P = 102b;\n};", `test.mof:2: invalid uint8 value "102b"`},
+		{"missing semicolon", "class Test_A {\n\tstring P\n};", `test.mof:3: expecting ";", found "}"`},
+		{"unknown declaration", "\n\nstruct Test_A { };", `test.mof:3: expecting a declaration, found "struct"`},
+		{"unknown property", "class Test_A { [Key] string ID; };\ninstance of Test_A {\n ID = \"a\";\n Other = 1;\n};", "test.mof:4: no property Other in class Test_A"},
+		{"duplicate alias", "class Test_A { [Key] string ID; };\ninstance of Test_A as $a { ID = \"a\"; };\ninstance of Test_A as $A { ID = \"b\"; };", "test.mof:3: alias $A is already defined"},
+		{"undefined alias", "class Test_A { [Key] string ID; Test_A REF R; };\ninstance of Test_A {\n ID = \"a\";\n R = $none;\n};", "test.mof:4: undefined alias $none"},
+		{"null array element", "class Test_A { [Key] string ID; uint16 Codes[]; };\ninstance of Test_A { ID = \"a\";\n Codes = {1,\n NULL, 3}; };", "test.mof:4: NULL elements of arrays are not supported"},
+		{"array of arrays", "class Test_A {\n\tuint16 Codes[] = {1, {2}};\n};", "test.mof:2: arrays of arrays are not allowed"},
+	}
+	for _, test := range tests {
+		_, err := ParseMOF(strings.NewReader(test.src), "test.mof")
+		if nil == err {
+			t.Errorf("%s: no error", test.name)
+			continue
+		}
+		if false == strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, err.Error(), test.want)
+		}
+	}
+}
+
+func TestMOFIncludeCycle(t *testing.T) {
+	files := map[string]string{
+		"main.mof": "#pragma include (\"a.mof\")\nclass Test_Main { };",
+		"a.mof":    "class Test_A { };\n#pragma include (\"b.mof\")",
+		"b.mof":    "\n#pragma include (\"a.mof\")",
+		"self.mof": "#pragma include (\"self.mof\")",
+		"ok.mof":   "#pragma include (\"b2.mof\")\n#pragma include (\"b2.mof\")",
+		"b2.mof":   "qualifier Test : boolean = false;",
+	}
+	c := NewMOFCompiler()
+	c.Include = func(file string) (io.ReadCloser, error) {
+		src, ok := files[file]
+		if false == ok {
+			return nil, os.ErrNotExist
+		}
+		return ioutil.NopCloser(strings.NewReader(src)), nil
+	}
+	err := c.Compile(strings.NewReader(files["main.mof"]), "main.mof")
+	if nil == err || false == strings.Contains(err.Error(), "b.mof:2: cannot include a.mof: it includes itself") {
+		t.Errorf("include cycle: got %v", err)
+	}
+	err = c.Compile(strings.NewReader(files["self.mof"]), "self.mof")
+	if nil == err || false == strings.Contains(err.Error(), "self.mof:1: cannot include self.mof: it includes itself") {
+		t.Errorf("self include: got %v", err)
+	}
+	// a file may be included again once it is done
+	if err = c.Compile(strings.NewReader(files["ok.mof"]), "ok.mof"); nil != err {
+		t.Errorf("repeated include: %v", err)
+	}
+
+	// the default include resolves the files relative to the including one
+	dir, err := ioutil.TempDir("", "mof")
+	if nil != err {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if err = ioutil.WriteFile(filepath.Join(dir, "self.mof"), []byte(files["self.mof"]), 0644); nil != err {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	_, err = ParseMOFFile(filepath.Join(dir, ".", "self.mof"))
+	if nil == err || false == strings.Contains(err.Error(), "it includes itself") || false == errors.Is(err, ErrInvalidParameter) {
+		t.Errorf("self include from a file: got %v", err)
+	}
+}
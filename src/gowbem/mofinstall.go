@@ -0,0 +1,175 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Returns a connection to the namespace of a declaration, the one of conn when it has none.
+func (conn *WBEMConnection) inNamespace(namespace string) *WBEMConnection {
+	if "" == namespace || strings.EqualFold(namespace, conn.namespace) {
+		return conn
+	}
+	c := *conn
+	c.namespace = namespace
+	return &c
+}
+
+// NewMOFCompiler returns a MOF compiler that types the instances of the classes the MOF does not
+// declare by the classes of the WBEM server.
+func (conn *WBEMConnection) NewMOFCompiler() *MOFCompiler {
+	c := NewMOFCompiler()
+	c.GetClass = func(namespace, className string) (*Class, error) {
+		classes, err := conn.inNamespace(namespace).GetClass(&ClassName{Name: className}, false, true, false, nil)
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalidClass) {
+			return nil, nil
+		}
+		if nil != err {
+			return nil, err
+		}
+		if 0 == len(classes) {
+			return nil, nil
+		}
+		return &classes[0], nil
+	}
+	return c
+}
+
+// InstallMOF installs the declarations of a MOF, each in the namespace of its #pragma namespace
+// or else in the target namespace:
+//      qualifier declarations are set first, with SetQualifier
+//      classes follow, superclasses and referenced classes first, with CreateClass, or ModifyClass when they already exist
+//      instances come last, referenced instances first, with CreateInstance, or ModifyInstance when they already exist
+// It stops at the first error.
+func (conn *WBEMConnection) InstallMOF(decls []MOFDeclaration) error {
+	return conn.InstallMOFContext(context.Background(), decls)
+}
+
+// InstallMOFContext is the same as InstallMOF, with ctx carried to the HTTP requests.
+func (conn *WBEMConnection) InstallMOFContext(ctx context.Context, decls []MOFDeclaration) error {
+	for _, decl := range decls {
+		if nil == decl.QualifierDeclaration {
+			continue
+		}
+		if err := conn.inNamespace(decl.Namespace).SetQualifierContext(ctx, decl.QualifierDeclaration); nil != err {
+			return fmt.Errorf("qualifier %s: %w", decl.QualifierDeclaration.Name, err)
+		}
+	}
+	for _, decl := range orderClasses(decls) {
+		c := conn.inNamespace(decl.Namespace)
+		err := c.CreateClassContext(ctx, decl.Class)
+		if errors.Is(err, ErrAlreadyExists) {
+			err = c.ModifyClassContext(ctx, decl.Class)
+		}
+		if nil != err {
+			return fmt.Errorf("class %s: %w", decl.Class.Name, err)
+		}
+	}
+	for _, decl := range orderInstances(decls) {
+		c := conn.inNamespace(decl.Namespace)
+		err := c.CreateInstanceContext(ctx, decl.Instance)
+		if errors.Is(err, ErrAlreadyExists) && nil != decl.InstanceName {
+			err = c.ModifyInstanceContext(ctx, &ValueNamedInstance{InstanceName: decl.InstanceName, Instance: decl.Instance}, nil)
+		}
+		if nil != err {
+			if nil != decl.InstanceName {
+				return fmt.Errorf("instance %s: %w", decl.InstanceName.String(), err)
+			}
+			return fmt.Errorf("instance of %s: %w", decl.Instance.ClassName, err)
+		}
+	}
+	return nil
+}
+
+// Returns the class declarations, each after its superclass and the classes it references
+// when they are declared as well.
+func orderClasses(decls []MOFDeclaration) []MOFDeclaration {
+	byName := make(map[string]int)
+	for i, decl := range decls {
+		if nil != decl.Class {
+			byName[strings.ToLower(decl.Namespace+":"+decl.Class.Name)] = i
+		}
+	}
+	var ordered []MOFDeclaration
+	state := make(map[int]int) // 1 while visiting, 2 once ordered
+	var visit func(i int)
+	visit = func(i int) {
+		if 0 != state[i] {
+			// ordered already, or a cycle of references, which the server resolves if it can
+			return
+		}
+		state[i] = 1
+		cls := decls[i].Class
+		deps := []string{cls.SuperClass}
+		for _, prop := range cls.PropertyReference {
+			deps = append(deps, prop.ReferenceClass)
+		}
+		for _, dep := range deps {
+			if j, ok := byName[strings.ToLower(decls[i].Namespace+":"+dep)]; ok && "" != dep {
+				visit(j)
+			}
+		}
+		state[i] = 2
+		ordered = append(ordered, decls[i])
+	}
+	for i, decl := range decls {
+		if nil != decl.Class {
+			visit(i)
+		}
+	}
+	return ordered
+}
+
+// Returns the instance declarations, each after the instances its references name.
+func orderInstances(decls []MOFDeclaration) []MOFDeclaration {
+	var byName InstanceNameMap
+	for i, decl := range decls {
+		if nil != decl.Instance && nil != decl.InstanceName {
+			byName.Set(decl.InstanceName, i)
+		}
+	}
+	var ordered []MOFDeclaration
+	state := make(map[int]int)
+	var visit func(i int)
+	visit = func(i int) {
+		if 0 != state[i] {
+			return
+		}
+		state[i] = 1
+		for _, prop := range decls[i].Instance.PropertyReference {
+			if nil == prop.ValueReference || nil == prop.ValueReference.InstanceName {
+				continue
+			}
+			if j, ok := byName.Get(prop.ValueReference.InstanceName); ok {
+				visit(j.(int))
+			}
+		}
+		state[i] = 2
+		ordered = append(ordered, decls[i])
+	}
+	for i, decl := range decls {
+		if nil != decl.Instance {
+			visit(i)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,551 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Object paths are formatted and parsed as untyped WBEM URIs of DSP0207:
+//      [[<scheme>:]//<host>[:<port>]]/<namespace>:<class>[.<key>=<value>{,<key>=<value>}]
+// Key values are written as follows:
+//      string, datetime    "text", with \ and " escaped by \
+//      char16              'c', with \ and ' escaped by \
+//      boolean             TRUE or FALSE
+//      numeric             123, -1, 0x1F, 1.5E+10
+//      reference           "//host/root/cimv2:CIM_Foo.Name=\"x\"", the WBEM URI of the reference as a string
+// As URIs are untyped, a quoted key value is read back as a reference only when it is the WBEM URI of an instance
+// that starts with / or a scheme, or with <class>.<key>= as a reference to a bare instance name does; any other
+// quoted value stays a string, and ResolveReferenceKeys converts the keys a class declares as REF. The exact
+// integer or real type of a numeric key is not kept.
+
+// NewLocalNamespacePath splits a namespace such as root/cimv2 into a LOCALNAMESPACEPATH.
+func NewLocalNamespacePath(namespace string) *LocalNamespacePath {
+	var ns []Namespace
+	for _, sub := range strings.Split(strings.Trim(namespace, "/"), "/") {
+		ns = append(ns, Namespace{Name: sub})
+	}
+	return &LocalNamespacePath{ns}
+}
+
+// NewNamespacePath returns the NAMESPACEPATH of namespace on host, which is <host>[:<port>].
+func NewNamespacePath(host, namespace string) *NamespacePath {
+	return &NamespacePath{
+		Host:               &Host{host},
+		LocalNamespacePath: NewLocalNamespacePath(namespace),
+	}
+}
+
+// NewInstancePath returns the INSTANCEPATH of the instance name in namespace on host.
+func NewInstancePath(host, namespace string, name *InstanceName) *InstancePath {
+	return &InstancePath{
+		NamespacePath: NewNamespacePath(host, namespace),
+		InstanceName:  name,
+	}
+}
+
+// NewLocalInstancePath returns the LOCALINSTANCEPATH of the instance name in namespace.
+func NewLocalInstancePath(namespace string, name *InstanceName) *LocalInstancePath {
+	return &LocalInstancePath{
+		LocalNamespacePath: NewLocalNamespacePath(namespace),
+		InstanceName:       name,
+	}
+}
+
+// NewClassPath returns the CLASSPATH of the class in namespace on host.
+func NewClassPath(host, namespace string, className string) *ClassPath {
+	return &ClassPath{
+		NamespacePath: NewNamespacePath(host, namespace),
+		ClassName:     &ClassName{className},
+	}
+}
+
+// String returns the namespace, such as root/cimv2.
+func (path *LocalNamespacePath) String() string {
+	if nil == path {
+		return ""
+	}
+	names := make([]string, 0, len(path.Namespace))
+	for _, ns := range path.Namespace {
+		names = append(names, ns.Name)
+	}
+	return strings.Join(names, "/")
+}
+
+// String returns //<host>/<namespace>.
+func (path *NamespacePath) String() string {
+	if nil == path {
+		return ""
+	}
+	return formatObjectPath(path.Host, path.LocalNamespacePath, "")
+}
+
+// Formats the parts of a WBEM URI that are present.
+func formatObjectPath(host *Host, ns *LocalNamespacePath, name string) string {
+	s := ""
+	if nil != host {
+		s += "//" + host.Host
+	}
+	if nil != ns {
+		s += "/" + ns.String()
+		if "" != name {
+			s += ":"
+		}
+	}
+	return s + name
+}
+
+// String returns the WBEM URI of the instance name, such as CIM_Foo.Name="x".
+func (name *InstanceName) String() string {
+	if nil == name {
+		return ""
+	}
+	return name.ClassName + name.formatKeys()
+}
+
+func (name *InstanceName) formatKeys() string {
+	switch {
+	case nil != name.KeyValue:
+		return "=" + formatKeyValue(name.KeyValue)
+	case nil != name.ValueReference:
+		return "=" + quoteKeyString(name.ValueReference.String(), '"')
+	}
+	keys := make([]string, 0, len(name.KeyBinding))
+	for _, key := range name.KeyBinding {
+		keys = append(keys, key.String())
+	}
+	if 0 == len(keys) {
+		return ""
+	}
+	return "." + strings.Join(keys, ",")
+}
+
+// String returns <key>=<value>.
+func (key *KeyBinding) String() string {
+	if nil != key.ValueReference {
+		return key.Name + "=" + quoteKeyString(key.ValueReference.String(), '"')
+	}
+	if nil != key.KeyValue {
+		return key.Name + "=" + formatKeyValue(key.KeyValue)
+	}
+	return key.Name + "=\"\""
+}
+
+func formatKeyValue(kv *KeyValue) string {
+	switch {
+	case "boolean" == kv.ValueType || CIMTypeBoolean == CIMType(kv.Type):
+		return strings.ToUpper(strings.TrimSpace(kv.KeyValue))
+	case "numeric" == kv.ValueType:
+		return strings.TrimSpace(kv.KeyValue)
+	case CIMTypeChar16 == CIMType(kv.Type):
+		return quoteKeyString(kv.KeyValue, '\'')
+	}
+	return quoteKeyString(kv.KeyValue, '"')
+}
+
+func quoteKeyString(s string, quote byte) string {
+	var b strings.Builder
+	b.WriteByte(quote)
+	for i := 0; i < len(s); i++ {
+		if '\\' == s[i] || quote == s[i] {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte(quote)
+	return b.String()
+}
+
+// String returns //<host>/<namespace>:<class>.<keys>.
+func (path *InstancePath) String() string {
+	if nil == path {
+		return ""
+	}
+	var host *Host
+	var ns *LocalNamespacePath
+	if nil != path.NamespacePath {
+		host, ns = path.NamespacePath.Host, path.NamespacePath.LocalNamespacePath
+	}
+	return formatObjectPath(host, ns, path.InstanceName.String())
+}
+
+// String returns /<namespace>:<class>.<keys>.
+func (path *LocalInstancePath) String() string {
+	if nil == path {
+		return ""
+	}
+	return formatObjectPath(nil, path.LocalNamespacePath, path.InstanceName.String())
+}
+
+// String returns //<host>/<namespace>:<class>.
+func (path *ClassPath) String() string {
+	if nil == path {
+		return ""
+	}
+	var host *Host
+	var ns *LocalNamespacePath
+	if nil != path.NamespacePath {
+		host, ns = path.NamespacePath.Host, path.NamespacePath.LocalNamespacePath
+	}
+	return formatObjectPath(host, ns, path.ClassName.String())
+}
+
+// String returns /<namespace>:<class>.
+func (path *LocalClassPath) String() string {
+	if nil == path {
+		return ""
+	}
+	return formatObjectPath(nil, path.LocalNamespacePath, path.ClassName.String())
+}
+
+// String returns the class name.
+func (name *ClassName) String() string {
+	if nil == name {
+		return ""
+	}
+	return name.Name
+}
+
+// String returns the WBEM URI of the instance or the class.
+func (path *ObjectPath) String() string {
+	if nil == path {
+		return ""
+	}
+	if nil != path.InstancePath {
+		return path.InstancePath.String()
+	}
+	return path.ClassPath.String()
+}
+
+// String returns the WBEM URI of the reference.
+func (ref *ValueReference) String() string {
+	switch {
+	case nil == ref:
+		return ""
+	case nil != ref.InstancePath:
+		return ref.InstancePath.String()
+	case nil != ref.LocalInstancePath:
+		return ref.LocalInstancePath.String()
+	case nil != ref.InstanceName:
+		return ref.InstanceName.String()
+	case nil != ref.ClassPath:
+		return ref.ClassPath.String()
+	case nil != ref.LocalClassPath:
+		return ref.LocalClassPath.String()
+	}
+	return ref.ClassName.String()
+}
+
+// The parts of a parsed WBEM URI.
+type parsedPath struct {
+	host       *Host
+	namespace  *LocalNamespacePath
+	name       *InstanceName
+	isInstance bool
+}
+
+func pathErr(s string, reason string) error {
+	return newCIMErr(ErrInvalidParameter, fmt.Sprintf("invalid object path %q: %s", s, reason), nil)
+}
+
+func parsePath(s string) (*parsedPath, error) {
+	var path parsedPath
+	rest := s
+	for _, scheme := range []string{"http:", "https:"} {
+		if strings.HasPrefix(strings.ToLower(rest), scheme+"//") {
+			rest = rest[len(scheme):]
+		}
+	}
+	if strings.HasPrefix(rest, "//") {
+		end := strings.IndexByte(rest[2:], '/')
+		if 0 > end {
+			return nil, pathErr(s, "no namespace after the host")
+		}
+		path.host = &Host{rest[2 : 2+end]}
+		if "" == path.host.Host {
+			return nil, pathErr(s, "empty host")
+		}
+		rest = rest[2+end:]
+	}
+	// the namespace may contain '.', but ends before the first key value
+	head := rest
+	if end := strings.IndexAny(rest, "=\"'"); 0 <= end {
+		head = rest[:end]
+	}
+	if colon := strings.LastIndexByte(head, ':'); 0 <= colon {
+		ns := strings.Trim(head[:colon], "/")
+		if "" == ns {
+			return nil, pathErr(s, "empty namespace")
+		}
+		for _, sub := range strings.Split(ns, "/") {
+			if "" == sub || strings.ContainsAny(sub, "\"'") {
+				return nil, pathErr(s, "invalid namespace")
+			}
+		}
+		path.namespace = NewLocalNamespacePath(ns)
+		rest = rest[colon+1:]
+	} else if nil != path.host || strings.HasPrefix(head, "/") {
+		return nil, pathErr(s, "no class after the namespace")
+	}
+	path.name = &InstanceName{}
+	className := rest
+	if end := strings.IndexAny(rest, ".="); 0 <= end {
+		className, rest = rest[:end], rest[end:]
+		path.isInstance = true
+	} else {
+		rest = ""
+	}
+	if false == isCIMName(className) {
+		return nil, pathErr(s, fmt.Sprintf("invalid class name %q", className))
+	}
+	path.name.ClassName = className
+	switch {
+	case strings.HasPrefix(rest, "="):
+		key, next, err := parseKeyValue(s, rest[1:])
+		if nil != err {
+			return nil, err
+		}
+		if "" != next {
+			return nil, pathErr(s, "trailing characters after the key")
+		}
+		path.name.KeyValue, path.name.ValueReference = key.KeyValue, key.ValueReference
+	case strings.HasPrefix(rest, "."):
+		rest = rest[1:]
+		for {
+			eq := strings.IndexByte(rest, '=')
+			if 0 > eq || false == isCIMName(rest[:eq]) {
+				return nil, pathErr(s, "invalid key binding")
+			}
+			key, next, err := parseKeyValue(s, rest[eq+1:])
+			if nil != err {
+				return nil, err
+			}
+			key.Name = rest[:eq]
+			path.name.KeyBinding = append(path.name.KeyBinding, *key)
+			if "" == next {
+				break
+			}
+			if ',' != next[0] {
+				return nil, pathErr(s, "expecting , between key bindings")
+			}
+			rest = next[1:]
+		}
+	}
+	return &path, nil
+}
+
+// Reports whether s is a valid class, property or key name.
+func isCIMName(s string) bool {
+	if "" == s {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case '_' == c, 'a' <= c && 'z' >= c, 'A' <= c && 'Z' >= c, 0x80 <= c:
+		case '0' <= c && '9' >= c && 0 < i:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Parses the value of a key, returning the rest of s after it.
+func parseKeyValue(s string, rest string) (*KeyBinding, string, error) {
+	if "" == rest {
+		return nil, "", pathErr(s, "missing key value")
+	}
+	if '"' == rest[0] || '\'' == rest[0] {
+		quote := rest[0]
+		var b strings.Builder
+		i := 1
+		for ; i < len(rest) && quote != rest[i]; i++ {
+			if '\\' == rest[i] {
+				i++
+				if len(rest) == i {
+					break
+				}
+			}
+			b.WriteByte(rest[i])
+		}
+		if len(rest) <= i {
+			return nil, "", pathErr(s, "unterminated quoted key value")
+		}
+		value := b.String()
+		if '\'' == quote {
+			if _, err := ParseCIMValue(CIMTypeChar16, value); nil != err {
+				return nil, "", pathErr(s, fmt.Sprintf("invalid char16 key value %q", value))
+			}
+			return &KeyBinding{KeyValue: &KeyValue{ValueType: "string", Type: string(CIMTypeChar16), KeyValue: value}}, rest[i+1:], nil
+		}
+		if hasPathPrefix(value) || hasKeyPrefix(value) {
+			if ref := parseReference(value); nil != ref {
+				return &KeyBinding{ValueReference: ref}, rest[i+1:], nil
+			}
+		}
+		return &KeyBinding{KeyValue: &KeyValue{ValueType: "string", KeyValue: value}}, rest[i+1:], nil
+	}
+	value := rest
+	if end := strings.IndexByte(rest, ','); 0 <= end {
+		value, rest = rest[:end], rest[end:]
+	} else {
+		rest = ""
+	}
+	switch strings.ToUpper(value) {
+	case "TRUE", "FALSE":
+		return &KeyBinding{KeyValue: &KeyValue{ValueType: "boolean", KeyValue: strings.ToLower(value)}}, rest, nil
+	}
+	if false == isNumericKey(value) {
+		return nil, "", pathErr(s, fmt.Sprintf("invalid unquoted key value %q", value))
+	}
+	return &KeyBinding{KeyValue: &KeyValue{ValueType: "numeric", KeyValue: value}}, rest, nil
+}
+
+func isNumericKey(s string) bool {
+	digits, base := integerDigits(s)
+	if _, err := strconv.ParseInt(digits, base, 64); nil == err {
+		return true
+	}
+	if _, err := strconv.ParseUint(strings.TrimPrefix(digits, "+"), base, 64); nil == err {
+		return true
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return nil == err && false == strings.ContainsAny(s, "xXnNiI")
+}
+
+// Reports whether s starts with the host or namespace prefix of a WBEM URI, as formatted references do.
+func hasPathPrefix(s string) bool {
+	lower := strings.ToLower(s)
+	return strings.HasPrefix(s, "/") || strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// Reports whether s starts with <class>.<key>=, as formatted references to a bare instance name do.
+func hasKeyPrefix(s string) bool {
+	dot := strings.IndexByte(s, '.')
+	if 0 > dot || false == isCIMName(s[:dot]) {
+		return false
+	}
+	eq := strings.IndexByte(s[dot+1:], '=')
+	return 0 <= eq && isCIMName(s[dot+1:dot+1+eq])
+}
+
+// Returns the reference a string key value names, or nil when it is not the WBEM URI of an instance.
+func parseReference(s string) *ValueReference {
+	path, err := parsePath(s)
+	if nil != err || false == path.isInstance {
+		return nil
+	}
+	switch {
+	case nil != path.host:
+		return &ValueReference{InstancePath: &InstancePath{&NamespacePath{path.host, path.namespace}, path.name}}
+	case nil != path.namespace:
+		return &ValueReference{LocalInstancePath: &LocalInstancePath{path.namespace, path.name}}
+	}
+	return &ValueReference{InstanceName: path.name}
+}
+
+// ResolveReferenceKeys converts the named string keys, such as the REF keys of the class, to the references
+// their values are the WBEM URIs of. Keys that are already references are left as they are.
+func (name *InstanceName) ResolveReferenceKeys(keys ...string) error {
+	for i := range name.KeyBinding {
+		key := &name.KeyBinding[i]
+		if nil == key.KeyValue {
+			continue
+		}
+		named := false
+		for _, k := range keys {
+			named = named || strings.EqualFold(k, key.Name)
+		}
+		if false == named {
+			continue
+		}
+		ref := parseReference(key.KeyValue.KeyValue)
+		if nil == ref {
+			return pathErr(key.KeyValue.KeyValue, fmt.Sprintf("key %s is not a reference to an instance", key.Name))
+		}
+		key.KeyValue, key.ValueReference = nil, ref
+	}
+	return nil
+}
+
+// ParseObjectPath parses the WBEM URI of an instance or a class, with or without host and namespace.
+// The result holds an INSTANCEPATH when the URI has keys, and a CLASSPATH otherwise.
+func ParseObjectPath(s string) (*ObjectPath, error) {
+	path, err := parsePath(s)
+	if nil != err {
+		return nil, err
+	}
+	var ns *NamespacePath
+	if nil != path.host || nil != path.namespace {
+		ns = &NamespacePath{path.host, path.namespace}
+	}
+	if path.isInstance {
+		return &ObjectPath{InstancePath: &InstancePath{ns, path.name}}, nil
+	}
+	return &ObjectPath{ClassPath: &ClassPath{ns, &ClassName{path.name.ClassName}}}, nil
+}
+
+// ParseInstanceName parses the WBEM URI of an instance, ignoring its host and namespace if any.
+func ParseInstanceName(s string) (*InstanceName, error) {
+	path, err := parsePath(s)
+	if nil != err {
+		return nil, err
+	}
+	return path.name, nil
+}
+
+// ParseInstancePath parses the WBEM URI of an instance, which must have a host and a namespace.
+func ParseInstancePath(s string) (*InstancePath, error) {
+	path, err := parsePath(s)
+	if nil != err {
+		return nil, err
+	}
+	if nil == path.host || nil == path.namespace {
+		return nil, pathErr(s, "an instance path needs a host and a namespace")
+	}
+	return &InstancePath{&NamespacePath{path.host, path.namespace}, path.name}, nil
+}
+
+// ParseLocalInstancePath parses the WBEM URI of an instance, which must have a namespace and no host.
+func ParseLocalInstancePath(s string) (*LocalInstancePath, error) {
+	path, err := parsePath(s)
+	if nil != err {
+		return nil, err
+	}
+	if nil != path.host || nil == path.namespace {
+		return nil, pathErr(s, "a local instance path needs a namespace and no host")
+	}
+	return &LocalInstancePath{path.namespace, path.name}, nil
+}
+
+// ParseClassPath parses the WBEM URI of a class, which must have a host and a namespace and no keys.
+func ParseClassPath(s string) (*ClassPath, error) {
+	path, err := parsePath(s)
+	if nil != err {
+		return nil, err
+	}
+	if path.isInstance {
+		return nil, pathErr(s, "a class path has no keys")
+	}
+	if nil == path.host || nil == path.namespace {
+		return nil, pathErr(s, "a class path needs a host and a namespace")
+	}
+	return &ClassPath{&NamespacePath{path.host, path.namespace}, &ClassName{path.name.ClassName}}, nil
+}
@@ -0,0 +1,69 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"reflect"
+	"testing"
+)
+
+func stringKey(name, value string) KeyBinding {
+	return KeyBinding{Name: name, KeyValue: &KeyValue{ValueType: "string", KeyValue: value}}
+}
+
+func TestInstanceNameRoundTrip(t *testing.T) {
+	target := &InstanceName{ClassName: "CIM_A", KeyBinding: []KeyBinding{stringKey("ID", `say "x"`)}}
+	tests := []struct {
+		name string
+		in   *InstanceName
+		text string
+	}{
+		{"string", &InstanceName{ClassName: "CIM_A", KeyBinding: []KeyBinding{stringKey("ID", "x")}}, `CIM_A.ID="x"`},
+		{"typed keys", &InstanceName{ClassName: "CIM_A", KeyBinding: []KeyBinding{
+			{Name: "B", KeyValue: &KeyValue{ValueType: "boolean", KeyValue: "true"}},
+			{Name: "N", KeyValue: &KeyValue{ValueType: "numeric", KeyValue: "-12"}},
+			{Name: "C", KeyValue: &KeyValue{ValueType: "string", Type: "char16", KeyValue: "'"}},
+		}}, `CIM_A.B=TRUE,N=-12,C='\''`},
+		{"dotted string", &InstanceName{ClassName: "CIM_A", KeyBinding: []KeyBinding{stringKey("ID", "host.example.com")}}, `CIM_A.ID="host.example.com"`},
+		{"bare instance name", &InstanceName{ClassName: "CIM_B", KeyBinding: []KeyBinding{{Name: "R", ValueReference: &ValueReference{InstanceName: target}}}},
+			`CIM_B.R="CIM_A.ID=\"say \\\"x\\\"\""`},
+		{"local instance path", &InstanceName{ClassName: "CIM_B", KeyBinding: []KeyBinding{{Name: "R", ValueReference: &ValueReference{
+			LocalInstancePath: &LocalInstancePath{NewLocalNamespacePath("root/cimv2"), target}}}}},
+			`CIM_B.R="/root/cimv2:CIM_A.ID=\"say \\\"x\\\"\""`},
+		{"instance path", &InstanceName{ClassName: "CIM_B", KeyBinding: []KeyBinding{{Name: "R", ValueReference: &ValueReference{
+			InstancePath: NewInstancePath("host", "root/cimv2", target)}}}},
+			`CIM_B.R="//host/root/cimv2:CIM_A.ID=\"say \\\"x\\\"\""`},
+		{"nested", &InstanceName{ClassName: "CIM_C", KeyBinding: []KeyBinding{{Name: "S", ValueReference: &ValueReference{
+			InstanceName: &InstanceName{ClassName: "CIM_B", KeyBinding: []KeyBinding{{Name: "R", ValueReference: &ValueReference{InstanceName: target}}}}}}}},
+			`CIM_C.S="CIM_B.R=\"CIM_A.ID=\\\"say \\\\\\\"x\\\\\\\"\\\"\""`},
+	}
+	for _, test := range tests {
+		text := test.in.String()
+		if test.text != text {
+			t.Errorf("%s: formatted %s, want %s", test.name, text, test.text)
+		}
+		back, err := ParseInstanceName(text)
+		if nil != err {
+			t.Errorf("%s: ParseInstanceName(%s): %v", test.name, text, err)
+			continue
+		}
+		if false == reflect.DeepEqual(test.in, back) {
+			t.Errorf("%s: parsed %s back as %s, want %s", test.name, text, back, test.in)
+		}
+	}
+}
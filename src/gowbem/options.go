@@ -0,0 +1,186 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Option configures a WBEMConnection created by NewWBEMConnWithOptions.
+type Option func(conn *WBEMConnection) error
+
+// Logger receives the debug messages of a connection. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// RetryPolicy controls how a request is retried when the WBEM server cannot be reached or
+// answers 502, 503 or 504. The delay starts at Backoff and doubles up to MaxBackoff. Retrying is
+// disabled when MaxRetries is 0. A request that failed to connect is always retried. A request
+// that may have reached the server is retried only for the intrinsic methods that do not change
+// anything, such as GetInstance, EnumerateInstances, Associators and the pull operations, unless
+// the context of the operation comes from RetryNonIdempotent.
+type RetryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+type retryNonIdempotentKey struct{}
+
+// RetryNonIdempotent returns a copy of ctx that lets the retry policy send again the operations
+// using it, such as CreateInstance or InvokeMethod, even if the server may have run them already.
+func RetryNonIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryNonIdempotentKey{}, true)
+}
+
+func retriesNonIdempotent(ctx context.Context) bool {
+	retry, _ := ctx.Value(retryNonIdempotentKey{}).(bool)
+	return retry
+}
+
+// WithTimeout sets the timeout of every HTTP request, DefaultTimeout if not set.
+func WithTimeout(timeout time.Duration) Option {
+	return func(conn *WBEMConnection) error {
+		conn.httpc.Timeout = timeout
+		return nil
+	}
+}
+
+// WithTLSOptions sets the TLS configuration of a https connection.
+func WithTLSOptions(opts *TLSOptions) Option {
+	return func(conn *WBEMConnection) error {
+		conn.tlsOpts = opts
+		return nil
+	}
+}
+
+// WithTransport replaces the HTTP transport, for example to add tracing or a mock server. The
+// TLS options and the proxy are not used with a custom transport.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(conn *WBEMConnection) error {
+		if nil == transport {
+			return fmt.Errorf("nil transport")
+		}
+		conn.transport = transport
+		return nil
+	}
+}
+
+// WithProxy sets the proxy of the HTTP transport. The proxy is taken from the environment if
+// not set, and disabled with WithProxy(nil).
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(conn *WBEMConnection) error {
+		conn.proxy = proxy
+		return nil
+	}
+}
+
+// WithProxyURL sends every request through the proxy at proxyURL.
+func WithProxyURL(proxyURL string) Option {
+	return func(conn *WBEMConnection) error {
+		res, err := url.Parse(proxyURL)
+		if nil != err {
+			return err
+		}
+		conn.proxy = http.ProxyURL(res)
+		return nil
+	}
+}
+
+// WithNamespace sets the target namespace, overriding the path of the URL.
+func WithNamespace(namespace string) Option {
+	return func(conn *WBEMConnection) error {
+		conn.SetNamespace(namespace)
+		return nil
+	}
+}
+
+// WithCredentials sets the user credentials, overriding the userinfo of the URL.
+func WithCredentials(username, password string) Option {
+	return func(conn *WBEMConnection) error {
+		conn.creds = StaticCredentials{username, password}
+		return nil
+	}
+}
+
+// WithLogger sends the debug messages of the connection to logger, whether or not the package
+// logger is enabled.
+func WithLogger(logger Logger) Option {
+	return func(conn *WBEMConnection) error {
+		conn.logger = logger
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent header of every request.
+func WithUserAgent(userAgent string) Option {
+	return func(conn *WBEMConnection) error {
+		conn.userAgent = userAgent
+		return nil
+	}
+}
+
+// WithRetryPolicy sets how failed requests are retried.
+func WithRetryPolicy(retry RetryPolicy) Option {
+	return func(conn *WBEMConnection) error {
+		if 0 > retry.MaxRetries || 0 > retry.Backoff || 0 > retry.MaxBackoff {
+			return fmt.Errorf("invalid retry policy")
+		}
+		conn.retry = retry
+		return nil
+	}
+}
+
+func (conn *WBEMConnection) setupTransport() error {
+	if nil != conn.transport {
+		if nil != conn.tlsOpts {
+			return fmt.Errorf("TLS options cannot be used with a custom transport")
+		}
+		conn.httpc.Transport = conn.transport
+		return nil
+	}
+	transport := &http.Transport{
+		Proxy: conn.proxy,
+	}
+	if SchemeHttps == conn.scheme {
+		opts := conn.tlsOpts
+		if nil == opts {
+			opts = &TLSOptions{}
+		}
+		cfg, err := opts.tlsConfig()
+		if nil != err {
+			return err
+		}
+		transport.TLSClientConfig = cfg
+	}
+	conn.httpc.Transport = transport
+	return nil
+}
+
+func (conn *WBEMConnection) logf(format string, args ...interface{}) {
+	if nil != conn.logger {
+		conn.logger.Printf(format, args...)
+	} else {
+		loggerPrint(format, args...)
+	}
+}
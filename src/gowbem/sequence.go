@@ -0,0 +1,440 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SequenceEventKind is what a SequenceTracker noticed in the sequence of the indications of a source.
+type SequenceEventKind int
+
+const (
+	// Indications are missing: the numbers from Expected to Received-1 never arrived, or not in the window.
+	SequenceGap SequenceEventKind = 1 + iota
+
+	// An indication arrived again.
+	SequenceDuplicate
+
+	// An indication arrived after a later one, and after its gap was reported.
+	SequenceOutOfOrder
+
+	// The SequenceContext changed, as the WBEM server restarted or recreated the subscription.
+	SequenceContextChange
+)
+
+func (kind SequenceEventKind) String() string {
+	switch kind {
+	case SequenceGap:
+		return "gap"
+	case SequenceDuplicate:
+		return "duplicate"
+	case SequenceOutOfOrder:
+		return "out-of-order"
+	case SequenceContextChange:
+		return "context-change"
+	}
+	return fmt.Sprintf("SequenceEventKind(%d)", int(kind))
+}
+
+// SequenceEvent reports a gap, duplicate, out-of-order arrival or context change in the sequence
+// of the indications of a source.
+type SequenceEvent struct {
+	Kind SequenceEventKind
+	// the source of the indication, as SequenceTracker.Key returns it
+	Source string
+	// the SequenceContext of the indication, and the previous one of a context change
+	Context         string
+	PreviousContext string
+	// the SequenceNumber expected, and the one received
+	Expected int64
+	Received int64
+	// the number of missing indications of a gap
+	Missing int64
+}
+
+func (event *SequenceEvent) String() string {
+	switch event.Kind {
+	case SequenceGap:
+		return fmt.Sprintf("%s: %d indications missing from %d in context %s", event.Source, event.Missing, event.Expected, event.Context)
+	case SequenceContextChange:
+		return fmt.Sprintf("%s: context %s replaced by %s", event.Source, event.PreviousContext, event.Context)
+	}
+	return fmt.Sprintf("%s: %s %d, expecting %d in context %s", event.Source, event.Kind, event.Received, event.Expected, event.Context)
+}
+
+// The sequence of the indications of a source in a SequenceContext.
+type sequenceState struct {
+	context  string
+	expected int64
+	// the recently seen numbers below expected, oldest first, to tell duplicates from late arrivals
+	seen  map[int64]bool
+	order []int64
+	// the indications after a gap held back for the missing ones, by number
+	held map[int64]*heldIndication
+}
+
+type heldIndication struct {
+	ind *Indication
+	// when it was held back
+	since time.Time
+}
+
+// The SequenceContexts of a source, which may have several at once, one per subscription or destination.
+type sequenceSource struct {
+	// the context of the last indication
+	last     string
+	contexts map[string]*sequenceState
+	// the contexts, least recently used first
+	order []string
+}
+
+// SequenceTracker follows the SequenceContext and SequenceNumber of indications, DSP1054, by source
+// and context, and reports the gaps, duplicates, out-of-order arrivals and context changes to Events.
+// The source is what Key returns, by default the host of the WBEM server and the IndicationFilterName
+// of the indication; a source may have a sequence in several contexts at once, e.g. one per
+// destination, and a context change is reported when a source sends a context it has not sent before.
+// Indications without a SequenceContext and SequenceNumber are passed on as they are.
+// With Deduplicate, the duplicates are dropped. With a Window, indications that arrive after a
+// gap are held back, up to Window of them and for up to MaxHold, until the missing ones arrive, so
+// that they are passed on in order; a gap is reported only when the window is full or the oldest held
+// indication has waited MaxHold, and the indications MaxHold releases are passed to Released.
+type SequenceTracker struct {
+	Events      func(*SequenceEvent)
+	Deduplicate bool
+	Window      int
+	// how long an indication is held back at most, DefaultSequenceMaxHold if 0
+	MaxHold time.Duration
+	// receives the indications released after MaxHold, in order; an IndicationListener passes them on
+	Released func([]*Indication)
+	// returns the source of an indication, the default if nil
+	Key func(*Indication) string
+
+	mu      sync.Mutex
+	sources map[string]*sequenceSource
+	timer   *time.Timer
+}
+
+const (
+	// DefaultSequenceMaxHold is how long a SequenceTracker holds back an indication at most by default.
+	DefaultSequenceMaxHold time.Duration = 10 * time.Second
+
+	// The number of the seen numbers a sequence remembers at least.
+	sequenceMemory = 256
+
+	// The number of the contexts a source keeps at most, which is its number of concurrent subscriptions.
+	sequenceContexts = 16
+
+	// How near the ends of sint64 two numbers are to be taken as a wrap from one to the other.
+	sequenceWrapMargin int64 = 1 << 16
+)
+
+// Returns the SequenceContext and SequenceNumber of an indication, false if it has none.
+func indicationSequence(inst *Instance) (string, int64, bool) {
+	context := propertyString(inst, "SequenceContext")
+	number, err := strconv.ParseInt(strings.TrimSpace(propertyString(inst, "SequenceNumber")), 10, 64)
+	if "" == context || nil != err {
+		return "", 0, false
+	}
+	return context, number, true
+}
+
+// Returns the number after n, which wraps to 0 after the largest sint64.
+func nextSequenceNumber(n int64) int64 {
+	if math.MaxInt64 == n {
+		return 0
+	}
+	return n + 1
+}
+
+// Reports whether to follows from across the wrap after the largest sint64.
+func sequenceWrapped(from, to int64) bool {
+	return math.MaxInt64-sequenceWrapMargin < from && 0 <= to && to < sequenceWrapMargin
+}
+
+// Returns the default source of an indication, <host> or <host>/<IndicationFilterName>.
+func defaultSequenceKey(ind *Indication) string {
+	source, _, err := net.SplitHostPort(ind.Source)
+	if nil != err {
+		source = ind.Source
+	}
+	if filter := propertyString(ind.Instance, "IndicationFilterName"); "" != filter {
+		source += "/" + filter
+	}
+	return source
+}
+
+func (t *SequenceTracker) maxHold() time.Duration {
+	if 0 < t.MaxHold {
+		return t.MaxHold
+	}
+	return DefaultSequenceMaxHold
+}
+
+// Track takes an indication in the order it arrived, and returns the indications to pass on,
+// in order: none while it is held back or dropped, or several once a gap is filled.
+func (t *SequenceTracker) Track(ind *Indication) []*Indication {
+	if nil == ind.Instance {
+		return []*Indication{ind}
+	}
+	context, number, ok := indicationSequence(ind.Instance)
+	if false == ok {
+		return []*Indication{ind}
+	}
+	key := t.Key
+	if nil == key {
+		key = defaultSequenceKey
+	}
+	source := key(ind)
+	var events []*SequenceEvent
+	report := func(event SequenceEvent) {
+		event.Source = source
+		event.Context = context
+		events = append(events, &event)
+	}
+
+	t.mu.Lock()
+	var out []*Indication
+	state, evicted, previous := t.state(source, context, number)
+	if nil != evicted {
+		// a context not seen for long, whose held indications will not be completed
+		out = append(out, evicted.release()...)
+	}
+	if "" != previous {
+		report(SequenceEvent{Kind: SequenceContextChange, PreviousContext: previous, Received: number})
+	}
+	switch {
+	case number == state.expected:
+		out = append(out, ind)
+		state.advance(number)
+		out = append(out, state.drain()...)
+	case state.seen[number] || nil != state.held[number]:
+		report(SequenceEvent{Kind: SequenceDuplicate, Expected: state.expected, Received: number})
+		if false == t.Deduplicate {
+			out = append(out, ind)
+		}
+	case sequenceWrapped(state.expected, number):
+		// the sequence wrapped, passed on after the ones held before the wrap
+		out = append(out, state.release()...)
+		if missing := math.MaxInt64 - state.expected + 1 + number; 0 < missing {
+			report(SequenceEvent{Kind: SequenceGap, Expected: state.expected, Received: number, Missing: missing})
+		}
+		out = append(out, ind)
+		state.advance(number)
+	case number < state.expected || sequenceWrapped(number, state.expected):
+		// missing no more or replayed, but passed on after the ones that followed it, which the sequence goes on from
+		report(SequenceEvent{Kind: SequenceOutOfOrder, Expected: state.expected, Received: number})
+		state.remember(number)
+		out = append(out, ind)
+	case 0 < t.Window:
+		state.held[number] = &heldIndication{ind: ind, since: time.Now()}
+		for len(state.held) > t.Window {
+			gap, released := state.skipGap()
+			report(gap)
+			out = append(out, released...)
+		}
+		if 0 < len(state.held) && nil == t.timer {
+			t.timer = time.AfterFunc(t.maxHold(), t.expire)
+		}
+	default:
+		// a gap, from which the sequence goes on
+		report(SequenceEvent{Kind: SequenceGap, Expected: state.expected, Received: number, Missing: number - state.expected})
+		out = append(out, ind)
+		state.advance(number)
+	}
+	t.mu.Unlock()
+
+	t.report(events)
+	return out
+}
+
+// Returns the sequence of a source in a context, which expects number when it is new, with the state
+// of the context it evicts, if any, and the previous context of the source when the context is new to it.
+func (t *SequenceTracker) state(source, context string, number int64) (state, evicted *sequenceState, previous string) {
+	if nil == t.sources {
+		t.sources = make(map[string]*sequenceSource)
+	}
+	src := t.sources[source]
+	if nil == src {
+		src = &sequenceSource{contexts: make(map[string]*sequenceState)}
+		t.sources[source] = src
+	}
+	state = src.contexts[context]
+	if nil == state {
+		if 0 < len(src.contexts) {
+			previous = src.last
+		}
+		state = &sequenceState{context: context, expected: number, seen: make(map[int64]bool), held: make(map[int64]*heldIndication)}
+		src.contexts[context] = state
+		if sequenceContexts <= len(src.order) {
+			evicted = src.contexts[src.order[0]]
+			delete(src.contexts, src.order[0])
+			src.order = src.order[1:]
+		}
+	} else {
+		for i, c := range src.order {
+			if c == context {
+				src.order = append(src.order[:i], src.order[i+1:]...)
+				break
+			}
+		}
+	}
+	src.order = append(src.order, context)
+	src.last = context
+	return state, evicted, previous
+}
+
+// Releases the indications held back for MaxHold, reporting the gaps before them, and waits for the next ones.
+func (t *SequenceTracker) expire() {
+	var events []*SequenceEvent
+	var out []*Indication
+	t.mu.Lock()
+	t.timer = nil
+	deadline := time.Now().Add(-t.maxHold())
+	var next time.Time
+	for source, src := range t.sources {
+		for _, state := range src.contexts {
+			for state.heldSince(deadline) {
+				gap, released := state.skipGap()
+				gap.Source = source
+				events = append(events, &gap)
+				out = append(out, released...)
+			}
+			for _, held := range state.held {
+				if next.IsZero() || held.since.Before(next) {
+					next = held.since
+				}
+			}
+		}
+	}
+	if false == next.IsZero() {
+		t.timer = time.AfterFunc(time.Until(next.Add(t.maxHold())), t.expire)
+	}
+	t.mu.Unlock()
+
+	t.report(events)
+	if 0 < len(out) && nil != t.Released {
+		t.Released(out)
+	}
+}
+
+func (t *SequenceTracker) report(events []*SequenceEvent) {
+	if nil != t.Events {
+		for _, event := range events {
+			t.Events(event)
+		}
+	}
+}
+
+// Flush returns the indications held back, in order, giving up on the gaps before them.
+func (t *SequenceTracker) Flush() []*Indication {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if nil != t.timer {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	var out []*Indication
+	for _, src := range t.sources {
+		for _, context := range src.order {
+			out = append(out, src.contexts[context].release()...)
+		}
+	}
+	return out
+}
+
+// Marks a number as passed on, and expects the next one.
+func (state *sequenceState) advance(number int64) {
+	state.remember(number)
+	state.expected = nextSequenceNumber(number)
+}
+
+func (state *sequenceState) remember(number int64) {
+	if state.seen[number] {
+		return
+	}
+	state.seen[number] = true
+	state.order = append(state.order, number)
+	if sequenceMemory < len(state.order) {
+		delete(state.seen, state.order[0])
+		state.order = state.order[1:]
+	}
+}
+
+// Returns the held indications that follow the expected number, and expects the one after them.
+func (state *sequenceState) drain() []*Indication {
+	var out []*Indication
+	for next := state.held[state.expected]; nil != next; next = state.held[state.expected] {
+		delete(state.held, state.expected)
+		out = append(out, next.ind)
+		state.advance(state.expected)
+	}
+	return out
+}
+
+// Gives up on the numbers missing before the lowest held one, returning the gap and the indications it releases.
+func (state *sequenceState) skipGap() (SequenceEvent, []*Indication) {
+	first := state.first()
+	gap := SequenceEvent{Kind: SequenceGap, Context: state.context, Expected: state.expected, Received: first, Missing: first - state.expected}
+	state.expected = first
+	return gap, state.drain()
+}
+
+// Reports whether an indication has been held back since deadline or before.
+func (state *sequenceState) heldSince(deadline time.Time) bool {
+	for _, held := range state.held {
+		if false == held.since.After(deadline) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the lowest held number.
+func (state *sequenceState) first() int64 {
+	var first int64 = math.MaxInt64
+	for number := range state.held {
+		if number < first {
+			first = number
+		}
+	}
+	return first
+}
+
+// Returns the held indications in order, and expects the one after the last.
+func (state *sequenceState) release() []*Indication {
+	var numbers []int64
+	for number := range state.held {
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	var out []*Indication
+	for _, number := range numbers {
+		out = append(out, state.held[number].ind)
+		delete(state.held, number)
+		state.advance(number)
+	}
+	return out
+}
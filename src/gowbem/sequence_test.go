@@ -0,0 +1,247 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// An indication of 10.0.0.1 in a context, numbered.
+type seq struct {
+	context string
+	number  int64
+}
+
+func newSequenceIndication(s seq) *Indication {
+	return &Indication{
+		Source: "10.0.0.1:5989",
+		Instance: &Instance{
+			ClassName: "CIM_AlertIndication",
+			Property: []Property{
+				{Name: "SequenceContext", Type: "string", Value: &Value{s.context}},
+				{Name: "SequenceNumber", Type: "sint64", Value: &Value{strconv.FormatInt(s.number, 10)}},
+			},
+		},
+	}
+}
+
+func sequenceOf(inds []*Indication) []int64 {
+	numbers := []int64{}
+	for _, ind := range inds {
+		_, number, _ := indicationSequence(ind.Instance)
+		numbers = append(numbers, number)
+	}
+	return numbers
+}
+
+// Records the events of a tracker as strings.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (rec *eventRecorder) record(event *SequenceEvent) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.events = append(rec.events, event.String())
+}
+
+func (rec *eventRecorder) get() []string {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]string{}, rec.events...)
+}
+
+func TestSequenceTracker(t *testing.T) {
+	tests := []struct {
+		name        string
+		deduplicate bool
+		window      int
+		in          []seq
+		out         []int64
+		events      []string
+	}{
+		{
+			name: "in order",
+			in:   []seq{{"c1", 1}, {"c1", 2}, {"c1", 3}},
+			out:  []int64{1, 2, 3},
+		},
+		{
+			name:   "gap",
+			in:     []seq{{"c1", 1}, {"c1", 4}, {"c1", 5}},
+			out:    []int64{1, 4, 5},
+			events: []string{"10.0.0.1: 2 indications missing from 2 in context c1"},
+		},
+		{
+			name:   "duplicate",
+			in:     []seq{{"c1", 1}, {"c1", 2}, {"c1", 2}, {"c1", 3}},
+			out:    []int64{1, 2, 2, 3},
+			events: []string{"10.0.0.1: duplicate 2, expecting 3 in context c1"},
+		},
+		{
+			name:        "duplicate dropped",
+			deduplicate: true,
+			in:          []seq{{"c1", 1}, {"c1", 2}, {"c1", 1}, {"c1", 3}},
+			out:         []int64{1, 2, 3},
+			events:      []string{"10.0.0.1: duplicate 1, expecting 3 in context c1"},
+		},
+		{
+			name: "out of order",
+			in:   []seq{{"c1", 1}, {"c1", 3}, {"c1", 2}, {"c1", 4}},
+			out:  []int64{1, 3, 2, 4},
+			events: []string{
+				"10.0.0.1: 1 indications missing from 2 in context c1",
+				"10.0.0.1: out-of-order 2, expecting 4 in context c1",
+			},
+		},
+		{
+			name:   "stale replay far behind",
+			in:     []seq{{"c1", 1000}, {"c1", 1001}, {"c1", 5}, {"c1", 1002}, {"c1", 5}},
+			out:    []int64{1000, 1001, 5, 1002, 5},
+			events: []string{"10.0.0.1: out-of-order 5, expecting 1002 in context c1", "10.0.0.1: duplicate 5, expecting 1003 in context c1"},
+		},
+		{
+			name: "wrap",
+			in:   []seq{{"c1", math.MaxInt64 - 1}, {"c1", math.MaxInt64}, {"c1", 0}, {"c1", 2}},
+			out:  []int64{math.MaxInt64 - 1, math.MaxInt64, 0, 2},
+			events: []string{
+				"10.0.0.1: 1 indications missing from 1 in context c1",
+			},
+		},
+		{
+			name: "gap across the wrap",
+			in:   []seq{{"c1", math.MaxInt64 - 1}, {"c1", 1}, {"c1", math.MaxInt64}},
+			out:  []int64{math.MaxInt64 - 1, 1, math.MaxInt64},
+			events: []string{
+				fmt.Sprintf("10.0.0.1: 2 indications missing from %d in context c1", int64(math.MaxInt64)),
+				fmt.Sprintf("10.0.0.1: out-of-order %d, expecting 2 in context c1", int64(math.MaxInt64)),
+			},
+		},
+		{
+			name: "context change",
+			in:   []seq{{"c1", 7}, {"c1", 8}, {"c2", 0}, {"c2", 1}, {"c1", 9}},
+			out:  []int64{7, 8, 0, 1, 9},
+			events: []string{
+				"10.0.0.1: context c1 replaced by c2",
+				// the sequence of c1 goes on, as the source may send both
+			},
+		},
+		{
+			name:   "window fills the gap",
+			window: 4,
+			in:     []seq{{"c1", 1}, {"c1", 3}, {"c1", 4}, {"c1", 2}, {"c1", 5}},
+			out:    []int64{1, 2, 3, 4, 5},
+		},
+		{
+			name:   "window full",
+			window: 2,
+			in:     []seq{{"c1", 1}, {"c1", 3}, {"c1", 4}, {"c1", 5}, {"c1", 2}},
+			out:    []int64{1, 3, 4, 5, 2},
+			events: []string{
+				"10.0.0.1: 1 indications missing from 2 in context c1",
+				"10.0.0.1: out-of-order 2, expecting 6 in context c1",
+			},
+		},
+	}
+	for _, test := range tests {
+		rec := &eventRecorder{}
+		tracker := &SequenceTracker{Events: rec.record, Deduplicate: test.deduplicate, Window: test.window, MaxHold: time.Hour}
+		var out []*Indication
+		for _, s := range test.in {
+			out = append(out, tracker.Track(newSequenceIndication(s))...)
+		}
+		if got := sequenceOf(out); false == reflect.DeepEqual(test.out, got) {
+			t.Errorf("%s: passed on %v, want %v", test.name, got, test.out)
+		}
+		if got := rec.get(); len(test.events) != len(got) || (0 != len(got) && false == reflect.DeepEqual(test.events, got)) {
+			t.Errorf("%s: events %q, want %q", test.name, got, test.events)
+		}
+		if held := tracker.Flush(); 0 != len(held) {
+			t.Errorf("%s: %v still held", test.name, sequenceOf(held))
+		}
+	}
+}
+
+func TestSequenceTrackerWithoutSequence(t *testing.T) {
+	tracker := &SequenceTracker{Window: 4}
+	ind := &Indication{Source: "10.0.0.1:5989", Instance: &Instance{ClassName: "CIM_AlertIndication"}}
+	if out := tracker.Track(ind); 1 != len(out) || ind != out[0] {
+		t.Errorf("passed on %v, want the indication", out)
+	}
+}
+
+func TestSequenceTrackerMaxHold(t *testing.T) {
+	rec := &eventRecorder{}
+	released := make(chan []*Indication, 1)
+	tracker := &SequenceTracker{
+		Events:   rec.record,
+		Window:   8,
+		MaxHold:  20 * time.Millisecond,
+		Released: func(inds []*Indication) { released <- inds },
+	}
+	defer tracker.Flush()
+	for _, s := range []seq{{"c1", 1}, {"c1", 3}, {"c1", 4}} {
+		tracker.Track(newSequenceIndication(s))
+	}
+	select {
+	case inds := <-released:
+		if got := sequenceOf(inds); false == reflect.DeepEqual([]int64{3, 4}, got) {
+			t.Errorf("released %v, want [3 4]", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("nothing released after MaxHold")
+	}
+	if want := []string{"10.0.0.1: 1 indications missing from 2 in context c1"}; false == reflect.DeepEqual(want, rec.get()) {
+		t.Errorf("events %q, want %q", rec.get(), want)
+	}
+	// the sequence goes on after the released ones
+	if out := sequenceOf(tracker.Track(newSequenceIndication(seq{"c1", 5}))); false == reflect.DeepEqual([]int64{5}, out) {
+		t.Errorf("passed on %v, want [5]", out)
+	}
+}
+
+func TestSequenceTrackerFlush(t *testing.T) {
+	rec := &eventRecorder{}
+	tracker := &SequenceTracker{Events: rec.record, Window: 8, MaxHold: time.Hour}
+	var out []*Indication
+	for _, s := range []seq{{"c1", 1}, {"c1", 4}, {"c1", 3}, {"c2", 10}, {"c2", 12}} {
+		out = append(out, tracker.Track(newSequenceIndication(s))...)
+	}
+	if got := sequenceOf(out); false == reflect.DeepEqual([]int64{1, 10}, got) {
+		t.Errorf("passed on %v, want [1 10]", got)
+	}
+	if got := sequenceOf(tracker.Flush()); false == reflect.DeepEqual([]int64{3, 4, 12}, got) {
+		t.Errorf("flushed %v, want [3 4 12]", got)
+	}
+	if got := tracker.Flush(); 0 != len(got) {
+		t.Errorf("flushed %v again", sequenceOf(got))
+	}
+	// after a flush, the sequences go on after the flushed ones
+	if got := sequenceOf(tracker.Track(newSequenceIndication(seq{"c1", 5}))); false == reflect.DeepEqual([]int64{5}, got) {
+		t.Errorf("passed on %v, want [5]", got)
+	}
+	if want := []string{"10.0.0.1: context c1 replaced by c2"}; false == reflect.DeepEqual(want, rec.get()) {
+		t.Errorf("events %q, want %q", rec.get(), want)
+	}
+}
@@ -0,0 +1,464 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultInteropNamespace   string = "root/interop"
+	DefaultSubscriptionPrefix string = "GoWbem"
+	DefaultQueryLanguage      string = "WQL"
+
+	ClassIndicationFilter       string = "CIM_IndicationFilter"
+	ClassListenerDestination    string = "CIM_ListenerDestinationCIMXML"
+	ClassIndicationSubscription string = "CIM_IndicationSubscription"
+)
+
+// IndicationFilter is the query of a CIM_IndicationFilter.
+type IndicationFilter struct {
+	Query string
+	// DefaultQueryLanguage if empty, or e.g. DMTF:CQL
+	QueryLanguage string
+	// the namespace of the indications, the one of the connection if empty
+	SourceNamespace string
+}
+
+// Subscription holds the instance names of a subscription, of its filter and of its destination.
+type Subscription struct {
+	Filter       *InstanceName
+	Destination  *InstanceName
+	Subscription *InstanceName
+}
+
+// SubscriptionManager creates and removes the indication filters, listener destinations and
+// subscriptions of a client in the interop namespace of a WBEM server, DSP1054. The client owns the
+// filters and destinations whose Name is <Prefix>:<ClientID>:<name>, with the : of Prefix and ClientID
+// escaped, and the subscriptions between them, and the manager lists and removes nothing else, so
+// that other hosts and other ClientIDs keep theirs:
+//      m := NewSubscriptionManager(conn)
+//      sub, err := m.Subscribe("alerts", &IndicationFilter{Query: "SELECT * FROM CIM_AlertIndication"}, "https://10.0.0.2:59988")
+//      ...
+//      err = m.Unsubscribe("alerts")
+// Creating is idempotent: what already exists is reused, or fails with ErrAlreadyExists when it
+// differs, e.g. a filter of the same name with another query.
+type SubscriptionManager struct {
+	// the interop namespace, DefaultInteropNamespace if empty
+	Namespace string
+	// the prefix of the owned names, DefaultSubscriptionPrefix if empty
+	Prefix string
+	// the client part of the owned names, the SystemName if empty
+	ClientID string
+	// the SystemName key of the created filters and destinations, the host name if empty
+	SystemName string
+	// the SubscriptionDuration of the created subscriptions, which KeepAlive renews; none if 0
+	Duration time.Duration
+
+	conn *WBEMConnection
+}
+
+// NewSubscriptionManager returns a manager of the subscriptions of conn, with the default namespace and prefix.
+func NewSubscriptionManager(conn *WBEMConnection) *SubscriptionManager {
+	return &SubscriptionManager{
+		Namespace: DefaultInteropNamespace,
+		Prefix:    DefaultSubscriptionPrefix,
+		conn:      conn,
+	}
+}
+
+// Returns the connection to the interop namespace.
+func (m *SubscriptionManager) interop() *WBEMConnection {
+	namespace := m.Namespace
+	if "" == namespace {
+		namespace = DefaultInteropNamespace
+	}
+	return m.conn.inNamespace(namespace)
+}
+
+// Escapes the : that separates the parts of the owned names, so that the owner of a name is exact.
+var ownerEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
+// Returns the Name of an owned filter or destination, <Prefix>:<ClientID>:<name>, where : and %
+// of the prefix and the client ID are escaped as %3A and %25.
+func (m *SubscriptionManager) ownedName(name string) string {
+	prefix := m.Prefix
+	if "" == prefix {
+		prefix = DefaultSubscriptionPrefix
+	}
+	clientID := m.ClientID
+	if "" == clientID {
+		clientID = m.systemName()
+	}
+	return ownerEscaper.Replace(prefix) + ":" + ownerEscaper.Replace(clientID) + ":" + name
+}
+
+// Returns whether the Name key of a filter or destination has the prefix and the client ID as its
+// first two parts. The SystemName key is not checked, as some servers replace it with their own.
+func (m *SubscriptionManager) owns(name *InstanceName) bool {
+	if nil == name {
+		return false
+	}
+	parts := strings.SplitN(keyString(name, "Name"), ":", 3)
+	return 3 == len(parts) && parts[0]+":"+parts[1]+":" == m.ownedName("")
+}
+
+// Returns whether a subscription is between an owned filter and an owned destination.
+func (m *SubscriptionManager) ownsSubscription(name *InstanceName) bool {
+	return m.owns(keyReference(name, "Filter")) && m.owns(keyReference(name, "Handler"))
+}
+
+func (m *SubscriptionManager) systemName() string {
+	if "" != m.SystemName {
+		return m.SystemName
+	}
+	hostname, err := os.Hostname()
+	if nil != err {
+		return m.conn.host
+	}
+	return hostname
+}
+
+// Returns the string value of a key of an instance name, empty if it has none.
+func keyString(name *InstanceName, key string) string {
+	for _, kb := range name.KeyBinding {
+		if strings.EqualFold(key, kb.Name) && nil != kb.KeyValue {
+			return kb.KeyValue.KeyValue
+		}
+	}
+	return ""
+}
+
+// Returns the instance name of a reference key of an instance name, nil if it has none.
+func keyReference(name *InstanceName, key string) *InstanceName {
+	for _, kb := range name.KeyBinding {
+		if strings.EqualFold(key, kb.Name) && nil != kb.ValueReference {
+			return referenceInstanceName(kb.ValueReference)
+		}
+	}
+	return nil
+}
+
+func stringProperty(name, value string) Property {
+	return Property{Name: name, Type: string(CIMTypeString), Value: &Value{value}}
+}
+
+func stringKeyBinding(name, value string) KeyBinding {
+	return KeyBinding{Name: name, KeyValue: &KeyValue{ValueType: "string", Type: string(CIMTypeString), KeyValue: value}}
+}
+
+// Returns the owned instance names of a class.
+func (m *SubscriptionManager) ownedNames(ctx context.Context, className string) ([]InstanceName, error) {
+	names, err := m.interop().EnumerateInstanceNamesContext(ctx, &ClassName{Name: className})
+	if nil != err {
+		return nil, err
+	}
+	owns := m.owns
+	if ClassIndicationSubscription == className {
+		owns = m.ownsSubscription
+	}
+	var owned []InstanceName
+	for i := range names {
+		if owns(&names[i]) {
+			owned = append(owned, names[i])
+		}
+	}
+	return owned, nil
+}
+
+// Returns the owned instance name of a class with the Name key, nil if there is none.
+func (m *SubscriptionManager) find(ctx context.Context, className, name string) (*InstanceName, error) {
+	names, err := m.ownedNames(ctx, className)
+	if nil != err {
+		return nil, err
+	}
+	for i := range names {
+		if keyString(&names[i], "Name") == name {
+			return &names[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Creates a filter or destination, or checks that the existing one has the same property values.
+func (m *SubscriptionManager) create(ctx context.Context, inst *Instance, name string, props ...string) (*InstanceName, error) {
+	conn := m.interop()
+	existing, err := m.find(ctx, inst.ClassName, name)
+	if nil != err {
+		return nil, err
+	}
+	if nil == existing {
+		instName, err := conn.createInstance(ctx, inst)
+		if nil == err && nil == instName {
+			// the server did not return the name, which has the keys of the instance
+			instName = &InstanceName{ClassName: inst.ClassName}
+			for _, key := range []string{"SystemCreationClassName", "SystemName", "CreationClassName", "Name"} {
+				v, _ := inst.GetString(key)
+				instName.KeyBinding = append(instName.KeyBinding, stringKeyBinding(key, v))
+			}
+		}
+		if false == errors.Is(err, ErrAlreadyExists) {
+			return instName, err
+		}
+		// created meanwhile, or owned by a name the enumeration does not show
+		if existing, err = m.find(ctx, inst.ClassName, name); nil != err {
+			return nil, err
+		}
+		if nil == existing {
+			return nil, fmt.Errorf("%s %s: %w", inst.ClassName, name, ErrAlreadyExists)
+		}
+	}
+	found, err := conn.GetInstanceContext(ctx, existing, false, props)
+	if nil != err {
+		return nil, err
+	}
+	for _, prop := range props {
+		if 0 == len(found) {
+			break
+		}
+		want, _ := inst.GetString(prop)
+		if have, _ := found[0].GetString(prop); have != want {
+			return nil, fmt.Errorf("%s %s exists with %s %q: %w", inst.ClassName, name, prop, have, ErrAlreadyExists)
+		}
+	}
+	return existing, nil
+}
+
+// CreateFilter creates the owned CIM_IndicationFilter of the name, or returns the existing one
+// when it has the same query.
+func (m *SubscriptionManager) CreateFilter(name string, filter *IndicationFilter) (*InstanceName, error) {
+	return m.CreateFilterContext(context.Background(), name, filter)
+}
+
+// CreateFilterContext is the same as CreateFilter, with ctx carried to the HTTP requests.
+func (m *SubscriptionManager) CreateFilterContext(ctx context.Context, name string, filter *IndicationFilter) (*InstanceName, error) {
+	if nil == filter || "" == filter.Query {
+		return nil, m.conn.oops(ErrInvalidParameter, "missing filter query")
+	}
+	language := filter.QueryLanguage
+	if "" == language {
+		language = DefaultQueryLanguage
+	}
+	sourceNamespace := filter.SourceNamespace
+	if "" == sourceNamespace {
+		sourceNamespace = m.conn.namespace
+	}
+	inst := &Instance{
+		ClassName: ClassIndicationFilter,
+		Property: []Property{
+			stringProperty("SystemCreationClassName", "CIM_ComputerSystem"),
+			stringProperty("SystemName", m.systemName()),
+			stringProperty("CreationClassName", ClassIndicationFilter),
+			stringProperty("Name", m.ownedName(name)),
+			stringProperty("Query", filter.Query),
+			stringProperty("QueryLanguage", language),
+			stringProperty("SourceNamespace", sourceNamespace),
+		},
+	}
+	return m.create(ctx, inst, m.ownedName(name), "Query", "QueryLanguage")
+}
+
+// CreateDestination creates the owned CIM_ListenerDestinationCIMXML of the name, which has the
+// WBEM server export the indications to the destination URL, e.g. the DestinationURL of an
+// IndicationListener, or returns the existing one when it has the same URL.
+func (m *SubscriptionManager) CreateDestination(name, destination string) (*InstanceName, error) {
+	return m.CreateDestinationContext(context.Background(), name, destination)
+}
+
+// CreateDestinationContext is the same as CreateDestination, with ctx carried to the HTTP requests.
+func (m *SubscriptionManager) CreateDestinationContext(ctx context.Context, name, destination string) (*InstanceName, error) {
+	if "" == destination {
+		return nil, m.conn.oops(ErrInvalidParameter, "missing destination URL")
+	}
+	inst := &Instance{
+		ClassName: ClassListenerDestination,
+		Property: []Property{
+			stringProperty("SystemCreationClassName", "CIM_ComputerSystem"),
+			stringProperty("SystemName", m.systemName()),
+			stringProperty("CreationClassName", ClassListenerDestination),
+			stringProperty("Name", m.ownedName(name)),
+			stringProperty("Destination", destination),
+		},
+	}
+	return m.create(ctx, inst, m.ownedName(name), "Destination")
+}
+
+// CreateSubscription creates the CIM_IndicationSubscription of an owned filter to an owned
+// destination, or returns the existing one.
+func (m *SubscriptionManager) CreateSubscription(filter, destination *InstanceName) (*InstanceName, error) {
+	return m.CreateSubscriptionContext(context.Background(), filter, destination)
+}
+
+// CreateSubscriptionContext is the same as CreateSubscription, with ctx carried to the HTTP requests.
+func (m *SubscriptionManager) CreateSubscriptionContext(ctx context.Context, filter, destination *InstanceName) (*InstanceName, error) {
+	if false == m.owns(filter) || false == m.owns(destination) {
+		return nil, m.conn.oops(ErrInvalidParameter, "filter and destination shall be owned")
+	}
+	existing, err := m.findSubscription(ctx, filter, destination)
+	if nil != err || nil != existing {
+		return existing, err
+	}
+	conn := m.interop()
+	inst := &Instance{
+		ClassName: ClassIndicationSubscription,
+		PropertyReference: []PropertyReference{
+			{
+				Name:           "Filter",
+				ReferenceClass: ClassIndicationFilter,
+				ValueReference: &ValueReference{LocalInstancePath: NewLocalInstancePath(conn.namespace, filter)},
+			}, {
+				Name:           "Handler",
+				ReferenceClass: ClassListenerDestination,
+				ValueReference: &ValueReference{LocalInstancePath: NewLocalInstancePath(conn.namespace, destination)},
+			},
+		},
+	}
+	if 0 < m.Duration {
+		inst.Property = append(inst.Property, Property{
+			Name:  "SubscriptionDuration",
+			Type:  string(CIMTypeUint64),
+			Value: &Value{strconv.FormatInt(int64(m.Duration/time.Second), 10)},
+		})
+	}
+	instName, err := conn.createInstance(ctx, inst)
+	if errors.Is(err, ErrAlreadyExists) {
+		if existing, err = m.findSubscription(ctx, filter, destination); nil == existing && nil == err {
+			err = fmt.Errorf("%s: %w", ClassIndicationSubscription, ErrAlreadyExists)
+		}
+		return existing, err
+	}
+	if nil == err && nil == instName {
+		instName = &InstanceName{
+			ClassName: ClassIndicationSubscription,
+			KeyBinding: []KeyBinding{
+				{Name: "Filter", ValueReference: inst.PropertyReference[0].ValueReference},
+				{Name: "Handler", ValueReference: inst.PropertyReference[1].ValueReference},
+			},
+		}
+	}
+	return instName, err
+}
+
+// Returns the subscription of a filter to a destination, nil if there is none.
+func (m *SubscriptionManager) findSubscription(ctx context.Context, filter, destination *InstanceName) (*InstanceName, error) {
+	names, err := m.ownedNames(ctx, ClassIndicationSubscription)
+	if nil != err {
+		return nil, err
+	}
+	for i := range names {
+		if filter.Equal(keyReference(&names[i], "Filter")) && destination.Equal(keyReference(&names[i], "Handler")) {
+			return &names[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Subscribe creates the owned filter and destination of the name, and the subscription between them.
+func (m *SubscriptionManager) Subscribe(name string, filter *IndicationFilter, destination string) (*Subscription, error) {
+	return m.SubscribeContext(context.Background(), name, filter, destination)
+}
+
+// SubscribeContext is the same as Subscribe, with ctx carried to the HTTP requests.
+func (m *SubscriptionManager) SubscribeContext(ctx context.Context, name string, filter *IndicationFilter, destination string) (*Subscription, error) {
+	var sub Subscription
+	var err error
+	if sub.Filter, err = m.CreateFilterContext(ctx, name, filter); nil != err {
+		return nil, err
+	}
+	if sub.Destination, err = m.CreateDestinationContext(ctx, name, destination); nil != err {
+		return nil, err
+	}
+	if sub.Subscription, err = m.CreateSubscriptionContext(ctx, sub.Filter, sub.Destination); nil != err {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// Owned lists the owned filters, destinations and subscriptions.
+func (m *SubscriptionManager) Owned() (filters, destinations, subscriptions []InstanceName, err error) {
+	return m.OwnedContext(context.Background())
+}
+
+// OwnedContext is the same as Owned, with ctx carried to the HTTP requests.
+func (m *SubscriptionManager) OwnedContext(ctx context.Context) (filters, destinations, subscriptions []InstanceName, err error) {
+	if filters, err = m.ownedNames(ctx, ClassIndicationFilter); nil != err {
+		return nil, nil, nil, err
+	}
+	if destinations, err = m.ownedNames(ctx, ClassListenerDestination); nil != err {
+		return nil, nil, nil, err
+	}
+	if subscriptions, err = m.ownedNames(ctx, ClassIndicationSubscription); nil != err {
+		return nil, nil, nil, err
+	}
+	return filters, destinations, subscriptions, nil
+}
+
+// Unsubscribe removes the owned filter and destination of the name, and their subscriptions.
+func (m *SubscriptionManager) Unsubscribe(name string) error {
+	return m.UnsubscribeContext(context.Background(), name)
+}
+
+// UnsubscribeContext is the same as Unsubscribe, with ctx carried to the HTTP requests.
+func (m *SubscriptionManager) UnsubscribeContext(ctx context.Context, name string) error {
+	return m.remove(ctx, func(instName *InstanceName) bool {
+		return keyString(instName, "Name") == m.ownedName(name)
+	})
+}
+
+// RemoveAll removes the owned filters, destinations and subscriptions.
+func (m *SubscriptionManager) RemoveAll() error {
+	return m.RemoveAllContext(context.Background())
+}
+
+// RemoveAllContext is the same as RemoveAll, with ctx carried to the HTTP requests.
+func (m *SubscriptionManager) RemoveAllContext(ctx context.Context) error {
+	return m.remove(ctx, func(*InstanceName) bool { return true })
+}
+
+// Removes the owned filters and destinations that match, subscriptions first.
+func (m *SubscriptionManager) remove(ctx context.Context, match func(*InstanceName) bool) error {
+	filters, destinations, subscriptions, err := m.OwnedContext(ctx)
+	if nil != err {
+		return err
+	}
+	var names []InstanceName
+	for _, name := range subscriptions {
+		if match(keyReference(&name, "Filter")) || match(keyReference(&name, "Handler")) {
+			names = append(names, name)
+		}
+	}
+	for _, name := range append(filters, destinations...) {
+		if match(&name) {
+			names = append(names, name)
+		}
+	}
+	conn := m.interop()
+	for i := range names {
+		err := conn.DeleteInstanceContext(ctx, &names[i])
+		if nil != err && false == errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("%s: %w", names[i].String(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// A WBEM server keeping the instances of the interop classes in memory, with the keys of DSP1054.
+type interopServer struct {
+	mu        sync.Mutex
+	names     []InstanceName
+	instances []Instance
+	// the intrinsic methods called, by name
+	calls map[string]int
+}
+
+// Returns the name of an instance from the keys of its class.
+func interopInstanceName(inst *Instance) *InstanceName {
+	name := &InstanceName{ClassName: inst.ClassName}
+	if ClassIndicationSubscription == inst.ClassName {
+		for _, prop := range inst.PropertyReference {
+			name.KeyBinding = append(name.KeyBinding, KeyBinding{Name: prop.Name, ValueReference: prop.ValueReference})
+		}
+		return name
+	}
+	for _, key := range []string{"SystemCreationClassName", "SystemName", "CreationClassName", "Name"} {
+		name.KeyBinding = append(name.KeyBinding, stringKeyBinding(key, propertyString(inst, key)))
+	}
+	return name
+}
+
+func (srv *interopServer) find(name *InstanceName) int {
+	for i := range srv.names {
+		if srv.names[i].Equal(name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Adds an instance as CreateInstance does, returning its name, or nil if it exists.
+func (srv *interopServer) add(inst Instance) *InstanceName {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.create(inst)
+}
+
+func (srv *interopServer) create(inst Instance) *InstanceName {
+	name := interopInstanceName(&inst)
+	if 0 <= srv.find(name) {
+		return nil
+	}
+	if duration := propertyString(&inst, "SubscriptionDuration"); "" != duration {
+		inst.Property = append(inst.Property, Property{Name: "SubscriptionTimeRemaining", Type: "uint64", Value: &Value{duration}})
+	}
+	srv.names = append(srv.names, *name)
+	srv.instances = append(srv.instances, inst)
+	return name
+}
+
+// Removes the instance of the name, as if the server lost it.
+func (srv *interopServer) remove(name *InstanceName) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if i := srv.find(name); 0 <= i {
+		srv.names = append(srv.names[:i], srv.names[i+1:]...)
+		srv.instances = append(srv.instances[:i], srv.instances[i+1:]...)
+	}
+}
+
+// Returns the Name keys, or the Name keys of the filter and handler of the subscriptions, of a class, sorted.
+func (srv *interopServer) keys(className string) []string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	keys := []string{}
+	for i := range srv.names {
+		name := &srv.names[i]
+		switch {
+		case className != name.ClassName:
+		case ClassIndicationSubscription == className:
+			keys = append(keys, keyString(keyReference(name, "Filter"), "Name")+" -> "+keyString(keyReference(name, "Handler"), "Name"))
+		default:
+			keys = append(keys, keyString(name, "Name"))
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Returns a property of the instance of the name.
+func (srv *interopServer) property(name *InstanceName, prop string) string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if i := srv.find(name); 0 <= i {
+		return propertyString(&srv.instances[i], prop)
+	}
+	return ""
+}
+
+// Sets a property of the instance of the name.
+func (srv *interopServer) set(name *InstanceName, prop, value string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if i := srv.find(name); 0 <= i {
+		inst := &srv.instances[i]
+		for j := range inst.Property {
+			if prop == inst.Property[j].Name {
+				inst.Property[j].Value = &Value{value}
+			}
+		}
+	}
+}
+
+func (srv *interopServer) count(method string) int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.calls[method]
+}
+
+func iparam(call *IMethodCall, name string) *IParamValue {
+	for i := range call.IParamValue {
+		if name == call.IParamValue[i].Name {
+			return &call.IParamValue[i]
+		}
+	}
+	return &IParamValue{}
+}
+
+func (srv *interopServer) ServeHTTP(writer http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+	var cim CIM
+	if err := xml.Unmarshal(body, &cim); nil != err || nil == cim.Message || nil == cim.Message.SimpleReq || nil == cim.Message.SimpleReq.IMethodCall {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	call := cim.Message.SimpleReq.IMethodCall
+	res := &IMethodResponse{Name: call.Name, IReturnValue: &IReturnValue{}}
+	fail := func(code StatusCode) {
+		res.IReturnValue = nil
+		res.Error = &Error{Code: strconv.Itoa(int(code))}
+	}
+
+	srv.mu.Lock()
+	if nil == srv.calls {
+		srv.calls = make(map[string]int)
+	}
+	srv.calls[call.Name]++
+	switch call.Name {
+	case "EnumerateInstanceNames":
+		for _, name := range srv.names {
+			if iparam(call, "ClassName").ClassName.Name == name.ClassName {
+				res.IReturnValue.InstanceName = append(res.IReturnValue.InstanceName, name)
+			}
+		}
+	case "CreateInstance":
+		if name := srv.create(*iparam(call, "NewInstance").Instance); nil != name {
+			res.IReturnValue.InstanceName = []InstanceName{*name}
+		} else {
+			fail(ErrAlreadyExists)
+		}
+	case "GetInstance":
+		if i := srv.find(iparam(call, "InstanceName").InstanceName); 0 <= i {
+			res.IReturnValue.Instance = []Instance{srv.instances[i]}
+		} else {
+			fail(ErrNotFound)
+		}
+	case "ModifyInstance":
+		modified := iparam(call, "ModifiedInstance").ValueNamedInstance
+		if i := srv.find(modified.InstanceName); 0 <= i {
+			duration := propertyString(modified.Instance, "SubscriptionDuration")
+			inst := &srv.instances[i]
+			for j := range inst.Property {
+				if "SubscriptionDuration" == inst.Property[j].Name {
+					inst.Property[j].Value = &Value{duration}
+				}
+			}
+		} else {
+			fail(ErrNotFound)
+		}
+	case "DeleteInstance":
+		if i := srv.find(iparam(call, "InstanceName").InstanceName); 0 <= i {
+			srv.names = append(srv.names[:i], srv.names[i+1:]...)
+			srv.instances = append(srv.instances[:i], srv.instances[i+1:]...)
+		} else {
+			fail(ErrNotFound)
+		}
+	default:
+		fail(ErrNotSupported)
+	}
+	srv.mu.Unlock()
+
+	raw, _ := xml.Marshal(&CIM{
+		CIMVersion: "2.0",
+		DTDVersion: "2.0",
+		Message:    &Message{ID: cim.Message.ID, ProtocolVersion: "1.0", SimpleRsp: &SimpleRsp{IMethodResponse: res}},
+	})
+	writer.Header().Set("Content-Type", "application/xml; charset=\"utf-8\"")
+	writer.Write(append([]byte(xml.Header), raw...))
+}
+
+func newInteropTest(t *testing.T) (*interopServer, *WBEMConnection, func()) {
+	srv := &interopServer{}
+	ts := httptest.NewServer(srv)
+	conn, err := NewWBEMConnWithOptions(ts.URL + "/root/cimv2")
+	if nil != err {
+		ts.Close()
+		t.Fatalf("NewWBEMConnWithOptions: %v", err)
+	}
+	return srv, conn, ts.Close
+}
+
+func newTestManager(conn *WBEMConnection, clientID string) *SubscriptionManager {
+	m := NewSubscriptionManager(conn)
+	m.ClientID = clientID
+	m.SystemName = "client.example.com"
+	return m
+}
+
+var testFilter = &IndicationFilter{Query: "SELECT * FROM CIM_AlertIndication"}
+
+func TestSubscribeIdempotent(t *testing.T) {
+	srv, conn, done := newInteropTest(t)
+	defer done()
+	m := newTestManager(conn, "a")
+
+	first, err := m.Subscribe("alerts", testFilter, "https://10.0.0.2:5990")
+	if nil != err {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	created := srv.count("CreateInstance")
+	second, err := m.Subscribe("alerts", testFilter, "https://10.0.0.2:5990")
+	if nil != err {
+		t.Fatalf("Subscribe again: %v", err)
+	}
+	if created != srv.count("CreateInstance") {
+		t.Errorf("Subscribe again created %d instances", srv.count("CreateInstance")-created)
+	}
+	if false == first.Filter.Equal(second.Filter) || false == first.Destination.Equal(second.Destination) || false == first.Subscription.Equal(second.Subscription) {
+		t.Errorf("Subscribe again returned %+v, want %+v", second, first)
+	}
+	if got := srv.keys(ClassIndicationSubscription); 1 != len(got) || "GoWbem:a:alerts -> GoWbem:a:alerts" != got[0] {
+		t.Errorf("subscriptions %q", got)
+	}
+	if "GoWbem:a:alerts" != srv.property(first.Filter, "Name") || "WQL" != srv.property(first.Filter, "QueryLanguage") {
+		t.Errorf("filter Name %q, QueryLanguage %q", srv.property(first.Filter, "Name"), srv.property(first.Filter, "QueryLanguage"))
+	}
+
+	// the same name with another query or destination is not taken over
+	if _, err = m.Subscribe("alerts", &IndicationFilter{Query: "SELECT * FROM CIM_InstModification"}, "https://10.0.0.2:5990"); false == errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Subscribe with another query: got %v, want ErrAlreadyExists", err)
+	}
+	if _, err = m.Subscribe("alerts", testFilter, "https://10.0.0.3:5990"); false == errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Subscribe with another destination: got %v, want ErrAlreadyExists", err)
+	}
+}
+
+func TestSubscriptionOwnership(t *testing.T) {
+	srv, conn, done := newInteropTest(t)
+	defer done()
+	a := newTestManager(conn, "a")
+	ab := newTestManager(conn, "a:b")
+	other := newTestManager(conn, "b")
+	for _, m := range []*SubscriptionManager{a, ab, other} {
+		for _, name := range []string{"alerts", "changes"} {
+			if _, err := m.Subscribe(name, testFilter, "https://10.0.0.2:5990"); nil != err {
+				t.Fatalf("Subscribe %s %s: %v", m.ClientID, name, err)
+			}
+		}
+	}
+	// a name that merely starts like an owned one
+	srv.add(Instance{ClassName: ClassIndicationFilter, Property: []Property{stringProperty("Name", "GoWbem:a")}})
+
+	filters, destinations, subscriptions, err := a.Owned()
+	if nil != err {
+		t.Fatalf("Owned: %v", err)
+	}
+	if 2 != len(filters) || 2 != len(destinations) || 2 != len(subscriptions) {
+		t.Errorf("a owns %d filters, %d destinations and %d subscriptions, want 2 of each", len(filters), len(destinations), len(subscriptions))
+	}
+
+	if err = a.Unsubscribe("alerts"); nil != err {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	want := []string{"GoWbem:a", "GoWbem:a%3Ab:alerts", "GoWbem:a%3Ab:changes", "GoWbem:a:changes", "GoWbem:b:alerts", "GoWbem:b:changes"}
+	if got := srv.keys(ClassIndicationFilter); false == reflect.DeepEqual(want, got) {
+		t.Errorf("filters after Unsubscribe %q, want %q", got, want)
+	}
+	if got := srv.keys(ClassIndicationSubscription); 5 != len(got) {
+		t.Errorf("subscriptions after Unsubscribe %q", got)
+	}
+
+	if err = a.RemoveAll(); nil != err {
+		t.Fatalf("RemoveAll: %v", err)
+	}
+	want = []string{"GoWbem:a", "GoWbem:a%3Ab:alerts", "GoWbem:a%3Ab:changes", "GoWbem:b:alerts", "GoWbem:b:changes"}
+	if got := srv.keys(ClassIndicationFilter); false == reflect.DeepEqual(want, got) {
+		t.Errorf("filters after RemoveAll %q, want %q", got, want)
+	}
+	want = []string{"GoWbem:a%3Ab:alerts", "GoWbem:a%3Ab:changes", "GoWbem:b:alerts", "GoWbem:b:changes"}
+	if got := srv.keys(ClassListenerDestination); false == reflect.DeepEqual(want, got) {
+		t.Errorf("destinations after RemoveAll %q, want %q", got, want)
+	}
+	want = []string{
+		"GoWbem:a%3Ab:alerts -> GoWbem:a%3Ab:alerts", "GoWbem:a%3Ab:changes -> GoWbem:a%3Ab:changes",
+		"GoWbem:b:alerts -> GoWbem:b:alerts", "GoWbem:b:changes -> GoWbem:b:changes",
+	}
+	if got := srv.keys(ClassIndicationSubscription); false == reflect.DeepEqual(want, got) {
+		t.Errorf("subscriptions after RemoveAll %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,250 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	DefaultTLSMinVersion uint16 = tls.VersionTLS12
+)
+
+// TLSOptions configures how a https connection verifies the WBEM server and authenticates itself.
+// The server certificate is verified against the system roots unless RootCAs or CAFile is given.
+// When PinnedSHA256 is set without any CA, the chain is not verified and the server certificate
+// is accepted only if its fingerprint is pinned, which suits self-signed BMC certificates.
+type TLSOptions struct {
+	// Trusted CA certificates, as a pool and/or a PEM bundle file.
+	RootCAs *x509.CertPool
+	CAFile  string
+
+	// Client certificates for mutual TLS, as loaded pairs and/or PEM files.
+	Certificates []tls.Certificate
+	CertFile     string
+	KeyFile      string
+
+	// Minimum TLS version, DefaultTLSMinVersion if 0.
+	MinVersion uint16
+
+	// Server name used for SNI and certificate verification, the host of the URL if empty.
+	ServerName string
+
+	// SHA-256 fingerprints of the accepted server certificates, in hex with or without colons.
+	PinnedSHA256 []string
+
+	// Disable all verification of the server certificate. Pinned fingerprints are still checked.
+	InsecureSkipVerify bool
+}
+
+func parseFingerprint(fingerprint string) ([]byte, error) {
+	raw, err := hex.DecodeString(strings.Replace(strings.TrimSpace(fingerprint), ":", "", -1))
+	if nil != err {
+		return nil, fmt.Errorf("invalid SHA-256 fingerprint %q: %v", fingerprint, err)
+	}
+	if sha256.Size != len(raw) {
+		return nil, fmt.Errorf("invalid SHA-256 fingerprint %q: length %d", fingerprint, len(raw))
+	}
+	return raw, nil
+}
+
+func (opts *TLSOptions) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         DefaultTLSMinVersion,
+		ServerName:         opts.ServerName,
+		RootCAs:            opts.RootCAs,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+	if 0 != opts.MinVersion {
+		cfg.MinVersion = opts.MinVersion
+	}
+	if "" != opts.CAFile {
+		pem, err := ioutil.ReadFile(opts.CAFile)
+		if nil != err {
+			return nil, err
+		}
+		if nil == cfg.RootCAs {
+			cfg.RootCAs = x509.NewCertPool()
+		}
+		if false == cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", opts.CAFile)
+		}
+	}
+	cfg.Certificates = append(cfg.Certificates, opts.Certificates...)
+	if "" != opts.CertFile || "" != opts.KeyFile {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if nil != err {
+			return nil, err
+		}
+		cfg.Certificates = append(cfg.Certificates, cert)
+	}
+	if 0 != len(opts.PinnedSHA256) {
+		var pins [][]byte
+		for _, fingerprint := range opts.PinnedSHA256 {
+			pin, err := parseFingerprint(fingerprint)
+			if nil != err {
+				return nil, err
+			}
+			pins = append(pins, pin)
+		}
+		if nil == cfg.RootCAs {
+			cfg.InsecureSkipVerify = true
+		}
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if 0 == len(rawCerts) {
+				return fmt.Errorf("no server certificate")
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			for _, pin := range pins {
+				if bytes.Equal(pin, sum[:]) {
+					return nil
+				}
+			}
+			return fmt.Errorf("server certificate fingerprint %s is not pinned", hex.EncodeToString(sum[:]))
+		}
+	}
+	return cfg, nil
+}
+
+// SetTLSOptions replaces the TLS configuration of a https connection. A nil opts restores the
+// default, which verifies the server certificate against the system roots.
+func (conn *WBEMConnection) SetTLSOptions(opts *TLSOptions) error {
+	if nil != conn.transport {
+		return fmt.Errorf("TLS options cannot be used with a custom transport")
+	}
+	conn.tlsOpts = opts
+	return conn.setupTransport()
+}
+
+// ListenerTLSOptions configures the https of an IndicationListener. The listener presents the
+// certificate of Certificates or CertFile and KeyFile. When ClientCAs, ClientCAFile or
+// ClientPinnedSHA256 is set, the WBEM servers must present a client certificate, which is accepted
+// if it is verified against the CAs, or if its fingerprint is pinned.
+type ListenerTLSOptions struct {
+	// Certificate of the listener, as loaded pairs and/or PEM files.
+	Certificates []tls.Certificate
+	CertFile     string
+	KeyFile      string
+
+	// CA certificates of the client certificates of the WBEM servers, as a pool and/or a PEM bundle file.
+	ClientCAs    *x509.CertPool
+	ClientCAFile string
+
+	// SHA-256 fingerprints of the accepted client certificates, in hex with or without colons.
+	ClientPinnedSHA256 []string
+
+	// Minimum TLS version, DefaultTLSMinVersion if 0.
+	MinVersion uint16
+}
+
+func (opts *ListenerTLSOptions) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: DefaultTLSMinVersion,
+		ClientCAs:  opts.ClientCAs,
+	}
+	if 0 != opts.MinVersion {
+		cfg.MinVersion = opts.MinVersion
+	}
+	cfg.Certificates = append(cfg.Certificates, opts.Certificates...)
+	if "" != opts.CertFile || "" != opts.KeyFile {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if nil != err {
+			return nil, err
+		}
+		cfg.Certificates = append(cfg.Certificates, cert)
+	}
+	if 0 == len(cfg.Certificates) {
+		return nil, fmt.Errorf("no listener certificate")
+	}
+	if "" != opts.ClientCAFile {
+		pem, err := ioutil.ReadFile(opts.ClientCAFile)
+		if nil != err {
+			return nil, err
+		}
+		if nil == cfg.ClientCAs {
+			cfg.ClientCAs = x509.NewCertPool()
+		}
+		if false == cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", opts.ClientCAFile)
+		}
+	}
+	if nil != cfg.ClientCAs {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if 0 != len(opts.ClientPinnedSHA256) {
+		var pins [][]byte
+		for _, fingerprint := range opts.ClientPinnedSHA256 {
+			pin, err := parseFingerprint(fingerprint)
+			if nil != err {
+				return nil, err
+			}
+			pins = append(pins, pin)
+		}
+		// the chain is verified here rather than by crypto/tls, so that pinned self-signed
+		// certificates are accepted along with the ones the CAs verify; the CAs are not
+		// advertised, as clients send no certificate when theirs is not issued by one of them
+		roots := cfg.ClientCAs
+		cfg.ClientCAs = nil
+		cfg.ClientAuth = tls.RequireAnyClientCert
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			if 0 == len(rawCerts) {
+				return fmt.Errorf("no client certificate")
+			}
+			if nil != roots && nil == verifyClientChain(rawCerts, roots) {
+				return nil
+			}
+			sum := sha256.Sum256(rawCerts[0])
+			for _, pin := range pins {
+				if bytes.Equal(pin, sum[:]) {
+					return nil
+				}
+			}
+			return fmt.Errorf("client certificate fingerprint %s is not pinned", hex.EncodeToString(sum[:]))
+		}
+	}
+	return cfg, nil
+}
+
+// Verifies a client certificate chain against roots.
+func verifyClientChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	var certs []*x509.Certificate
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if nil != err {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
@@ -0,0 +1,495 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// ToMOF returns the MOF of a Class, an Instance, a ValueNamedInstance or a QualifierDeclaration, which
+// the MOF compiler reads back into the same object when it knows the classes and the qualifiers used:
+//      [Description ("A managed element.")]
+//      class CIM_ManagedElement : CIM_Root {
+//
+//         [Key, MaxLen (256)]
+//         string InstanceID;
+//
+//         uint32 RequestStateChange(
+//            [In] uint16 RequestedState,
+//            [In (false), Out] CIM_Job REF Job);
+//      };
+// Elements are written in the order of their CIM-XML, one per line, so that the MOF of an object is
+// stable and diffs well. Flavors are written only when the CIM-XML sets them. Embedded instances are
+// written as instance values. Arrays with NULL elements are an error, as VALUE.ARRAY does not keep
+// where the NULL elements were.
+func ToMOF(obj interface{}) (string, error) {
+	var w mofWriter
+	var err error
+	switch o := obj.(type) {
+	case *Class:
+		err = w.class(o)
+	case Class:
+		err = w.class(&o)
+	case *Instance:
+		err = w.instance(o, "")
+	case Instance:
+		err = w.instance(&o, "")
+	case *ValueNamedInstance:
+		err = w.namedInstance(o)
+	case ValueNamedInstance:
+		err = w.namedInstance(&o)
+	case *QualifierDeclaration:
+		err = w.qualifierDeclaration(o)
+	case QualifierDeclaration:
+		err = w.qualifierDeclaration(&o)
+	default:
+		return "", newCIMErr(ErrInvalidParameter, fmt.Sprintf("cannot write %T as MOF", obj), nil)
+	}
+	if nil != err {
+		return "", err
+	}
+	return w.b.String(), nil
+}
+
+const mofIndent = "   "
+
+type mofWriter struct {
+	b strings.Builder
+}
+
+func (w *mofWriter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&w.b, format, args...)
+}
+
+// Quotes a string or a char16 value, escaping what DSP0221 escapes.
+func quoteMOF(s string, quote rune) string {
+	var b strings.Builder
+	b.WriteRune(quote)
+	for _, c := range s {
+		switch c {
+		case '\\':
+			b.WriteString(`\\`)
+		case quote:
+			b.WriteRune('\\')
+			b.WriteRune(c)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if c < 0x20 || 0x7f == c {
+				fmt.Fprintf(&b, `\x%04X`, c)
+			} else {
+				b.WriteRune(c)
+			}
+		}
+	}
+	b.WriteRune(quote)
+	return b.String()
+}
+
+// Returns the MOF literal of a scalar value.
+func mofScalar(typ CIMType, s string, embedded bool) (string, error) {
+	switch typ {
+	case CIMTypeString:
+		if embedded && strings.HasPrefix(strings.TrimSpace(s), "<INSTANCE") {
+			var inst Instance
+			if err := xml.Unmarshal([]byte(s), &inst); nil == err {
+				var w mofWriter
+				if err = w.embeddedInstance(&inst); nil != err {
+					return "", err
+				}
+				return w.b.String(), nil
+			}
+		}
+		return quoteMOF(s, '"'), nil
+	case CIMTypeDateTime:
+		return quoteMOF(s, '"'), nil
+	case CIMTypeChar16:
+		return quoteMOF(s, '\''), nil
+	}
+	v, err := ParseCIMValue(typ, s)
+	if nil != err {
+		return "", err
+	}
+	return FormatCIMValue(typ, v)
+}
+
+// Returns the MOF literal of a VALUE or a VALUE.ARRAY, NULL when there is neither.
+func mofValues(typ CIMType, val *Value, arry *ValueArray, embedded bool) (string, error) {
+	if nil != val {
+		return mofScalar(typ, val.Value, embedded)
+	}
+	if nil == arry {
+		return "NULL", nil
+	}
+	if 0 != len(arry.ValueNull) {
+		// VALUE.ARRAY keeps the NULL elements apart from the others, so where they were is unknown
+		return "", newCIMErr(ErrNotSupported, "the array has NULL elements, which MOF cannot place", nil)
+	}
+	var elems []string
+	for _, v := range arry.Value {
+		s, err := mofScalar(typ, v.Value, embedded)
+		if nil != err {
+			return "", err
+		}
+		elems = append(elems, s)
+	}
+	return "{" + strings.Join(elems, ", ") + "}", nil
+}
+
+func mofReference(ref *ValueReference) string {
+	if nil == ref {
+		return "NULL"
+	}
+	return quoteMOF(ref.String(), '"')
+}
+
+// Returns the flavors a qualifier or a qualifier declaration sets.
+func mofFlavors(overridable, toSubClass, toInstance, translatable string) []string {
+	var flavors []string
+	switch overridable {
+	case "true":
+		flavors = append(flavors, "EnableOverride")
+	case "false":
+		flavors = append(flavors, "DisableOverride")
+	}
+	switch toSubClass {
+	case "true":
+		flavors = append(flavors, "ToSubclass")
+	case "false":
+		flavors = append(flavors, "Restricted")
+	}
+	if "true" == toInstance {
+		flavors = append(flavors, "ToInstance")
+	}
+	if "true" == translatable {
+		flavors = append(flavors, "Translatable")
+	}
+	return flavors
+}
+
+// Returns the qualifier list of an element, "" when it has none.
+func mofQualifiers(quals []Qualifier) (string, error) {
+	if 0 == len(quals) {
+		return "", nil
+	}
+	var list []string
+	for _, q := range quals {
+		s := q.Name
+		typ := CIMType(q.Type)
+		switch {
+		case nil != q.ValueArray:
+			v, err := mofValues(typ, nil, q.ValueArray, false)
+			if nil != err {
+				return "", fmt.Errorf("qualifier %s: %w", q.Name, err)
+			}
+			s += " " + v
+		case nil == q.Value:
+			s += " (NULL)"
+		case CIMTypeBoolean != typ || "true" != strings.ToLower(strings.TrimSpace(q.Value.Value)):
+			v, err := mofScalar(typ, q.Value.Value, false)
+			if nil != err {
+				return "", fmt.Errorf("qualifier %s: %w", q.Name, err)
+			}
+			s += " (" + v + ")"
+		}
+		if flavors := mofFlavors(q.Overridable, q.ToSubClass, q.ToInstance, q.Translatable); 0 != len(flavors) {
+			s += " : " + strings.Join(flavors, " ")
+		}
+		list = append(list, s)
+	}
+	return "[" + strings.Join(list, ", ") + "]", nil
+}
+
+// Writes the qualifier list of an element on its own line.
+func (w *mofWriter) qualifierLine(indent string, quals []Qualifier) error {
+	s, err := mofQualifiers(quals)
+	if nil != err {
+		return err
+	}
+	if "" != s {
+		w.printf("%s%s\n", indent, s)
+	}
+	return nil
+}
+
+func (w *mofWriter) qualifierDeclaration(decl *QualifierDeclaration) error {
+	w.printf("Qualifier %s : %s", decl.Name, decl.Type)
+	if "true" == decl.IsArray {
+		w.printf("[%s]", decl.ArraySize)
+	}
+	if nil != decl.Value || nil != decl.ValueArray {
+		v, err := mofValues(CIMType(decl.Type), decl.Value, decl.ValueArray, false)
+		if nil != err {
+			return fmt.Errorf("qualifier %s: %w", decl.Name, err)
+		}
+		w.printf(" = %s", v)
+	}
+	if nil != decl.Scope {
+		var scopes []string
+		for _, scope := range []struct{ name, value string }{
+			{"class", decl.Scope.Class},
+			{"association", decl.Scope.Association},
+			{"indication", decl.Scope.Indication},
+			{"property", decl.Scope.Property},
+			{"reference", decl.Scope.Reference},
+			{"method", decl.Scope.Method},
+			{"parameter", decl.Scope.Parameter},
+		} {
+			if "true" == scope.value {
+				scopes = append(scopes, scope.name)
+			}
+		}
+		if 7 == len(scopes) {
+			scopes = []string{"any"}
+		}
+		if 0 != len(scopes) {
+			w.printf(",\n%sScope(%s)", mofIndent, strings.Join(scopes, ", "))
+		}
+	}
+	if flavors := mofFlavors(decl.Overridable, decl.ToSubClass, decl.ToInstance, decl.Translatable); 0 != len(flavors) {
+		w.printf(",\n%sFlavor(%s)", mofIndent, strings.Join(flavors, ", "))
+	}
+	w.printf(";\n")
+	return nil
+}
+
+func isEmbedded(embeddedObject string, quals []Qualifier) bool {
+	if "" != embeddedObject {
+		return true
+	}
+	for _, q := range quals {
+		if strings.EqualFold("EmbeddedInstance", q.Name) || strings.EqualFold("EmbeddedObject", q.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *mofWriter) class(cls *Class) error {
+	if err := w.qualifierLine("", cls.Qualifier); nil != err {
+		return fmt.Errorf("class %s: %w", cls.Name, err)
+	}
+	w.printf("class %s", cls.Name)
+	if "" != cls.SuperClass {
+		w.printf(" : %s", cls.SuperClass)
+	}
+	w.printf(" {\n")
+	fail := func(name string, err error) error {
+		return fmt.Errorf("class %s: %s: %w", cls.Name, name, err)
+	}
+	for _, prop := range cls.Property {
+		w.printf("\n")
+		if err := w.qualifierLine(mofIndent, prop.Qualifier); nil != err {
+			return fail(prop.Name, err)
+		}
+		w.printf("%s%s %s", mofIndent, prop.Type, prop.Name)
+		if nil != prop.Value {
+			v, err := mofScalar(CIMType(prop.Type), prop.Value.Value, isEmbedded(prop.EmbeddedObject, prop.Qualifier))
+			if nil != err {
+				return fail(prop.Name, err)
+			}
+			w.printf(" = %s", v)
+		}
+		w.printf(";\n")
+	}
+	for _, prop := range cls.PropertyArray {
+		w.printf("\n")
+		if err := w.qualifierLine(mofIndent, prop.Qualifier); nil != err {
+			return fail(prop.Name, err)
+		}
+		w.printf("%s%s %s[%s]", mofIndent, prop.Type, prop.Name, prop.ArraySize)
+		if nil != prop.ValueArray {
+			v, err := mofValues(CIMType(prop.Type), nil, prop.ValueArray, isEmbedded(prop.EmbeddedObject, prop.Qualifier))
+			if nil != err {
+				return fail(prop.Name, err)
+			}
+			w.printf(" = %s", v)
+		}
+		w.printf(";\n")
+	}
+	for _, prop := range cls.PropertyReference {
+		w.printf("\n")
+		if err := w.qualifierLine(mofIndent, prop.Qualifier); nil != err {
+			return fail(prop.Name, err)
+		}
+		w.printf("%s%s REF %s", mofIndent, prop.ReferenceClass, prop.Name)
+		if nil != prop.ValueReference {
+			w.printf(" = %s", mofReference(prop.ValueReference))
+		}
+		w.printf(";\n")
+	}
+	for i := range cls.Method {
+		if err := w.method(&cls.Method[i]); nil != err {
+			return fail(cls.Method[i].Name, err)
+		}
+	}
+	w.printf("};\n")
+	return nil
+}
+
+func (w *mofWriter) method(meth *Method) error {
+	w.printf("\n")
+	if err := w.qualifierLine(mofIndent, meth.Qualifier); nil != err {
+		return err
+	}
+	typ := meth.Type
+	if "" == typ {
+		typ = "void"
+	}
+	var params []string
+	param := func(quals []Qualifier, decl string) error {
+		s, err := mofQualifiers(quals)
+		if nil != err {
+			return err
+		}
+		if "" != s {
+			decl = s + " " + decl
+		}
+		params = append(params, mofIndent+mofIndent+decl)
+		return nil
+	}
+	for _, p := range meth.Parameter {
+		if err := param(p.Qualifier, p.Type+" "+p.Name); nil != err {
+			return fmt.Errorf("parameter %s: %w", p.Name, err)
+		}
+	}
+	for _, p := range meth.ParameterReference {
+		if err := param(p.Qualifier, p.ReferenceClass+" REF "+p.Name); nil != err {
+			return fmt.Errorf("parameter %s: %w", p.Name, err)
+		}
+	}
+	for _, p := range meth.ParameterArray {
+		if err := param(p.Qualifier, p.Type+" "+p.Name+"["+p.ArraySize+"]"); nil != err {
+			return fmt.Errorf("parameter %s: %w", p.Name, err)
+		}
+	}
+	for _, p := range meth.ParameterRefArray {
+		if err := param(p.Qualifier, p.ReferenceClass+" REF "+p.Name+"["+p.ArraySize+"]"); nil != err {
+			return fmt.Errorf("parameter %s: %w", p.Name, err)
+		}
+	}
+	if 0 == len(params) {
+		w.printf("%s%s %s();\n", mofIndent, typ, meth.Name)
+	} else {
+		w.printf("%s%s %s(\n%s);\n", mofIndent, typ, meth.Name, strings.Join(params, ",\n"))
+	}
+	return nil
+}
+
+// Returns the property assignments of an instance.
+func instanceProperties(inst *Instance) ([]string, error) {
+	var lines []string
+	add := func(quals []Qualifier, name string, value string) error {
+		s, err := mofQualifiers(quals)
+		if nil != err {
+			return err
+		}
+		if "" != s {
+			s += " "
+		}
+		lines = append(lines, fmt.Sprintf("%s%s = %s;", s, name, value))
+		return nil
+	}
+	for _, prop := range inst.Property {
+		v, err := mofValues(CIMType(prop.Type), prop.Value, nil, isEmbedded(prop.EmbeddedObject, prop.Qualifier))
+		if nil == err {
+			err = add(prop.Qualifier, prop.Name, v)
+		}
+		if nil != err {
+			return nil, fmt.Errorf("property %s: %w", prop.Name, err)
+		}
+	}
+	for _, prop := range inst.PropertyArray {
+		v, err := mofValues(CIMType(prop.Type), nil, prop.ValueArray, isEmbedded(prop.EmbeddedObject, prop.Qualifier))
+		if nil == err {
+			err = add(prop.Qualifier, prop.Name, v)
+		}
+		if nil != err {
+			return nil, fmt.Errorf("property %s: %w", prop.Name, err)
+		}
+	}
+	for _, prop := range inst.PropertyReference {
+		if err := add(prop.Qualifier, prop.Name, mofReference(prop.ValueReference)); nil != err {
+			return nil, fmt.Errorf("property %s: %w", prop.Name, err)
+		}
+	}
+	return lines, nil
+}
+
+func (w *mofWriter) instance(inst *Instance, comment string) error {
+	fail := func(err error) error {
+		return fmt.Errorf("instance of %s: %w", inst.ClassName, err)
+	}
+	if "" != comment {
+		w.printf("// %s\n", comment)
+	}
+	if err := w.qualifierLine("", inst.Qualifier); nil != err {
+		return fail(err)
+	}
+	lines, err := instanceProperties(inst)
+	if nil != err {
+		return fail(err)
+	}
+	w.printf("instance of %s {\n", inst.ClassName)
+	for _, line := range lines {
+		w.printf("%s%s\n", mofIndent, line)
+	}
+	w.printf("};\n")
+	return nil
+}
+
+// Writes an embedded instance on a single line, as a value.
+func (w *mofWriter) embeddedInstance(inst *Instance) error {
+	lines, err := instanceProperties(inst)
+	if nil != err {
+		return fmt.Errorf("embedded instance of %s: %w", inst.ClassName, err)
+	}
+	if 0 == len(lines) {
+		w.printf("instance of %s {}", inst.ClassName)
+	} else {
+		w.printf("instance of %s { %s }", inst.ClassName, strings.Join(lines, " "))
+	}
+	return nil
+}
+
+func (w *mofWriter) namedInstance(named *ValueNamedInstance) error {
+	if nil == named.Instance {
+		return newCIMErr(ErrInvalidParameter, "the named instance has no instance", nil)
+	}
+	comment := ""
+	if nil != named.InstanceName {
+		// a line comment ends at the first newline of a key
+		comment = strings.Map(func(c rune) rune {
+			if '\n' == c || '\r' == c {
+				return ' '
+			}
+			return c
+		}, named.InstanceName.String())
+	}
+	return w.instance(named.Instance, comment)
+}
@@ -0,0 +1,159 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const roundTripMOF = `
+Qualifier Units : string = null, Scope(property, method, parameter), Flavor(Translatable);
+Qualifier Sizes : uint32[] = {1, 2}, Scope(property);
+
+[Description ("A \"managed\" element.\nSecond line."), Abstract]
+class Test_Element {
+   [Key, MaxLen (256)]
+   string InstanceID;
+   [Units ("Bytes") : DisableOverride]
+   uint64 Capacity = 0x400;
+   [Sizes {4, 8}]
+   uint16 Codes[];
+   char16 Letter = 'x';
+   real64 Ratio = 0.25;
+   datetime Since;
+   boolean Enabled = true;
+};
+
+[Association]
+class Test_Dependency {
+   [Key] Test_Element REF Antecedent;
+   [Key] Test_Element REF Dependent;
+};
+
+class Test_Job : Test_Element {
+   [EmbeddedInstance ("Test_Element")]
+   string Errors[];
+   uint32 Start([In] uint16 Priority, [In (false), Out] Test_Job REF Job, [Out, EmbeddedInstance ("Test_Element")] string Result);
+   string Name();
+};
+
+instance of Test_Element as $First {
+   InstanceID = "first \\ 'one'";
+   Capacity = 18446744073709551615;
+   Codes = {1, 2, 3};
+   Letter = '\'';
+   Ratio = -1.5e-3;
+   Since = "20200102030405.000000+060";
+   Enabled = false;
+};
+
+instance of Test_Element as $Second { InstanceID = "second\ttab"; Codes = {}; };
+
+instance of Test_Dependency { Antecedent = $First; Dependent = $Second; };
+
+instance of Test_Job {
+   InstanceID = "job";
+   Errors = {instance of Test_Element { InstanceID = "e1"; }, instance of Test_Element { InstanceID = "e2"; Capacity = 7; }};
+};
+`
+
+// Clears the TYPE of the keys of an instance name, which object paths do not carry.
+func untypeKeys(name *InstanceName) {
+	if nil == name {
+		return
+	}
+	for _, key := range name.KeyBinding {
+		if nil != key.KeyValue {
+			key.KeyValue.Type = ""
+		}
+		if nil != key.ValueReference {
+			untypeKeys(key.ValueReference.InstanceName)
+		}
+	}
+	if nil != name.KeyValue {
+		name.KeyValue.Type = ""
+	}
+}
+
+func TestToMOFRoundTrip(t *testing.T) {
+	decls := parseTestMOF(t, roundTripMOF)
+	var b strings.Builder
+	for _, decl := range decls {
+		var obj interface{}
+		switch {
+		case nil != decl.QualifierDeclaration:
+			obj = decl.QualifierDeclaration
+		case nil != decl.Class:
+			obj = decl.Class
+		default:
+			obj = decl.Instance
+		}
+		mof, err := ToMOF(obj)
+		if nil != err {
+			t.Fatalf("ToMOF(%T): %v", obj, err)
+		}
+		b.WriteString(mof)
+	}
+	back, err := ParseMOF(strings.NewReader(b.String()), "back.mof")
+	if nil != err {
+		t.Fatalf("ParseMOF of ToMOF: %v\n%s", err, b.String())
+	}
+	if len(decls) != len(back) {
+		t.Fatalf("%d declarations read back, want %d\n%s", len(back), len(decls), b.String())
+	}
+	for i := range decls {
+		// the aliases are not written, and the references are written as object paths, without the key types
+		want, got := decls[i], back[i]
+		want.Alias = ""
+		for _, decl := range []MOFDeclaration{want, got} {
+			if nil != decl.Instance {
+				for _, prop := range decl.Instance.PropertyReference {
+					untypeKeys(prop.ValueReference.InstanceName)
+				}
+				untypeKeys(decl.InstanceName)
+			}
+		}
+		if false == reflect.DeepEqual(want, got) {
+			mof, _ := ToMOF(got.Instance)
+			t.Errorf("declaration %d changed:\n got %+v\nwant %+v\n%s", i, got, want, mof)
+		}
+	}
+}
+
+func TestToMOFNullArrayElements(t *testing.T) {
+	inst := &Instance{
+		ClassName: "Test_Element",
+		PropertyArray: []PropertyArray{
+			{Name: "Codes", Type: "uint16", ValueArray: &ValueArray{Value: []Value{{"1"}, {"3"}}, ValueNull: []ValueNull{{}}}},
+		},
+	}
+	if _, err := ToMOF(inst); false == errors.Is(err, ErrNotSupported) {
+		t.Errorf("got %v, want CIM_ERR_NOT_SUPPORTED", err)
+	}
+	inst.PropertyArray[0].ValueArray.ValueNull = nil
+	mof, err := ToMOF(inst)
+	if nil != err {
+		t.Fatalf("ToMOF: %v", err)
+	}
+	if false == strings.Contains(mof, "Codes = {1, 3};") {
+		t.Errorf("got %s", mof)
+	}
+}
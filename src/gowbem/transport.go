@@ -0,0 +1,187 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (conn *WBEMConnection) newRequest(ctx context.Context, method string, object string, content []byte) (*http.Request, error) {
+	reqURL := url.URL{
+		Scheme: conn.scheme,
+		Host:   conn.hostPort(),
+		Path:   "/" + DefaultRequestURI,
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", reqURL.String(), bytes.NewReader(content))
+	if nil != err {
+		return nil, err
+	}
+	req.Host = conn.hostPort()
+	req.Header.Add("Content-Type", "application/xml; charset=\"utf-8\"")
+	req.Header.Add("Accept-Encoding", "identity")
+	if "" != conn.userAgent {
+		req.Header.Set("User-Agent", conn.userAgent)
+	}
+	req.Header["TE"] = append(req.Header["TE"], "trailers")
+	req.Header[HttpHdrOperation] = append(req.Header[HttpHdrOperation], "MethodCall")
+	req.Header[HttpHdrMethod] = append(req.Header[HttpHdrMethod], method)
+	req.Header[HttpHdrObject] = append(req.Header[HttpHdrObject], object)
+	return req, nil
+}
+
+func isRetryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// The intrinsic methods that change nothing on the server, and so may run twice.
+var idempotentMethods = map[string]bool{
+	"GetClass":                   true,
+	"GetInstance":                true,
+	"GetProperty":                true,
+	"GetQualifier":               true,
+	"EnumerateClasses":           true,
+	"EnumerateClassNames":        true,
+	"EnumerateInstances":         true,
+	"EnumerateInstanceNames":     true,
+	"EnumerateQualifiers":        true,
+	"ExecQuery":                  true,
+	"Associators":                true,
+	"AssociatorNames":            true,
+	"References":                 true,
+	"ReferenceNames":             true,
+	"OpenEnumerateInstances":     true,
+	"OpenEnumerateInstancePaths": true,
+	"OpenAssociatorInstances":    true,
+	"OpenReferenceInstances":     true,
+	"OpenQueryInstances":         true,
+	"PullInstancesWithPath":      true,
+	"PullInstancePaths":          true,
+	"PullInstances":              true,
+	"EnumerationCount":           true,
+}
+
+// Reports whether the request failed to connect, and so never reached the server.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && "dial" == opErr.Op
+}
+
+// doPost sends a CIM operation request, retrying it according to the retry policy of the
+// connection, and returns the body of the response. A request that may have reached the server
+// is retried only if it is idempotent or ctx comes from RetryNonIdempotent.
+func (conn *WBEMConnection) doPost(ctx context.Context, method string, object string, content []byte, idempotent bool) ([]byte, error) {
+	idempotent = idempotent || retriesNonIdempotent(ctx)
+	backoff := conn.retry.Backoff
+	for attempt := 0; ; attempt++ {
+		raw, retryable, err := conn.doPostOnce(ctx, method, object, content)
+		if nil == err || false == retryable || attempt >= conn.retry.MaxRetries {
+			return raw, err
+		}
+		if false == idempotent && false == isDialError(err) {
+			return raw, err
+		}
+		conn.logf("%s to %s failed, retrying in %v: %v", method, conn.host, backoff, err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if 0 != conn.retry.MaxBackoff && backoff > conn.retry.MaxBackoff {
+			backoff = conn.retry.MaxBackoff
+		}
+	}
+}
+
+func (conn *WBEMConnection) doPostOnce(ctx context.Context, method string, object string, content []byte) ([]byte, bool, error) {
+	var res *http.Response
+	creds, err := conn.credentials(ctx)
+	if nil != err {
+		return nil, false, err
+	}
+	for challenged := false; ; challenged = true {
+		var req *http.Request
+		req, err = conn.newRequest(ctx, method, object, content)
+		if nil != err {
+			return nil, false, err
+		}
+		err = conn.authorize(req, creds.Username, creds.Password)
+		if nil != err {
+			return nil, false, err
+		}
+		res, err = conn.httpc.Do(req)
+		if nil != err {
+			return nil, nil == ctx.Err(), err
+		}
+		if http.StatusUnauthorized != res.StatusCode || true == challenged {
+			break
+		}
+		// send again if the challenge changed or the credentials were rotated meanwhile
+		retry := conn.challenged(res)
+		fresh, err := conn.credentials(ctx)
+		if nil != err {
+			res.Body.Close()
+			return nil, false, err
+		}
+		if *fresh != *creds {
+			creds = fresh
+			retry = true
+		}
+		if false == retry {
+			break
+		}
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+	}
+	defer res.Body.Close()
+	if 200 != res.StatusCode {
+		err = HttpErr{
+			StatusCode: res.StatusCode,
+			Status:     res.Status,
+			CIMError:   HttpCIMError(strings.ToLower(strings.TrimSpace(res.Header.Get(HttpHdrError)))),
+		}
+		return nil, isRetryableStatus(res.StatusCode), err
+	}
+	raw, err := ioutil.ReadAll(res.Body)
+	if nil != err {
+		return nil, false, err
+	}
+	// a server failing in the middle of a chunked response reports it in the trailers
+	if code := strings.TrimSpace(res.Trailer.Get(HttpHdrStatusCode)); "" != code && "0" != code {
+		i, err := strconv.Atoi(code)
+		if nil != err {
+			i = int(ErrFailed)
+		}
+		return nil, false, conn.oops(StatusCode(i), res.Trailer.Get(HttpHdrStatusCodeDescription))
+	}
+	return raw, false, nil
+}
@@ -0,0 +1,104 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package gowbem
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}
+
+func TestRetryUnavailable(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+	conn, err := NewWBEMConnWithOptions(ts.URL, WithRetryPolicy(testRetryPolicy), WithLogger(testLogger{t}))
+	if nil != err {
+		t.Fatalf("NewWBEMConnWithOptions: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		method     string
+		idempotent bool
+		requests   int
+	}{
+		{"GetInstance", context.Background(), "GetInstance", true, 3},
+		{"CreateInstance", context.Background(), "CreateInstance", idempotentMethods["CreateInstance"], 1},
+		{"extrinsic method", context.Background(), "RequestStateChange", false, 1},
+		{"extrinsic method opted in", RetryNonIdempotent(context.Background()), "RequestStateChange", false, 3},
+	}
+	for _, test := range tests {
+		requests = 0
+		_, err := conn.doPost(test.ctx, test.method, "root/cimv2", []byte("<CIM/>"), test.idempotent)
+		if httpErr, ok := err.(HttpErr); false == ok || http.StatusServiceUnavailable != httpErr.StatusCode {
+			t.Errorf("%s: got %v, want a 503 HttpErr", test.name, err)
+		}
+		if test.requests != requests {
+			t.Errorf("%s: %d requests, want %d", test.name, requests, test.requests)
+		}
+	}
+}
+
+// A logger counting the retries of a connection.
+type retryCounter struct {
+	mu      sync.Mutex
+	retries int
+}
+
+func (counter *retryCounter) Printf(format string, v ...interface{}) {
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+	counter.retries++
+}
+
+func TestRetryDialError(t *testing.T) {
+	// a port nobody listens on
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	counter := &retryCounter{}
+	conn, err := NewWBEMConnWithOptions("http://"+addr, WithRetryPolicy(testRetryPolicy), WithLogger(counter))
+	if nil != err {
+		t.Fatalf("NewWBEMConnWithOptions: %v", err)
+	}
+	_, err = conn.doPost(context.Background(), "CreateInstance", "root/cimv2", []byte("<CIM/>"), false)
+	if false == isDialError(err) {
+		t.Fatalf("got %v, want a dial error", err)
+	}
+	if testRetryPolicy.MaxRetries != counter.retries {
+		t.Errorf("%d retries, want %d", counter.retries, testRetryPolicy.MaxRetries)
+	}
+}
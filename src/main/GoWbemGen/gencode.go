@@ -0,0 +1,542 @@
+//    Copyright (C) 2017  Chen Yan
+//
+//    This program is free software: you can redistribute it and/or modify
+//    it under the terms of the GNU General Public License as published by
+//    the Free Software Foundation, either version 3 of the License, or
+//    (at your option) any later version.
+//
+//    This program is distributed in the hope that it will be useful,
+//    but WITHOUT ANY WARRANTY; without even the implied warranty of
+//    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//    GNU General Public License for more details.
+//
+//    You should have received a copy of the GNU General Public License
+//    along with this program.  If not, see <http://www.gnu.org/licenses/>.
+//
+//    Author: Chen Yan <leochenlinux@gmail.com>
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"gowbem"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Generator emits a Go package for CIM classes. For each class it emits:
+//      a struct with cim tags for MarshalInstance and UnmarshalInstance
+//      a constructor of the instance name from the keys
+//      constants for the ValueMap and Values qualifiers of the properties and method parameters
+//      a wrapper of InvokeMethodValue for each method, with structs for the input and output parameters
+// String properties and parameters with the EmbeddedInstance qualifier are *gowbem.Instance. A property
+// whose Go name is taken, such as InstanceName, gets the suffix Property, and a method the suffix Method.
+type Generator struct {
+	pkg     string
+	source  string
+	classes map[string]*gowbem.Class
+	buf     bytes.Buffer
+}
+
+func NewGenerator(pkg string, source string, classes []gowbem.Class) *Generator {
+	gen := &Generator{
+		pkg:     pkg,
+		source:  source,
+		classes: make(map[string]*gowbem.Class),
+	}
+	for i := range classes {
+		gen.classes[strings.ToLower(classes[i].Name)] = &classes[i]
+	}
+	return gen
+}
+
+func (gen *Generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&gen.buf, format, args...)
+}
+
+// Generate returns the gofmt-ed code of the classes.
+func (gen *Generator) Generate(classNames []string) ([]byte, error) {
+	gen.buf.Reset()
+	gen.printf("// Code generated by GoWbemGen from %s. DO NOT EDIT.\n\n", gen.source)
+	gen.printf("package %s\n\n", gen.pkg)
+	gen.printf("import \"gowbem\"\n")
+	done := make(map[string]bool)
+	for _, name := range classNames {
+		if done[strings.ToLower(name)] {
+			continue
+		}
+		done[strings.ToLower(name)] = true
+		cls, err := gen.resolve(name, nil)
+		if nil != err {
+			return nil, err
+		}
+		gen.genClass(cls)
+	}
+	code, err := format.Source(gen.buf.Bytes())
+	if nil != err {
+		return gen.buf.Bytes(), fmt.Errorf("generated code does not compile: %w", err)
+	}
+	return code, nil
+}
+
+// Returns the class with the properties and methods of its superclasses, when they are known.
+func (gen *Generator) resolve(name string, seen map[string]bool) (*gowbem.Class, error) {
+	cls, ok := gen.classes[strings.ToLower(name)]
+	if false == ok {
+		return nil, fmt.Errorf("no definition of class %s", name)
+	}
+	if nil == seen {
+		seen = make(map[string]bool)
+	}
+	if seen[strings.ToLower(name)] {
+		return nil, fmt.Errorf("class %s inherits from itself", name)
+	}
+	seen[strings.ToLower(name)] = true
+	if _, ok := gen.classes[strings.ToLower(cls.SuperClass)]; "" == cls.SuperClass || false == ok {
+		return cls, nil
+	}
+	super, err := gen.resolve(cls.SuperClass, seen)
+	if nil != err {
+		return nil, err
+	}
+	merged := *cls
+	merged.Property = mergeProperties(super.Property, cls.Property)
+	merged.PropertyArray = mergePropertyArrays(super.PropertyArray, cls.PropertyArray)
+	merged.PropertyReference = mergePropertyReferences(super.PropertyReference, cls.PropertyReference)
+	merged.Method = mergeMethods(super.Method, cls.Method)
+	return &merged, nil
+}
+
+// Merges the qualifiers of an overridden element into the ones of the overriding element.
+func mergeQualifiers(inherited, local []gowbem.Qualifier) []gowbem.Qualifier {
+	merged := append([]gowbem.Qualifier{}, local...)
+	for _, q := range inherited {
+		if nil == findQualifier(local, q.Name) {
+			merged = append(merged, q)
+		}
+	}
+	return merged
+}
+
+func mergeProperties(inherited, local []gowbem.Property) []gowbem.Property {
+	merged := append([]gowbem.Property{}, inherited...)
+	for _, prop := range local {
+		found := false
+		for i := range merged {
+			if strings.EqualFold(prop.Name, merged[i].Name) {
+				prop.Qualifier = mergeQualifiers(merged[i].Qualifier, prop.Qualifier)
+				merged[i], found = prop, true
+			}
+		}
+		if false == found {
+			merged = append(merged, prop)
+		}
+	}
+	return merged
+}
+
+func mergePropertyArrays(inherited, local []gowbem.PropertyArray) []gowbem.PropertyArray {
+	merged := append([]gowbem.PropertyArray{}, inherited...)
+	for _, prop := range local {
+		found := false
+		for i := range merged {
+			if strings.EqualFold(prop.Name, merged[i].Name) {
+				prop.Qualifier = mergeQualifiers(merged[i].Qualifier, prop.Qualifier)
+				merged[i], found = prop, true
+			}
+		}
+		if false == found {
+			merged = append(merged, prop)
+		}
+	}
+	return merged
+}
+
+func mergePropertyReferences(inherited, local []gowbem.PropertyReference) []gowbem.PropertyReference {
+	merged := append([]gowbem.PropertyReference{}, inherited...)
+	for _, prop := range local {
+		found := false
+		for i := range merged {
+			if strings.EqualFold(prop.Name, merged[i].Name) {
+				prop.Qualifier = mergeQualifiers(merged[i].Qualifier, prop.Qualifier)
+				merged[i], found = prop, true
+			}
+		}
+		if false == found {
+			merged = append(merged, prop)
+		}
+	}
+	return merged
+}
+
+func mergeMethods(inherited, local []gowbem.Method) []gowbem.Method {
+	merged := append([]gowbem.Method{}, inherited...)
+	for _, meth := range local {
+		found := false
+		for i := range merged {
+			if strings.EqualFold(meth.Name, merged[i].Name) {
+				meth.Qualifier = mergeQualifiers(merged[i].Qualifier, meth.Qualifier)
+				merged[i], found = meth, true
+			}
+		}
+		if false == found {
+			merged = append(merged, meth)
+		}
+	}
+	return merged
+}
+
+func findQualifier(qualifiers []gowbem.Qualifier, name string) *gowbem.Qualifier {
+	for i := range qualifiers {
+		if strings.EqualFold(name, qualifiers[i].Name) {
+			return &qualifiers[i]
+		}
+	}
+	return nil
+}
+
+// Returns a boolean qualifier, which is true when it is present without a value.
+func boolQualifier(qualifiers []gowbem.Qualifier, name string, def bool) bool {
+	q := findQualifier(qualifiers, name)
+	if nil == q {
+		return def
+	}
+	if nil == q.Value {
+		return true
+	}
+	return "true" == strings.ToLower(strings.TrimSpace(q.Value.Value))
+}
+
+func arrayQualifier(qualifiers []gowbem.Qualifier, name string) []string {
+	q := findQualifier(qualifiers, name)
+	if nil == q || nil == q.ValueArray {
+		return nil
+	}
+	var strs []string
+	for _, v := range q.ValueArray.Value {
+		strs = append(strs, v.Value)
+	}
+	return strs
+}
+
+// The Go type of a CIM type in the generated code.
+var goTypes = map[gowbem.CIMType]string{
+	gowbem.CIMTypeUint8:     "uint8",
+	gowbem.CIMTypeUint16:    "uint16",
+	gowbem.CIMTypeUint32:    "uint32",
+	gowbem.CIMTypeUint64:    "uint64",
+	gowbem.CIMTypeSint8:     "int8",
+	gowbem.CIMTypeSint16:    "int16",
+	gowbem.CIMTypeSint32:    "int32",
+	gowbem.CIMTypeSint64:    "int64",
+	gowbem.CIMTypeReal32:    "float32",
+	gowbem.CIMTypeReal64:    "float64",
+	gowbem.CIMTypeBoolean:   "bool",
+	gowbem.CIMTypeChar16:    "rune",
+	gowbem.CIMTypeString:    "string",
+	gowbem.CIMTypeDateTime:  "gowbem.CIMDateTime",
+	gowbem.CIMTypeReference: "*gowbem.InstanceName",
+}
+
+// Returns an exported Go identifier for a CIM name, e.g. CIMComputerSystem for CIM_ComputerSystem.
+func goTypeName(name string) string {
+	return exported(strings.Replace(name, "_", "", -1))
+}
+
+func exported(name string) string {
+	if "" == name {
+		return "X"
+	}
+	r := []rune(name)
+	if false == unicode.IsLetter(r[0]) {
+		return "X" + name
+	}
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// Returns an unexported Go identifier for a parameter of a constructor.
+func goParamName(name string) string {
+	r := []rune(exported(name))
+	r[0] = unicode.ToLower(r[0])
+	s := string(r)
+	if token.IsKeyword(s) || "gowbem" == s {
+		s += "Arg"
+	}
+	return s
+}
+
+// Returns a Go identifier for a Values entry, e.g. EnabledButOffline for "Enabled but Offline".
+func enumName(value string) string {
+	var b strings.Builder
+	upper := true
+	for _, c := range value {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			if upper {
+				c = unicode.ToUpper(c)
+			}
+			b.WriteRune(c)
+			upper = false
+		} else {
+			upper = true
+		}
+	}
+	return b.String()
+}
+
+// A property or a parameter of the generated code.
+type genField struct {
+	name    string
+	goName  string
+	typ     gowbem.CIMType
+	isArray bool
+	key     bool
+	quals   []gowbem.Qualifier
+}
+
+// Reports whether the field is a string holding embedded instances, which MarshalInstance and
+// MarshalParamValues encode from *gowbem.Instance.
+func (field *genField) embedded() bool {
+	return gowbem.CIMTypeString == field.typ && nil != findQualifier(field.quals, "EmbeddedInstance")
+}
+
+func (field *genField) goType(pointer bool) string {
+	t := goTypes[field.typ]
+	switch {
+	case field.embedded() && field.isArray:
+		return "[]*gowbem.Instance"
+	case field.embedded():
+		return "*gowbem.Instance"
+	case field.isArray:
+		return "[]" + t
+	case pointer && gowbem.CIMTypeReference != field.typ:
+		return "*" + t
+	}
+	return t
+}
+
+func (field *genField) tag(options ...string) string {
+	if gowbem.CIMTypeChar16 == field.typ {
+		options = append(options, "type=char16")
+	}
+	return fmt.Sprintf("`cim:\"%s\"`", strings.Join(append([]string{field.name}, options...), ","))
+}
+
+func classFields(cls *gowbem.Class) []genField {
+	var fields []genField
+	for _, prop := range cls.Property {
+		fields = append(fields, genField{name: prop.Name, typ: gowbem.CIMType(prop.Type), key: boolQualifier(prop.Qualifier, "Key", false), quals: prop.Qualifier})
+	}
+	for _, prop := range cls.PropertyArray {
+		fields = append(fields, genField{name: prop.Name, typ: gowbem.CIMType(prop.Type), isArray: true, quals: prop.Qualifier})
+	}
+	for _, prop := range cls.PropertyReference {
+		fields = append(fields, genField{name: prop.Name, typ: gowbem.CIMTypeReference, key: boolQualifier(prop.Qualifier, "Key", false), quals: prop.Qualifier})
+	}
+	for i := range fields {
+		fields[i].goName = exported(fields[i].name)
+	}
+	return fields
+}
+
+func methodParams(meth *gowbem.Method) (in, out []genField) {
+	var params []genField
+	for _, p := range meth.Parameter {
+		params = append(params, genField{name: p.Name, typ: gowbem.CIMType(p.Type), quals: p.Qualifier})
+	}
+	for _, p := range meth.ParameterArray {
+		params = append(params, genField{name: p.Name, typ: gowbem.CIMType(p.Type), isArray: true, quals: p.Qualifier})
+	}
+	for _, p := range meth.ParameterReference {
+		params = append(params, genField{name: p.Name, typ: gowbem.CIMTypeReference, quals: p.Qualifier})
+	}
+	for _, p := range meth.ParameterRefArray {
+		params = append(params, genField{name: p.Name, typ: gowbem.CIMTypeReference, isArray: true, quals: p.Qualifier})
+	}
+	for _, p := range params {
+		p.goName = exported(p.name)
+		if boolQualifier(p.quals, "In", true) {
+			in = append(in, p)
+		}
+		if boolQualifier(p.quals, "Out", false) {
+			out = append(out, p)
+		}
+	}
+	return in, out
+}
+
+func (gen *Generator) genClass(cls *gowbem.Class) {
+	typeName := goTypeName(cls.Name)
+	fields := classFields(cls)
+	used := map[string]bool{"CIMClassName": true, "InstanceName": true}
+	for i := range fields {
+		for used[fields[i].goName] {
+			fields[i].goName += "Property"
+		}
+		used[fields[i].goName] = true
+	}
+
+	gen.printf("\n// %s maps the instances of the CIM class %s.\n", typeName, cls.Name)
+	gen.printf("type %s struct {\n", typeName)
+	for _, field := range fields {
+		if false == field.typ.IsValid() {
+			gen.printf("\t// %s has the unknown type %q.\n", field.name, field.typ)
+			continue
+		}
+		if field.key {
+			gen.printf("\t%s %s %s\n", field.goName, field.goType(false), field.tag("key"))
+		} else {
+			gen.printf("\t%s %s %s\n", field.goName, field.goType(true), field.tag("omitempty"))
+		}
+	}
+	gen.printf("}\n")
+
+	gen.printf("\n// CIMClassName returns %q, the class MarshalInstance gives to the instance.\n", cls.Name)
+	gen.printf("func (%s) CIMClassName() string {\n\treturn %q\n}\n", typeName, cls.Name)
+
+	gen.printf("\n// InstanceName returns the instance name of obj, made of its keys.\n")
+	gen.printf("func (obj *%s) InstanceName() (*gowbem.InstanceName, error) {\n\treturn gowbem.MarshalInstanceName(obj)\n}\n", typeName)
+
+	var keys []genField
+	for _, field := range fields {
+		if field.key && field.typ.IsValid() {
+			keys = append(keys, field)
+		}
+	}
+	if 0 != len(keys) {
+		var params, inits []string
+		for _, key := range keys {
+			params = append(params, fmt.Sprintf("%s %s", goParamName(key.name), key.goType(false)))
+			inits = append(inits, fmt.Sprintf("%s: %s", key.goName, goParamName(key.name)))
+		}
+		gen.printf("\n// New%sName returns the instance name of %s from its keys.\n", typeName, cls.Name)
+		gen.printf("func New%sName(%s) (*gowbem.InstanceName, error) {\n", typeName, strings.Join(params, ", "))
+		gen.printf("\treturn gowbem.MarshalInstanceName(&%s{%s})\n}\n", typeName, strings.Join(inits, ", "))
+	}
+
+	for _, field := range fields {
+		gen.genValueMap(typeName, cls.Name, &field)
+	}
+
+	for i := range cls.Method {
+		gen.genMethod(typeName, cls, &cls.Method[i], used)
+	}
+}
+
+// Emits the constants of the ValueMap and Values qualifiers of an integer or string property or parameter.
+func (gen *Generator) genValueMap(typeName string, className string, field *genField) {
+	valueMap := arrayQualifier(field.quals, "ValueMap")
+	values := arrayQualifier(field.quals, "Values")
+	if 0 == len(values) || (0 != len(valueMap) && len(valueMap) != len(values)) {
+		return
+	}
+	if false == field.typ.IsInteger() && gowbem.CIMTypeString != field.typ {
+		return
+	}
+	if 0 == len(valueMap) {
+		// without ValueMap, the values are the indexes of Values
+		for i := range values {
+			valueMap = append(valueMap, strconv.Itoa(i))
+		}
+	}
+	var consts []string
+	names := make(map[string]bool)
+	for i, v := range valueMap {
+		var literal string
+		if gowbem.CIMTypeString == field.typ {
+			literal = strconv.Quote(v)
+		} else {
+			// ranges such as 0x8000.. have no single value
+			if _, err := gowbem.ParseCIMValue(field.typ, v); nil != err {
+				continue
+			}
+			literal = strings.TrimSpace(v)
+		}
+		name := typeName + field.goName + enumName(values[i])
+		if names[name] || name == typeName+field.goName {
+			name += "_" + enumName(v)
+		}
+		if names[name] {
+			continue
+		}
+		names[name] = true
+		consts = append(consts, fmt.Sprintf("\t%s %s = %s // %s\n", name, goTypes[field.typ], literal, strings.Replace(values[i], "\n", " ", -1)))
+	}
+	if 0 == len(consts) {
+		return
+	}
+	gen.printf("\n// Values of %s.%s.\nconst (\n%s)\n", className, field.name, strings.Join(consts, ""))
+}
+
+// Emits the wrapper of a method, which returns the typed return value and the output parameters.
+func (gen *Generator) genMethod(typeName string, cls *gowbem.Class, meth *gowbem.Method, used map[string]bool) {
+	if false == gowbem.CIMType(meth.Type).IsValid() {
+		gen.printf("\n// The method %s of %s has the unknown return type %q.\n", meth.Name, cls.Name, meth.Type)
+		return
+	}
+	retType := goTypes[gowbem.CIMType(meth.Type)]
+	funcName := exported(meth.Name)
+	for used[funcName] {
+		funcName += "Method"
+	}
+	used[funcName] = true
+	in, out := methodParams(meth)
+	inType := typeName + exported(meth.Name) + "In"
+	outType := typeName + exported(meth.Name) + "Out"
+
+	gen.printf("\n// %s holds the input parameters of %s.%s.\n", inType, cls.Name, meth.Name)
+	gen.printf("type %s struct {\n", inType)
+	for _, p := range in {
+		if p.typ.IsValid() {
+			gen.printf("\t%s %s %s\n", p.goName, p.goType(true), p.tag())
+		}
+	}
+	gen.printf("}\n")
+
+	gen.printf("\n// %s holds the output parameters of %s.%s.\n", outType, cls.Name, meth.Name)
+	gen.printf("type %s struct {\n", outType)
+	for _, p := range out {
+		if p.typ.IsValid() {
+			gen.printf("\t%s %s %s\n", p.goName, p.goType(true), p.tag())
+		}
+	}
+	gen.printf("}\n")
+
+	static := boolQualifier(meth.Qualifier, "Static", false)
+	if static {
+		gen.printf("\n// %s invokes the static method %s of the class %s.\n", funcName, meth.Name, cls.Name)
+	} else {
+		gen.printf("\n// %s invokes the method %s of %s on the instance named by the keys of obj.\n", funcName, meth.Name, cls.Name)
+	}
+	gen.printf("func (obj *%s) %s(conn *gowbem.WBEMConnection, in *%s) (%s, *%s, error) {\n", typeName, funcName, inType, retType, outType)
+	gen.printf("\tvar ret %s\n", retType)
+	if static {
+		gen.printf("\tobjectName := &gowbem.ObjectName{ClassName: &gowbem.ClassName{Name: %q}}\n", cls.Name)
+	} else {
+		gen.printf("\tname, err := obj.InstanceName()\n\tif nil != err {\n\t\treturn ret, nil, err\n\t}\n")
+		gen.printf("\tobjectName := &gowbem.ObjectName{InstanceName: name}\n")
+	}
+	gen.printf("\tvar params []gowbem.ParamValue\n")
+	gen.printf("\tif nil != in {\n")
+	gen.printf("\t\tvar err error\n")
+	gen.printf("\t\tif params, err = gowbem.MarshalParamValues(in); nil != err {\n\t\t\treturn ret, nil, err\n\t\t}\n\t}\n")
+	gen.printf("\tretVal, outParams, err := conn.InvokeMethodValue(objectName, %q, params)\n", meth.Name)
+	gen.printf("\tif nil != err {\n\t\treturn ret, nil, err\n\t}\n")
+	gen.printf("\tif err := gowbem.UnmarshalValue(retVal, &ret); nil != err {\n\t\treturn ret, nil, err\n\t}\n")
+	gen.printf("\tout := &%s{}\n", outType)
+	gen.printf("\tif err := gowbem.UnmarshalParamValues(outParams, out); nil != err {\n\t\treturn ret, nil, err\n\t}\n")
+	gen.printf("\treturn ret, out, nil\n}\n")
+
+	// a parameter that is both In and Out has its constants once
+	done := make(map[string]bool)
+	for _, p := range append(in, out...) {
+		if false == done[p.name] {
+			done[p.name] = true
+			gen.genValueMap(typeName+exported(meth.Name), cls.Name+"."+meth.Name, &p)
+		}
+	}
+}